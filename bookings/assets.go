@@ -0,0 +1,42 @@
+// Package bookings holds the files the server reads at run time: the page
+// templates, the static assets and the database migrations. They are
+// embedded in the binary so it can be started from any directory.
+package bookings
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+)
+
+//go:embed templates/*.html static migrations
+var files embed.FS
+
+var (
+	// Templates holds the page and layout templates.
+	Templates = sub("templates")
+	// Static holds the files served under /static.
+	Static = sub("static")
+	// Migrations holds the fizz and sql migrations.
+	Migrations = sub("migrations")
+)
+
+func sub(dir string) fs.FS {
+	f, err := fs.Sub(files, dir)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Dir returns dir read from the working directory when dev is set and the
+// directory exists there, so edits show up without rebuilding, and the
+// embedded copy otherwise.
+func Dir(dir string, dev bool) fs.FS {
+	if dev {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return os.DirFS(dir)
+		}
+	}
+	return sub(dir)
+}
@@ -0,0 +1,52 @@
+package bookings
+
+import (
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestEmbedded(t *testing.T) {
+	var tests = []struct {
+		name string
+		fsys fs.FS
+		file string
+	}{
+		{"templates", Templates, "home.page.html"},
+		{"layouts", Templates, "base.layout.html"},
+		{"static", Static, "css/styles.css"},
+		{"migrations", Migrations, "20231217091113_create_user_table.up.fizz"},
+	}
+
+	for _, e := range tests {
+		if _, err := fs.Stat(e.fsys, e.file); err != nil {
+			t.Errorf("%s: %s is not embedded: %s", e.name, e.file, err)
+		}
+	}
+
+	if _, err := fs.Stat(Templates, "working-html"); err == nil {
+		t.Error("the working-html drafts were embedded")
+	}
+}
+
+func TestDir(t *testing.T) {
+	disk := reflect.TypeOf(os.DirFS("."))
+
+	var tests = []struct {
+		name     string
+		dir      string
+		dev      bool
+		fromDisk bool
+	}{
+		{"development", "templates", true, true},
+		{"production", "templates", false, false},
+		{"missing directory", "no-such-dir", true, false},
+	}
+
+	for _, e := range tests {
+		if got := reflect.TypeOf(Dir(e.dir, e.dev)) == disk; got != e.fromDisk {
+			t.Errorf("%s: expected read from disk to be %v but got %v", e.name, e.fromDisk, got)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Command bookingsctl manages the users, rooms and bookings of the bookings
+// database. It reads the same config file, environment and flags as the
+// server and goes through the same repository, so changes made here behave
+// like changes made in the web app.
+//
+//	bookingsctl [settings flags] <command> [command flags] [args]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chenemiken/goland/bookings/internal/config"
+	"github.com/chenemiken/goland/bookings/internal/drivers"
+	"github.com/chenemiken/goland/bookings/internal/repository"
+	"github.com/chenemiken/goland/bookings/internal/repository/dbrepo"
+)
+
+// command is one thing bookingsctl can do, like "users create".
+type command struct {
+	name  string
+	args  string
+	usage string
+	run   func(c *ctl, args []string) error
+}
+
+var commands = []command{
+	{"users list", "", "list the users", usersList},
+	{"users create", "-email EMAIL [-first NAME] [-last NAME] [-access LEVEL] [-password PASSWORD] [-property ID]",
+		"add a user; a password is generated when none is given", usersCreate},
+	{"users disable", "EMAIL", "stop a user from logging in and log them out", usersDisable},
+	{"users enable", "EMAIL", "let a disabled user log in again", usersEnable},
+	{"users reset-password", "[-password PASSWORD] EMAIL",
+		"set a new password; one is generated when none is given", usersResetPassword},
+	{"users sign-out", "EMAIL", "log a user out of every session", usersSignOut},
+	{"reservations list", "[-property ID] [-new]", "list the reservations of a property", reservationsList},
+	{"reservations search", "[-property ID] TEXT",
+		"find reservations by guest name, email, phone or id", reservationsSearch},
+	{"rooms list", "[-property ID]", "list the rooms of a property", roomsList},
+	{"blocks list", "-room ID [-from DATE] [-to DATE]", "list the owner blocks of a room", blocksList},
+	{"blocks add", "-room ID -from DATE [-nights N]", "block a room for some nights", blocksAdd},
+	{"blocks remove", "-room ID -from DATE [-nights N]", "unblock a room for some nights", blocksRemove},
+	{"seed", "[-property ID] [-room NAME]...",
+		"add the restrictions the app relies on, and rooms missing from the property", seed},
+}
+
+// ctl is what the commands work with.
+type ctl struct {
+	db       repository.DatabaseRepo
+	sessions sessionStore
+	out      io.Writer
+}
+
+// sessionStore is the part of the server's session store bookingsctl uses.
+type sessionStore interface {
+	DeleteUserSessions(userID int) (int, error)
+}
+
+var errUsage = errors.New("unknown command")
+
+func main() {
+	err := run(os.Args[1:], os.Getenv, os.Stdout)
+	if errors.Is(err, errUsage) {
+		printUsage(os.Stderr)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bookingsctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, getenv func(string) string, out io.Writer) error {
+	settings, err := config.Load(args, getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		printUsage(out)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cmd, rest, ok := lookup(settings.Args)
+	if !ok {
+		return errUsage
+	}
+
+	if settings.Database.Driver == "memory" {
+		return fmt.Errorf("database.driver is memory, changes would be lost when bookingsctl exits")
+	}
+
+	db, err := drivers.ConnectSQL(settings.Database.Driver, settings.Database.DSN())
+	if err != nil {
+		return fmt.Errorf("cannot connect to the database: %w", err)
+	}
+	defer db.SQL.Close()
+
+	app := &config.AppConfig{InProduction: settings.Production, BaseURL: settings.BaseURL}
+	c := &ctl{
+		db:       dbrepo.NewSQLRepo(db.Driver, db.SQL, app),
+		sessions: dbrepo.NewSessionStore(db.Driver, db.SQL),
+		out:      out,
+	}
+	return cmd.run(c, rest)
+}
+
+// lookup finds the command named by the first words of args and returns it
+// with the arguments that follow.
+func lookup(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):], true
+		}
+	}
+	return command{}, nil, false
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: bookingsctl [settings flags] <command> [command flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s %s\n        %s\n", cmd.name, cmd.args, cmd.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "The settings flags, config file and environment are the server's; run")
+	fmt.Fprintln(w, "bookingsctl -h for the flags.")
+}
+
+// flags returns a flag set for the command name whose errors and usage go
+// to the command output.
+func (c *ctl) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.out)
+	return fs
+}
+
+// table writes rows aligned in columns, the first row being the header.
+func (c *ctl) table(rows [][]string) {
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+}
+
+// oneArg returns the single positional argument of a command.
+func oneArg(fs *flag.FlagSet, what string) (string, error) {
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("%s: expected %s", fs.Name(), what)
+	}
+	return fs.Arg(0), nil
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[int]string) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
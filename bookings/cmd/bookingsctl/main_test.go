@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chenemiken/goland/bookings/internal/repository/dbrepo"
+)
+
+func TestLookup(t *testing.T) {
+	var tests = []struct {
+		args         []string
+		expectedName string
+		expectedRest []string
+	}{
+		{[]string{"users", "create", "-email", "a@b.co"}, "users create", []string{"-email", "a@b.co"}},
+		{[]string{"seed"}, "seed", []string{}},
+		{[]string{"blocks", "remove", "-room", "1"}, "blocks remove", []string{"-room", "1"}},
+		{[]string{"users"}, "", nil},
+		{[]string{"users", "delete"}, "", nil},
+		{nil, "", nil},
+	}
+
+	for _, e := range tests {
+		cmd, rest, ok := lookup(e.args)
+		if ok != (e.expectedName != "") || cmd.name != e.expectedName {
+			t.Errorf("%q: expected command %q but got %q", e.args, e.expectedName, cmd.name)
+			continue
+		}
+		if strings.Join(rest, " ") != strings.Join(e.expectedRest, " ") {
+			t.Errorf("%q: expected args %q but got %q", e.args, e.expectedRest, rest)
+		}
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	env := map[string]string{"BOOKINGS_DATABASE_NAME": "bookings", "BOOKINGS_DATABASE_USER": "u"}
+	getenv := func(key string) string { return env[key] }
+
+	err := run([]string{"-port", "9000", "users", "delete"}, getenv, &bytes.Buffer{})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("expected the usage error but got %v", err)
+	}
+
+	err = run([]string{"-database.driver", "memory", "users", "list"}, getenv, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "changes would be lost") {
+		t.Errorf("expected an error for the memory driver but got %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := run([]string{"-h"}, getenv, &out); err != nil {
+		t.Errorf("expected help to succeed but got %v", err)
+	}
+	if !strings.Contains(out.String(), "users reset-password") {
+		t.Errorf("expected the commands in the help but got %q", out.String())
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	var tests = []struct {
+		name     string
+		run      func(c *ctl, args []string) error
+		args     []string
+		expected string
+	}{
+		{"bad email", usersCreate, []string{"-email", "nobody"}, "-email must be an email address"},
+		{"bad access", usersCreate, []string{"-email", "a@b.co", "-access", "0"}, "-access must be 1 or more"},
+		{"no email", usersDisable, nil, "expected the email of the user"},
+		{"sign out no email", usersSignOut, nil, "expected the email of the user"},
+		{"short password", usersResetPassword, []string{"-password", "short", "a@b.co"}, "at least 8 characters"},
+		{"no text", reservationsSearch, nil, "expected the text to search for"},
+		{"no from", blocksAdd, []string{"-room", "1"}, "-from is required"},
+		{"bad from", blocksAdd, []string{"-room", "1", "-from", "tomorrow"}, "-from must be a date"},
+		{"no nights", blocksRemove, []string{"-room", "1", "-from", "2050-01-01", "-nights", "0"}, "-nights must be 1 or more"},
+		{"unknown room", blocksAdd, []string{"-room", "99", "-from", "2050-01-01"}, "no room 99"},
+		{"unknown flag", seed, []string{"-rooms", "a"}, "flag provided but not defined"},
+	}
+
+	for _, e := range tests {
+		c := &ctl{db: dbrepo.NewTestingRepo(nil), out: &bytes.Buffer{}}
+		err := e.run(c, e.args)
+		if err == nil || !strings.Contains(err.Error(), e.expected) {
+			t.Errorf("%s: expected %q but got %v", e.name, e.expected, err)
+		}
+	}
+}
+
+// fakeSessions counts the sessions of each user.
+type fakeSessions map[int]int
+
+func (f fakeSessions) DeleteUserSessions(userID int) (int, error) {
+	n := f[userID]
+	delete(f, userID)
+	return n, nil
+}
+
+func TestSignOut(t *testing.T) {
+	var tests = []struct {
+		name     string
+		run      func(c *ctl, args []string) error
+		expected string
+	}{
+		{"sign out", usersSignOut, "ended 2 sessions"},
+		{"disable", usersDisable, "and ended 2 sessions"},
+		{"enable", usersEnable, "enabled"},
+	}
+
+	for _, e := range tests {
+		var out bytes.Buffer
+		sessions := fakeSessions{0: 2}
+		c := &ctl{db: dbrepo.NewTestingRepo(nil), sessions: sessions, out: &out}
+		if err := e.run(c, []string{"a@b.co"}); err != nil {
+			t.Errorf("%s: %v", e.name, err)
+			continue
+		}
+		if !strings.Contains(out.String(), e.expected) {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, out.String())
+		}
+		if e.name == "enable" && len(sessions) != 1 {
+			t.Errorf("expected enabling a user to keep their sessions")
+		}
+	}
+}
+
+func TestChoosePassword(t *testing.T) {
+	password, generated, err := choosePassword("")
+	if err != nil || !generated || len(password) < minPasswordLength {
+		t.Errorf("expected a generated password but got %q %v %v", password, generated, err)
+	}
+
+	password, generated, err = choosePassword("correct horse")
+	if err != nil || generated || password != "correct horse" {
+		t.Errorf("expected the password given but got %q %v %v", password, generated, err)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/dates"
+	"github.com/chenemiken/goland/bookings/internal/models"
+)
+
+func reservationsList(c *ctl, args []string) error {
+	fs := c.flags("reservations list")
+	property := fs.Int("property", 1, "property id")
+	onlyNew := fs.Bool("new", false, "only list reservations not processed yet")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var reservations []models.Reservation
+	var err error
+	if *onlyNew {
+		reservations, err = c.db.AllNewReservations(*property)
+	} else {
+		reservations, err = c.db.AllReservations(*property)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.reservationTable(reservations)
+	return nil
+}
+
+func reservationsSearch(c *ctl, args []string) error {
+	fs := c.flags("reservations search")
+	property := fs.Int("property", 1, "property id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	text, err := oneArg(fs, "the text to search for")
+	if err != nil {
+		return err
+	}
+
+	reservations, err := c.db.SearchReservations(*property, text)
+	if err != nil {
+		return err
+	}
+
+	c.reservationTable(reservations)
+	return nil
+}
+
+func (c *ctl) reservationTable(reservations []models.Reservation) {
+	rows := [][]string{{"ID", "GUEST", "EMAIL", "PHONE", "ROOM", "ARRIVAL", "DEPARTURE", "STATUS"}}
+	for _, r := range reservations {
+		status := "new"
+		if r.Processed == 1 {
+			status = "processed"
+		}
+		rows = append(rows, []string{strconv.Itoa(r.ID), r.FirstName + " " + r.LastName,
+			r.Email, r.Phone, r.Room.RoomName, r.StartDate.Format("2006-01-02"),
+			r.EndDate.Format("2006-01-02"), status})
+	}
+	c.table(rows)
+}
+
+func roomsList(c *ctl, args []string) error {
+	fs := c.flags("rooms list")
+	property := fs.Int("property", 1, "property id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rooms, err := c.db.AllRooms(*property)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{{"ID", "NAME", "SLEEPS", "BEDS"}}
+	for _, r := range rooms {
+		rows = append(rows, []string{strconv.Itoa(r.ID), r.RoomName,
+			strconv.Itoa(r.MaxOccupancy), r.BedConfiguration})
+	}
+	c.table(rows)
+	return nil
+}
+
+// blockFlags are the room and nights the blocks commands act on.
+type blockFlags struct {
+	room   *int
+	from   *string
+	nights *int
+}
+
+func newBlockFlags(fs *flag.FlagSet, nights int) blockFlags {
+	return blockFlags{
+		room:   fs.Int("room", 0, "room id"),
+		from:   fs.String("from", "", "first night, as 2006-01-02"),
+		nights: fs.Int("nights", nights, "number of nights"),
+	}
+}
+
+// parse returns the room and the nights from start to end, end excluded.
+// -from is required unless defaultFrom is set, when it defaults to today at
+// the property of the room.
+func (b blockFlags) parse(c *ctl, name string, defaultFrom bool) (models.Room, time.Time, time.Time, error) {
+	var start time.Time
+
+	room, err := c.db.GetRoomById(*b.room)
+	if err != nil {
+		return room, start, start, fmt.Errorf("%s: no room %d", name, *b.room)
+	}
+
+	switch {
+	case *b.from != "":
+		start, err = dates.Parse(*b.from)
+		if err != nil {
+			return room, start, start, fmt.Errorf("%s: -from must be a date like 2006-01-02", name)
+		}
+	case defaultFrom:
+		property, err := c.db.GetPropertyByID(room.PropertyID)
+		if err != nil {
+			return room, start, start, err
+		}
+		start = property.Today()
+	default:
+		return room, start, start, fmt.Errorf("%s: -from is required", name)
+	}
+
+	if *b.nights < 1 {
+		return room, start, start, fmt.Errorf("%s: -nights must be 1 or more", name)
+	}
+	return room, start, start.AddDate(0, 0, *b.nights), nil
+}
+
+// ownerBlocks returns the owner blocks of a room overlapping start to end.
+func (c *ctl) ownerBlocks(roomID int, start, end time.Time) ([]models.RoomRestrictions, error) {
+	restrictions, err := c.db.GetRestrictionForRoomByDate(roomID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []models.RoomRestrictions
+	for _, r := range restrictions {
+		if r.RestrictionID == models.RestrictionOwnerBlock && dates.Overlap(r.StartDate, r.EndDate, start, end) {
+			blocks = append(blocks, r)
+		}
+	}
+	return blocks, nil
+}
+
+func blocksList(c *ctl, args []string) error {
+	fs := c.flags("blocks list")
+	b := newBlockFlags(fs, 365)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	room, start, end, err := b.parse(c, fs.Name(), true)
+	if err != nil {
+		return err
+	}
+
+	blocks, err := c.ownerBlocks(room.ID, start, end)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{{"ID", "NIGHT"}}
+	for _, block := range blocks {
+		rows = append(rows, []string{strconv.Itoa(block.ID), block.StartDate.Format("2006-01-02")})
+	}
+	c.table(rows)
+	return nil
+}
+
+func blocksAdd(c *ctl, args []string) error {
+	fs := c.flags("blocks add")
+	b := newBlockFlags(fs, 1)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	room, start, end, err := b.parse(c, fs.Name(), false)
+	if err != nil {
+		return err
+	}
+
+	for night := start; night.Before(end); night = night.AddDate(0, 0, 1) {
+		free, err := c.db.SearchAvailabilityByDatesByRoomId(night, night.AddDate(0, 0, 1), room.ID)
+		if err != nil {
+			return err
+		}
+		if !free {
+			fmt.Fprintf(c.out, "%s is already taken\n", night.Format("2006-01-02"))
+			continue
+		}
+
+		if err := c.db.InsertBlockForRoom(room.ID, night); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "blocked %s\n", night.Format("2006-01-02"))
+	}
+	return nil
+}
+
+func blocksRemove(c *ctl, args []string) error {
+	fs := c.flags("blocks remove")
+	b := newBlockFlags(fs, 1)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	room, start, end, err := b.parse(c, fs.Name(), false)
+	if err != nil {
+		return err
+	}
+
+	blocks, err := c.ownerBlocks(room.ID, start, end)
+	if err != nil {
+		return err
+	}
+	if len(blocks) == 0 {
+		fmt.Fprintln(c.out, "no blocks on those nights")
+		return nil
+	}
+
+	for _, block := range blocks {
+		if err := c.db.DeleteBlockByID(block.ID, room.ID); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "unblocked %s\n", block.StartDate.Format("2006-01-02"))
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chenemiken/goland/bookings/internal/models"
+)
+
+// restrictions are the restrictions and stay rules the app refers to by id.
+var restrictions = map[int]string{
+	models.RestrictionReservation:  "reservation",
+	models.RestrictionOwnerBlock:   "owner_block",
+	models.RuleMinStay:             "min_stay",
+	models.RuleMaxStay:             "max_stay",
+	models.RuleClosedToArrival:     "closed_to_arrival",
+	models.RuleClosedToDeparture:   "closed_to_departure",
+	models.RuleWeekdayArrivalOnly:  "weekday_arrival_only",
+	models.RestrictionWaitlistHold: "Waitlist hold",
+}
+
+// roomNames collects the repeated -room flag.
+type roomNames []string
+
+func (r *roomNames) String() string {
+	return strings.Join(*r, ", ")
+}
+
+func (r *roomNames) Set(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("the room name is empty")
+	}
+	*r = append(*r, strings.TrimSpace(name))
+	return nil
+}
+
+// seed adds what a fresh database needs and the migrations may not have:
+// the restrictions, and the rooms named with -room that the property does
+// not have yet. Running it again changes nothing.
+func seed(c *ctl, args []string) error {
+	fs := c.flags("seed")
+	property := fs.Int("property", 1, "property the rooms belong to")
+	occupancy := fs.Int("sleeps", 2, "how many guests the new rooms sleep")
+	var names roomNames
+	fs.Var(&names, "room", "name of a room to add; repeat for more rooms")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	existing, err := c.db.AllRestrictions()
+	if err != nil {
+		return err
+	}
+	have := map[int]bool{}
+	for _, r := range existing {
+		have[r.ID] = true
+	}
+	for _, id := range sortedKeys(restrictions) {
+		if have[id] {
+			continue
+		}
+		err := c.db.InsertRestriction(models.Restriction{ID: id, RestrictionName: restrictions[id]})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "added restriction %d %s\n", id, restrictions[id])
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+	if _, err := c.db.GetPropertyByID(*property); err != nil {
+		return fmt.Errorf("seed: no property %d", *property)
+	}
+	rooms, err := c.db.AllRooms(*property)
+	if err != nil {
+		return err
+	}
+	haveRoom := map[string]bool{}
+	for _, r := range rooms {
+		haveRoom[strings.ToLower(r.RoomName)] = true
+	}
+
+	for _, name := range names {
+		if haveRoom[strings.ToLower(name)] {
+			continue
+		}
+		id, err := c.db.InsertRoom(models.Room{
+			RoomName:     name,
+			PropertyID:   *property,
+			MaxOccupancy: *occupancy,
+		})
+		if err != nil {
+			return err
+		}
+		haveRoom[strings.ToLower(name)] = true
+		fmt.Fprintf(c.out, "added room %d %s\n", id, name)
+	}
+	return nil
+}
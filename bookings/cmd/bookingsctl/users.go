@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/chenemiken/goland/bookings/internal/models"
+)
+
+// minPasswordLength is the shortest password accepted.
+const minPasswordLength = 8
+
+func usersList(c *ctl, args []string) error {
+	fs := c.flags("users list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	users, err := c.db.AllUsers()
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{{"ID", "EMAIL", "NAME", "ACCESS", "STATUS"}}
+	for _, u := range users {
+		status := "active"
+		if u.Disabled() {
+			status = "disabled " + u.DisabledAt.Format("2006-01-02")
+		}
+		rows = append(rows, []string{strconv.Itoa(u.ID), u.Email,
+			u.FirstName + " " + u.LastName, strconv.Itoa(u.AccessLevel), status})
+	}
+	c.table(rows)
+	return nil
+}
+
+func usersCreate(c *ctl, args []string) error {
+	fs := c.flags("users create")
+	email := fs.String("email", "", "email the user logs in with")
+	first := fs.String("first", "", "first name")
+	last := fs.String("last", "", "last name")
+	access := fs.Int("access", 1, "access level; administrators, 3, manage every property")
+	password := fs.String("password", "", "password; generated when empty")
+	property := fs.Int("property", 0, "property the user manages; none when 0")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !govalidator.IsEmail(*email) {
+		return fmt.Errorf("users create: -email must be an email address")
+	}
+	if *access < 1 {
+		return fmt.Errorf("users create: -access must be 1 or more")
+	}
+	if _, err := c.db.GetUserByEmail(*email); err == nil {
+		return fmt.Errorf("users create: %s already has an account", *email)
+	}
+	if *property > 0 {
+		if _, err := c.db.GetPropertyByID(*property); err != nil {
+			return fmt.Errorf("users create: no property %d", *property)
+		}
+	}
+	pass, generated, err := choosePassword(*password)
+	if err != nil {
+		return err
+	}
+
+	id, err := c.db.InsertUser(models.User{
+		FirstName:   *first,
+		LastName:    *last,
+		Email:       *email,
+		Password:    pass,
+		AccessLevel: *access,
+	})
+	if err != nil {
+		return err
+	}
+	if *property > 0 {
+		if err := c.db.AssignUserToProperty(id, *property); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(c.out, "created user %d, %s\n", id, *email)
+	if generated {
+		fmt.Fprintf(c.out, "password: %s\n", pass)
+	}
+	return nil
+}
+
+func usersDisable(c *ctl, args []string) error {
+	return setDisabled(c, "users disable", args, true)
+}
+
+func usersEnable(c *ctl, args []string) error {
+	return setDisabled(c, "users enable", args, false)
+}
+
+func setDisabled(c *ctl, name string, args []string, disabled bool) error {
+	fs := c.flags(name)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	u, err := userArg(c, fs.Name(), fs.Args())
+	if err != nil {
+		return err
+	}
+
+	if err := c.db.SetUserDisabled(u.ID, disabled); err != nil {
+		return err
+	}
+
+	if !disabled {
+		fmt.Fprintf(c.out, "enabled %s\n", u.Email)
+		return nil
+	}
+
+	// disabling only stops new logins, so the sessions are ended too
+	n, err := c.sessions.DeleteUserSessions(u.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "disabled %s and ended %d sessions\n", u.Email, n)
+	return nil
+}
+
+func usersSignOut(c *ctl, args []string) error {
+	fs := c.flags("users sign-out")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	u, err := userArg(c, fs.Name(), fs.Args())
+	if err != nil {
+		return err
+	}
+
+	n, err := c.sessions.DeleteUserSessions(u.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "ended %d sessions of %s\n", n, u.Email)
+	return nil
+}
+
+func usersResetPassword(c *ctl, args []string) error {
+	fs := c.flags("users reset-password")
+	password := fs.String("password", "", "new password; generated when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	u, err := userArg(c, fs.Name(), fs.Args())
+	if err != nil {
+		return err
+	}
+	pass, generated, err := choosePassword(*password)
+	if err != nil {
+		return err
+	}
+
+	if err := c.db.UpdatePassword(u.ID, pass); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "reset the password of %s\n", u.Email)
+	if generated {
+		fmt.Fprintf(c.out, "password: %s\n", pass)
+	}
+	return nil
+}
+
+// userArg finds the user whose email is the only argument.
+func userArg(c *ctl, name string, args []string) (models.User, error) {
+	if len(args) != 1 {
+		return models.User{}, fmt.Errorf("%s: expected the email of the user", name)
+	}
+	u, err := c.db.GetUserByEmail(args[0])
+	if err != nil {
+		return u, fmt.Errorf("%s: no user %s", name, args[0])
+	}
+	return u, nil
+}
+
+// choosePassword checks the password given, or generates one when it is
+// empty.
+func choosePassword(password string) (string, bool, error) {
+	if password != "" {
+		if len(password) < minPasswordLength {
+			return "", false, fmt.Errorf("the password must be at least %d characters", minPasswordLength)
+		}
+		return password, false, nil
+	}
+
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", false, err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), true, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/drivers"
+)
+
+// mailQueueSize is how much mail can wait on app.MailChan without blocking
+// the request that sends it; past mailQueueLimit the app reports itself as
+// not ready, as mail is backing up.
+const mailQueueSize = 100
+const mailQueueLimit = 75
+
+// dbPingTimeout bounds the database check of /readyz.
+const dbPingTimeout = 2 * time.Second
+
+// dbConn is the database the readiness check and metrics report on.
+var dbConn *drivers.DB
+
+// inMemory is set when the data is kept in memory, so there is no database
+// to check.
+var inMemory bool
+
+// Healthz tells that the process is up and serving.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// Readyz tells whether the app can serve guests: the database answers, the
+// templates are loaded and the mail queue is not backing up. It answers 503
+// otherwise. The endpoint is public, so it only tells which checks failed;
+// why is logged.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	var lines []string
+	ready := true
+	check := func(name string, err error) {
+		if err != nil {
+			ready = false
+			app.Logger.WarnContext(r.Context(), "readiness check failed",
+				"check", name, "err", err)
+			lines = append(lines, name+": fail")
+			return
+		}
+		lines = append(lines, name+": ok")
+	}
+
+	check("database", pingDB(r.Context()))
+
+	var err error
+	if len(app.TemplateCache) == 0 {
+		err = fmt.Errorf("no templates loaded")
+	}
+	check("templates", err)
+
+	err = nil
+	if queued := len(app.MailChan); queued >= mailQueueLimit {
+		err = fmt.Errorf("%d emails waiting", queued)
+	}
+	check("mail queue", err)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprintln(w, strings.Join(lines, "\n"))
+}
+
+func pingDB(ctx context.Context) error {
+	if inMemory {
+		return nil
+	}
+	if dbConn == nil || dbConn.SQL == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbPingTimeout)
+	defer cancel()
+	return dbConn.SQL.PingContext(ctx)
+}
+
+// dbSQL returns the connection pool for the metrics, if there is one.
+func dbSQL() *sql.DB {
+	if dbConn == nil {
+		return nil
+	}
+	return dbConn.SQL
+}
+
+func mailQueueLength() int {
+	return len(app.MailChan)
+}
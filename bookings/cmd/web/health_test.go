@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/logging"
+	"github.com/chenemiken/goland/bookings/internal/metrics"
+	"github.com/chenemiken/goland/bookings/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestHealthz(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Healthz(rr, httptest.NewRequest("GET", "/healthz", nil))
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "ok\n" {
+		t.Errorf("expected ok but got %d %q", rr.Code, rr.Body)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	saved := app.Logger
+	defer func() { app.Logger = saved }()
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "text", slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Logger = logger
+
+	dbConn = nil
+	app.TemplateCache = nil
+	app.MailChan = make(chan models.MailData, mailQueueSize)
+	for i := 0; i < mailQueueLimit; i++ {
+		app.MailChan <- models.MailData{}
+	}
+
+	rr := httptest.NewRecorder()
+	Readyz(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 but got %d", rr.Code)
+	}
+	for _, text := range []string{
+		"database: fail",
+		"templates: fail",
+		"mail queue: fail",
+	} {
+		if !strings.Contains(rr.Body.String(), text) {
+			t.Errorf("expected %q in %q", text, rr.Body)
+		}
+	}
+	if strings.Contains(rr.Body.String(), "not connected") {
+		t.Errorf("expected the reason of the failure to be kept out of %q", rr.Body)
+	}
+	if !strings.Contains(buf.String(), "check=database err=\"not connected\"") {
+		t.Errorf("expected the reason of the failure in the log but got %q", buf.String())
+	}
+}
+
+func TestReadyzInMemory(t *testing.T) {
+	dbConn = nil
+	inMemory = true
+	defer func() { inMemory = false }()
+
+	if err := pingDB(context.Background()); err != nil {
+		t.Errorf("expected the memory database to be ready but got %v", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	metrics.Reset()
+
+	mux := chi.NewRouter()
+	mux.Use(Metrics)
+	mux.Get("/rooms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+	})
+	mux.Get("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	for _, url := range []string{"/rooms/1", "/rooms/2", "/missing", "/nowhere"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", url, nil))
+	}
+	for _, method := range []string{"BREW", "PROPFIND"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/nowhere", nil))
+	}
+
+	rr := httptest.NewRecorder()
+	metrics.Handler(nil, nil).ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	for _, line := range []string{
+		`bookings_http_requests_total{method="GET",route="/rooms/{id}",status="200"} 2`,
+		`bookings_http_requests_total{method="GET",route="/missing",status="404"} 1`,
+		`bookings_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`bookings_http_requests_total{method="other",route="unmatched",status="405"} 2`,
+	} {
+		if !strings.Contains(rr.Body.String(), line) {
+			t.Errorf("expected %q in\n%s", line, rr.Body)
+		}
+	}
+	if strings.Contains(rr.Body.String(), "BREW") {
+		t.Errorf("expected unknown methods to be counted as other in\n%s", rr.Body)
+	}
+}
@@ -2,117 +2,181 @@ package main
 
 import (
 	"encoding/gob"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/chenemiken/goland/bookings"
 	"github.com/chenemiken/goland/bookings/helpers"
+	"github.com/chenemiken/goland/bookings/internal/assets"
 	"github.com/chenemiken/goland/bookings/internal/config"
 	"github.com/chenemiken/goland/bookings/internal/drivers"
 	"github.com/chenemiken/goland/bookings/internal/handlers"
+	"github.com/chenemiken/goland/bookings/internal/logging"
+	"github.com/chenemiken/goland/bookings/internal/migrate"
 	"github.com/chenemiken/goland/bookings/internal/models"
 	"github.com/chenemiken/goland/bookings/internal/render"
+	"github.com/chenemiken/goland/bookings/internal/repository/dbrepo"
 )
 
-const portNumber = ":8080"
+// templateWatchInterval is how often templates are checked for edits when
+// the template cache is off.
+const templateWatchInterval = 500 * time.Millisecond
 
 var app config.AppConfig
 var session scs.SessionManager
-var infoLog *log.Logger
-var errorLog *log.Logger
 
 func main() {
-	db, err := run()
-	if err != nil {
-		log.Fatal(err)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrateCommand(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
-	defer db.SQL.Close()
 
-	defer close(app.MailChan)
+	db, err := run(os.Args[1:], os.Getenv)
+	if err != nil {
+		logger := app.Logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.Error("could not start", "err", err)
+		os.Exit(1)
+	}
 
 	listenForMail()
+	if err := requeueSpooledMail(); err != nil {
+		app.Logger.Error("could not send mail saved at the last shutdown", "err", err)
+	}
+	listenForPurge()
+	listenForHoldExpiry()
+	listenForSessionCleanup()
+	if !app.UseCache {
+		render.WatchTemplates(templateWatchInterval)
+	}
 
-	fmt.Printf((fmt.Sprintf("Starting application on port %s \n", portNumber)))
-	// _ = http.ListenAndServe(portNumber, nil)
+	app.Logger.Info("starting application", "addr", app.Addr)
 
 	server := &http.Server{
-		Addr:    portNumber,
+		Addr:    app.Addr,
 		Handler: routes(&app),
 	}
 
-	err = server.ListenAndServe()
-	log.Fatal(err)
+	go func() {
+		err := server.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			app.Logger.Error("server stopped", "err", err)
+			os.Exit(1)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	shutdown(server, db)
 }
 
-func run() (*drivers.DB, error) {
+// run sets the app up from the command line args and the environment read
+// with getenv.
+func run(args []string, getenv func(string) string) (*drivers.DB, error) {
 	gob.Register(models.Reservation{})
 	gob.Register(models.User{})
 	gob.Register(models.Room{})
 	gob.Register(models.Restriction{})
 	gob.Register(map[string]int{})
 
-	inProduction := flag.Bool("production", true, "App is in production")
-	useCache := flag.Bool("cache", true, "App should use template cache")
-	dbHost := flag.String("dbhost", "localhost", "database host")
-	dbPort := flag.String("dbport", "5432", "database port")
-	dbName := flag.String("dbname", "", "database name")
-	dbUser := flag.String("dbuser", "", "database user")
-	dbPass := flag.String("dbpass", "", "database pass")
-	dbSSL := flag.String("dbssl", "disable",
-		"database ssl settings (disable, prefer, required)")
-
-	flag.Parse()
-
-	if *dbName == "" || *dbUser == "" {
-		fmt.Println("Missing database credential flags")
-		os.Exit(1)
+	settings, err := config.Load(args, getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if settings.PrintConfig {
+		settings.Print(os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	app.InProduction = *inProduction
-	app.UseCache = *useCache
-
-	infoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	app.InfoLog = infoLog
-
-	errorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	app.ErrorLog = errorLog
+	app.InProduction = settings.Production
+	app.UseCache = settings.Cache
+	app.PurgeAfter = settings.PurgeAfter
+	app.HoldFor = settings.HoldFor
+	app.QueryCacheTTL = settings.QueryCacheTTL
+	app.ShutdownTimeout = settings.ShutdownTimeout
+	app.MailSpool = settings.MailSpool
+	app.BaseURL = settings.BaseURL
+	app.Addr = settings.Addr()
+	app.SMTP = settings.SMTP
+	app.Security = settings.Security
+
+	logger, err := logging.New(os.Stdout, settings.LogFormat, settings.Level())
+	if err != nil {
+		return nil, err
+	}
+	app.Logger = logger
 
 	session = *scs.New()
-	session.Lifetime = 24 * time.Hour
+	session.Lifetime = settings.Session.Lifetime
+	session.IdleTimeout = settings.Session.IdleTimeout
 	session.Cookie.Persist = true
 	session.Cookie.SameSite = http.SameSiteLaxMode
 	session.Cookie.Secure = app.InProduction
 
-	tc, err := render.CreateTemplateCache()
+	// in development the files are read from the working directory when run
+	// from the repo, so edits show up without a rebuild
+	app.Templates = bookings.Dir("templates", !app.InProduction)
+	app.Static = bookings.Dir("static", !app.InProduction)
+
+	manifest, err := assets.New(app.Static, "/static", !app.UseCache)
 	if err != nil {
-		log.Fatal("cannot create template cache")
-		return nil, err
+		return nil, fmt.Errorf("cannot fingerprint static files: %w", err)
 	}
+	app.Assets = manifest
 
-	app.TemplateCache = tc
-	app.Session = &session
+	render.NewRenderer(&app)
 
-	connectionString := fmt.Sprintf("host=%s port=%s dbname=%s user=%s "+
-		"password=%s sslmode=%s", *dbHost, *dbPort, *dbName, *dbUser, *dbPass,
-		*dbSSL)
-	db, err := drivers.ConnectSQL(connectionString)
+	tc, err := render.CreateTemplateCache()
 	if err != nil {
-		log.Fatal("Can not connect to db... Dying.")
-		return nil, err
+		return nil, fmt.Errorf("cannot create template cache: %w", err)
 	}
-	app.InfoLog.Println("Connected to the database!")
 
-	render.NewRenderer(&app)
+	app.TemplateCache = tc
+	app.Session = &session
 
-	repo := handlers.NewRepo(db, &app)
+	var db *drivers.DB
+	var repo *handlers.Repository
+	if settings.Database.Driver == "memory" {
+		app.Logger.Warn("keeping the data in memory, it is lost when the app stops")
+		inMemory = true
+		repo = handlers.NewMemoryRepo(&app)
+	} else {
+		db, err = connectDB(settings.Database)
+		if err != nil {
+			return nil, err
+		}
+		repo = handlers.NewRepo(db, &app)
+		sessionStore = dbrepo.NewSessionStore(db.Driver, db.SQL)
+		session.Store = sessionStore
+		repo.Sessions = sessionStore
+	}
 	handlers.NewHandlers(repo)
 	helpers.NewHelpers(&app)
 
-	mailChan := make(chan models.MailData)
+	mailChan := make(chan models.MailData, mailQueueSize)
 	app.MailChan = mailChan
 
 	// http.HandleFunc("/", handlers.Repo.Home)
@@ -120,3 +184,28 @@ func run() (*drivers.DB, error) {
 
 	return db, nil
 }
+
+// connectDB connects to the database and checks the migrations are all
+// applied.
+func connectDB(settings config.DatabaseSettings) (*drivers.DB, error) {
+	db, err := drivers.ConnectSQL(settings.Driver, settings.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to the database: %w", err)
+	}
+	app.Logger.Info("connected to the database")
+	dbConn = db
+
+	migrator, err := migrate.New(db.SQL, migrate.Dialect(settings.Driver),
+		bookings.Dir("migrations", !app.InProduction))
+	if err != nil {
+		return nil, err
+	}
+	pending, err := migrator.Pending()
+	if err != nil {
+		return nil, fmt.Errorf("cannot check migrations: %w", err)
+	}
+	if len(pending) > 0 {
+		return nil, fmt.Errorf("%d migrations have not been applied, run bookings migrate up first", len(pending))
+	}
+	return db, nil
+}
@@ -3,8 +3,12 @@ package main
 import "testing"
 
 func TestRun(t *testing.T) {
-	_, err := run()
+	saved, savedInMemory := app, inMemory
+	defer func() { app, inMemory = saved, savedInMemory }()
+
+	getenv := func(string) string { return "" }
+	_, err := run([]string{"-database.driver=memory"}, getenv)
 	if err != nil {
-		t.Error("failed run()")
+		t.Errorf("failed run(): %v", err)
 	}
 }
@@ -1,17 +1,88 @@
 package main
 
 import (
-	"fmt"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/chenemiken/goland/bookings/helpers"
+	"github.com/chenemiken/goland/bookings/internal/handlers"
+	"github.com/chenemiken/goland/bookings/internal/i18n"
+	"github.com/chenemiken/goland/bookings/internal/logging"
+	"github.com/chenemiken/goland/bookings/internal/metrics"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/justinas/nosurf"
 )
 
-func WriteToConsole(next http.Handler) http.Handler {
+const requestIDHeader = "X-Request-ID"
+
+// RequestID gives every request an id, kept from the X-Request-ID header
+// when a proxy in front already set a sane one. The id is sent back in the
+// same header and carried in the context, so log records, error pages and
+// emails can all be traced to the request.
+func RequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("hey from middleware not agency-banking!")
-		next.ServeHTTP(w, r)
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID reports whether an incoming id is short and plain enough
+// to be trusted in logs and headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// AccessLog logs every request once it is served, with its status, the
+// bytes written and how long it took.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		app.Logger.InfoContext(r.Context(), "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"duration", time.Since(start),
+			"remote", r.RemoteAddr,
+		)
 	})
 }
 
@@ -24,6 +95,8 @@ func NoSurf(next http.Handler) http.Handler {
 		Secure:   app.InProduction,
 		SameSite: http.SameSiteLaxMode,
 	})
+	// browsers send violation reports without a token
+	csrfHandler.ExemptPath(cspReportPath)
 	return csrfHandler
 }
 
@@ -41,3 +114,155 @@ func Auth(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+const localeCookie = "lang"
+
+// Locale picks the language the visitor is served in. A locale prefix on the
+// URL, like /fr/search-availability, wins and is remembered in a cookie; then
+// comes the cookie; then the Accept-Language header. The prefix is stripped
+// before routing so every route works under every locale.
+func Locale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]
+
+		if i18n.IsSupported(prefix) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     localeCookie,
+				Value:    prefix,
+				Path:     "/",
+				MaxAge:   365 * 24 * 60 * 60,
+				HttpOnly: true,
+				Secure:   app.InProduction,
+				SameSite: http.SameSiteLaxMode,
+			})
+
+			r = helpers.WithLocale(r, prefix)
+			u := *r.URL
+			u.Path = stripLocale(u.Path, prefix)
+			u.RawPath = ""
+			r.URL = &u
+			r.RequestURI = stripLocale(r.RequestURI, prefix)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		locale := i18n.Match(r.Header.Get("Accept-Language"))
+		if c, err := r.Cookie(localeCookie); err == nil && i18n.IsSupported(c.Value) {
+			locale = c.Value
+		}
+
+		next.ServeHTTP(w, helpers.WithLocale(r, locale))
+	})
+}
+
+// stripLocale removes the /{locale} prefix from a path or request URI.
+func stripLocale(path, locale string) string {
+	path = strings.TrimPrefix(path, "/"+locale)
+	if path == "" || strings.HasPrefix(path, "?") {
+		path = "/" + path
+	}
+	return path
+}
+
+// PropertyFromHost picks the property whose host name matches the request,
+// falling back to the first property so single-property installs keep working.
+func PropertyFromHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		property, err := handlers.Repo.DB.GetPropertyByHost(host)
+		if err != nil {
+			properties, err := handlers.Repo.DB.AllProperties()
+			if err != nil {
+				helpers.ServerError(w, r, err)
+				return
+			}
+			if len(properties) == 0 {
+				helpers.ClientError(w, r, http.StatusNotFound)
+				return
+			}
+			property = properties[0]
+		}
+
+		next.ServeHTTP(w, helpers.WithProperty(r, property, ""))
+	})
+}
+
+// PropertyFromPath picks the property named by the {property} slug in the
+// URL, for sites served under /p/{property}.
+func PropertyFromPath(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "property")
+
+		property, err := handlers.Repo.DB.GetPropertyBySlug(slug)
+		if err != nil {
+			helpers.ClientError(w, r, http.StatusNotFound)
+			return
+		}
+
+		next.ServeHTTP(w, helpers.WithProperty(r, property, "/p/"+property.Slug))
+	})
+}
+
+// AdminProperty scopes the admin pages to the property chosen by the user,
+// or to the first property they are allowed to manage.
+func AdminProperty(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		properties, err := handlers.Repo.AdminProperties(r)
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+		if len(properties) == 0 {
+			helpers.ClientError(w, r, http.StatusForbidden)
+			return
+		}
+
+		property := properties[0]
+		id := session.GetInt(r.Context(), "property_id")
+		for _, p := range properties {
+			if p.ID == id {
+				property = p
+			}
+		}
+
+		next.ServeHTTP(w, helpers.WithProperty(r, property, ""))
+	})
+}
+
+// Metrics records the count and latency of requests by the route pattern
+// they matched.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.ObserveRequest(methodLabel(r.Method), route, status, time.Since(start))
+	})
+}
+
+// methodLabel returns the method a request is counted under. Clients can
+// send any method, so the ones net/http does not know are counted together
+// as other, to keep the number of series bounded.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodConnect,
+		http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
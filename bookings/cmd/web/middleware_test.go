@@ -1,8 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/chenemiken/goland/bookings/helpers"
+	"github.com/chenemiken/goland/bookings/internal/logging"
 )
 
 func TestNoSurf(t *testing.T) {
@@ -30,3 +38,125 @@ func TestSessionLoad(t *testing.T) {
 	}
 
 }
+
+func TestLocale(t *testing.T) {
+	var tests = []struct {
+		name           string
+		uri            string
+		cookie         string
+		acceptLanguage string
+		expectedLocale string
+		expectedURI    string
+	}{
+		{"default", "/about", "", "", "en", "/about"},
+		{"accept language", "/about", "", "es-MX,es;q=0.9,en;q=0.5", "es", "/about"},
+		{"cookie", "/about", "fr", "es", "fr", "/about"},
+		{"prefix", "/fr/choose-room/1?s=2050-01-02", "es", "es", "fr",
+			"/choose-room/1?s=2050-01-02"},
+		{"bare prefix", "/es", "", "", "es", "/"},
+		{"unsupported prefix", "/de/about", "", "", "en", "/de/about"},
+	}
+
+	for _, e := range tests {
+		var locale, uri string
+		h := Locale(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			locale = helpers.CurrentLocale(r)
+			uri = r.RequestURI
+		}))
+
+		req := httptest.NewRequest("GET", e.uri, nil)
+		if e.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: localeCookie, Value: e.cookie})
+		}
+		req.Header.Set("Accept-Language", e.acceptLanguage)
+
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if locale != e.expectedLocale {
+			t.Errorf("%s: expected locale %q but got %q", e.name, e.expectedLocale, locale)
+		}
+		if uri != e.expectedURI {
+			t.Errorf("%s: expected request URI %q but got %q", e.name, e.expectedURI, uri)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var tests = []struct {
+		name     string
+		incoming string
+		kept     bool
+	}{
+		{"none", "", false},
+		{"from proxy", "edge-42.a_b", true},
+		{"too long", strings.Repeat("a", 65), false},
+		{"unsafe", "id\nforged: yes", false},
+	}
+
+	for _, e := range tests {
+		var inContext string
+		h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inContext = helpers.RequestID(r)
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		if e.incoming != "" {
+			req.Header.Set("X-Request-ID", e.incoming)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		id := rr.Header().Get("X-Request-ID")
+		if id == "" || id != inContext {
+			t.Errorf("%s: expected the response header %q to match the context %q", e.name, id, inContext)
+		}
+		if e.kept && id != e.incoming {
+			t.Errorf("%s: expected the incoming id %q to be kept but got %q", e.name, e.incoming, id)
+		}
+		if !e.kept && (id == e.incoming || len(id) != 32) {
+			t.Errorf("%s: expected a new id but got %q", e.name, id)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := app.Logger
+	app.Logger = logger
+	defer func() { app.Logger = saved }()
+
+	h := RequestID(AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})))
+
+	req := httptest.NewRequest("POST", "/make-reservation", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected one JSON record but got %q: %s", buf.String(), err)
+	}
+
+	expected := map[string]any{
+		"msg":        "request",
+		"method":     "POST",
+		"path":       "/make-reservation",
+		"status":     float64(http.StatusTeapot),
+		"bytes":      float64(len("short and stout")),
+		"request_id": "abc-123",
+	}
+	for k, v := range expected {
+		if record[k] != v {
+			t.Errorf("expected %s to be %v but got %v", k, v, record[k])
+		}
+	}
+	if _, ok := record["duration"]; !ok {
+		t.Error("expected the duration to be logged")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/chenemiken/goland/bookings"
+	"github.com/chenemiken/goland/bookings/internal/config"
+	"github.com/chenemiken/goland/bookings/internal/drivers"
+	"github.com/chenemiken/goland/bookings/internal/migrate"
+)
+
+var errMigrateUsage = errors.New("usage: bookings migrate up|down|status|redo [flags]")
+
+// migrateCommand runs `bookings migrate <action>`. It takes the same flags,
+// config file and environment as the server to find the database.
+func migrateCommand(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+	action := args[0]
+	switch action {
+	case "up", "down", "status", "redo":
+	default:
+		return errMigrateUsage
+	}
+
+	settings, err := config.Load(args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if settings.Database.Driver == "memory" {
+		return fmt.Errorf("database.driver is memory, there is no database to migrate")
+	}
+
+	db, err := drivers.ConnectSQL(settings.Database.Driver, settings.Database.DSN())
+	if err != nil {
+		return fmt.Errorf("cannot connect to the database: %w", err)
+	}
+	defer db.SQL.Close()
+
+	migrator, err := migrate.New(db.SQL, migrate.Dialect(settings.Database.Driver),
+		bookings.Dir("migrations", !settings.Production))
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			fmt.Fprintf(out, "applied %s %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "the database is up to date")
+		}
+
+	case "down":
+		m, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "reverted %s %s\n", m.Version, m.Name)
+
+	case "redo":
+		m, err := migrator.Redo()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "redid %s %s\n", m.Version, m.Name)
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		writeStatus(out, statuses)
+	}
+
+	return nil
+}
+
+func writeStatus(out io.Writer, statuses []migrate.Status) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS")
+	for _, s := range statuses {
+		status := "pending"
+		if s.Applied {
+			status = "applied"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Version, s.Name, status)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chenemiken/goland/bookings/internal/migrate"
+)
+
+func TestMigrateCommandUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"sideways"}, {"-dbname", "b", "up"}} {
+		if err := migrateCommand(args, &bytes.Buffer{}); !errors.Is(err, errMigrateUsage) {
+			t.Errorf("%q: expected the usage but got %v", args, err)
+		}
+	}
+}
+
+func TestMigrateCommandMemory(t *testing.T) {
+	err := migrateCommand([]string{"status", "-database.driver", "memory"}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "no database to migrate") {
+		t.Errorf("expected an error for the memory driver but got %v", err)
+	}
+}
+
+func TestWriteStatus(t *testing.T) {
+	var buf bytes.Buffer
+	writeStatus(&buf, []migrate.Status{
+		{Migration: migrate.Migration{Version: "20231217091113", Name: "create_user_table"}, Applied: true},
+		{Migration: migrate.Migration{Version: "20240302090000", Name: "add_locale"}},
+	})
+
+	expected := "VERSION         NAME               STATUS\n" +
+		"20231217091113  create_user_table  applied\n" +
+		"20240302090000  add_locale         pending\n"
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/handlers"
+)
+
+const purgeInterval = time.Hour
+
+// listenForPurge periodically removes reservations that have been in the
+// trash for longer than app.PurgeAfter.
+func listenForPurge() {
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			purgeTrash()
+			select {
+			case <-ticker.C:
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+func purgeTrash() {
+	before := time.Now().Add(-app.PurgeAfter)
+
+	purged, err := handlers.Repo.DB.PurgeDeletedReservations(before)
+	if err != nil {
+		app.Logger.Error("could not purge the trash", "err", err)
+		return
+	}
+
+	if purged > 0 {
+		app.Logger.Info("purged reservations from the trash", "reservations", purged)
+	}
+}
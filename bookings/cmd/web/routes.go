@@ -3,8 +3,10 @@ package main
 import (
 	"net/http"
 
+	"github.com/chenemiken/goland/bookings/helpers"
 	"github.com/chenemiken/goland/bookings/internal/config"
 	"github.com/chenemiken/goland/bookings/internal/handlers"
+	"github.com/chenemiken/goland/bookings/internal/metrics"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -12,45 +14,89 @@ import (
 func routes(app *config.AppConfig) http.Handler {
 	mux := chi.NewRouter()
 
+	mux.Use(RequestID)
+	mux.Use(AccessLog)
+	mux.Use(Metrics)
+	mux.Use(SecurityHeaders)
 	mux.Use(middleware.Recoverer)
+	mux.Use(Locale)
 	mux.Use(NoSurf)
 	mux.Use(SessionLoad)
 
-	mux.Get("/", handlers.Repo.Home)
-	mux.Get("/about", handlers.Repo.About)
-	mux.Get("/generals-quarters", handlers.Repo.Generals)
-	mux.Get("/majors-suite", handlers.Repo.Majors)
-	mux.Get("/search-availability", handlers.Repo.Availability)
-	mux.Post("/search-availability", handlers.Repo.PostAvailability)
-	mux.Post("/search-availability-json", handlers.Repo.PostAvailabilityJson)
+	mux.Get("/healthz", Healthz)
+	mux.Get("/readyz", Readyz)
+	mux.Handle("/metrics", metrics.Handler(dbSQL(), mailQueueLength))
+	mux.Post(cspReportPath, CSPReport)
 
-	mux.Get("/make-reservation", handlers.Repo.Reservation)
-	mux.Post("/make-reservation", handlers.Repo.PostReservation)
-	mux.Get("/reservation-summary", handlers.Repo.ReservationSummary)
-	mux.Get("/choose-room/{id}", handlers.Repo.ChooseRoom)
-	mux.Get("/book-room", handlers.Repo.BookRoom)
+	mux.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		helpers.ClientError(w, r, http.StatusNotFound)
+	})
+
+	// the public site is served per property, either on the property's own
+	// host name or under /p/{property}
+	mux.Group(func(mux chi.Router) {
+		mux.Use(PropertyFromHost)
+		publicRoutes(mux)
+	})
+	mux.Route("/p/{property}", func(mux chi.Router) {
+		mux.Use(PropertyFromPath)
+		publicRoutes(mux)
+	})
 
-	mux.Get("/contact", handlers.Repo.Contact)
 	mux.Get("/users/login", handlers.Repo.ShowLogin)
 	mux.Post("/users/login", handlers.Repo.PostLogin)
 	mux.Get("/users/logout", handlers.Repo.Logout)
 	mux.Route("/admin", func(mux chi.Router) {
 		// mux.Use(Auth)
+		mux.Use(AdminProperty)
 
 		mux.Get("/dashboard", handlers.Repo.AdminDashboard)
+		mux.Get("/properties", handlers.Repo.AdminAllProperties)
+		mux.Post("/properties/{id}", handlers.Repo.AdminPostProperty)
+		mux.Get("/switch-property/{id}", handlers.Repo.AdminSwitchProperty)
+		mux.Get("/users", handlers.Repo.AdminUsers)
+		mux.Post("/users/{id}/sign-out", handlers.Repo.AdminSignOutUser)
 		mux.Get("/reservations-new", handlers.Repo.AdminNewReservations)
 		mux.Get("/reservations-all", handlers.Repo.AdminAllReservations)
+		mux.Get("/reservations-trash", handlers.Repo.AdminTrashReservations)
 		mux.Get("/reservation-calendar", handlers.Repo.AdminReservationCalendar)
+		mux.Post("/reservation-calendar", handlers.Repo.AdminPostReservationCalendar)
+		mux.Post("/room-rules", handlers.Repo.AdminPostRoomRule)
+		mux.Post("/delete-room-rule", handlers.Repo.AdminDeleteRoomRule)
 
 		mux.Get("/reservations/{src}/{id}", handlers.Repo.AdminShowReservation)
 		mux.Post("/reservations/{src}/{id}", handlers.Repo.AdminPostShowReservation)
 
+		mux.Post("/reassign-reservation/{src}/{id}", handlers.Repo.AdminReassignReservation)
 		mux.Get("/process-reservation/{src}/{id}", handlers.Repo.AdminProcessReservation)
 		mux.Get("/delete-reservation/{src}/{id}", handlers.Repo.AdminDeleteReservation)
+		mux.Get("/restore-reservation/{id}", handlers.Repo.AdminRestoreReservation)
 	})
 
-	fileServer := http.FileServer(http.Dir("./static/"))
-	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
+	mux.Handle("/static/*", http.StripPrefix("/static", app.Assets))
 
 	return mux
 }
+
+func publicRoutes(mux chi.Router) {
+	mux.Get("/", handlers.Repo.Home)
+	mux.Get("/about", handlers.Repo.About)
+	mux.Get("/generals-quarters", handlers.Repo.Generals)
+	mux.Get("/majors-suite", handlers.Repo.Majors)
+	mux.Get("/search-availability", handlers.Repo.Availability)
+	mux.Post("/search-availability", handlers.Repo.PostAvailability)
+	mux.Post("/search-availability-json", handlers.Repo.PostAvailabilityJson)
+	mux.Get("/room-calendar/{id}", handlers.Repo.RoomCalendarJson)
+
+	mux.Get("/make-reservation", handlers.Repo.Reservation)
+	mux.Post("/make-reservation", handlers.Repo.PostReservation)
+	mux.Get("/reservation-summary", handlers.Repo.ReservationSummary)
+	mux.Get("/choose-room/{id}", handlers.Repo.ChooseRoom)
+	mux.Get("/book-room", handlers.Repo.BookRoom)
+
+	mux.Get("/waitlist", handlers.Repo.Waitlist)
+	mux.Post("/waitlist", handlers.Repo.PostWaitlist)
+	mux.Get("/waitlist/hold/{token}", handlers.Repo.WaitlistHold)
+
+	mux.Get("/contact", handlers.Repo.Contact)
+}
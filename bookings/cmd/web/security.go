@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/chenemiken/goland/bookings/helpers"
+	"github.com/chenemiken/goland/bookings/internal/metrics"
+)
+
+// cspReportPath is where browsers send Content-Security-Policy violations.
+const cspReportPath = "/csp-report"
+
+// maxCSPReportSize bounds the body of a violation report.
+const maxCSPReportSize = 64 << 10
+
+// SecurityHeaders sends the security headers of app.Security with every
+// response. Each request gets a new nonce, put in the policy and carried in
+// the context for the templates to mark their inline scripts with.
+func SecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		if app.Security.FrameOptions != "" {
+			h.Set("X-Frame-Options", app.Security.FrameOptions)
+		}
+		if app.Security.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", app.Security.ReferrerPolicy)
+		}
+		// not in development, where it would keep localhost on https
+		if app.InProduction && app.Security.HSTSMaxAge > 0 {
+			h.Set("Strict-Transport-Security", "max-age="+
+				strconv.FormatInt(int64(app.Security.HSTSMaxAge.Seconds()), 10)+"; includeSubDomains")
+		}
+
+		if app.Security.CSP != "" {
+			nonce, err := newCSPNonce()
+			if err != nil {
+				helpers.ServerError(w, r, err)
+				return
+			}
+			header := "Content-Security-Policy"
+			if app.Security.CSPReportOnly {
+				header = "Content-Security-Policy-Report-Only"
+			}
+			h.Set(header, strings.ReplaceAll(app.Security.CSP, "{nonce}", nonce))
+			h.Set("Reporting-Endpoints", `csp-endpoint="`+cspReportPath+`"`)
+			r = helpers.WithCSPNonce(r, nonce)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newCSPNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// cspViolation is the part of a violation report that is logged. Browsers
+// send the report-uri format, {"csp-report": {...}} with dashed names, or
+// the Reporting API format, a list of {"type": "csp-violation", "body":
+// {...}} with camel case names.
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	BlockedURI         string `json:"blocked-uri"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+	Disposition        string `json:"disposition"`
+}
+
+type reportingAPIViolation struct {
+	DocumentURL        string `json:"documentURL"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	BlockedURL         string `json:"blockedURL"`
+	SourceFile         string `json:"sourceFile"`
+	LineNumber         int    `json:"lineNumber"`
+	Disposition        string `json:"disposition"`
+}
+
+// CSPReport logs the Content-Security-Policy violations browsers report.
+func CSPReport(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCSPReportSize))
+	if err != nil {
+		http.Error(w, "report too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	violations, err := parseCSPReport(body)
+	if err != nil {
+		http.Error(w, "not a csp report", http.StatusBadRequest)
+		return
+	}
+
+	for _, v := range violations {
+		metrics.CSPViolations.Inc()
+		app.Logger.WarnContext(r.Context(), "content security policy violation",
+			"document", v.DocumentURI,
+			"directive", v.EffectiveDirective,
+			"blocked", v.BlockedURI,
+			"source", v.SourceFile,
+			"line", v.LineNumber,
+			"disposition", v.Disposition,
+		)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseCSPReport reads a report in either format.
+func parseCSPReport(body []byte) ([]cspViolation, error) {
+	var report struct {
+		Violation *cspViolation `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &report); err == nil && report.Violation != nil {
+		v := *report.Violation
+		if v.EffectiveDirective == "" {
+			v.EffectiveDirective = v.ViolatedDirective
+		}
+		return []cspViolation{v}, nil
+	}
+
+	var reports []struct {
+		Type string                `json:"type"`
+		Body reportingAPIViolation `json:"body"`
+	}
+	if err := json.Unmarshal(body, &reports); err != nil {
+		return nil, err
+	}
+	var violations []cspViolation
+	for _, rep := range reports {
+		if rep.Type != "csp-violation" {
+			continue
+		}
+		violations = append(violations, cspViolation{
+			DocumentURI:        rep.Body.DocumentURL,
+			EffectiveDirective: rep.Body.EffectiveDirective,
+			BlockedURI:         rep.Body.BlockedURL,
+			SourceFile:         rep.Body.SourceFile,
+			LineNumber:         rep.Body.LineNumber,
+			Disposition:        rep.Body.Disposition,
+		})
+	}
+	return violations, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chenemiken/goland/bookings/helpers"
+	"github.com/chenemiken/goland/bookings/internal/config"
+	"github.com/chenemiken/goland/bookings/internal/logging"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	defaults := config.DefaultSettings().Security
+	reportOnly := defaults
+	reportOnly.CSPReportOnly = true
+	none := config.SecuritySettings{}
+
+	var tests = []struct {
+		name       string
+		security   config.SecuritySettings
+		production bool
+		expected   map[string]string
+	}{
+		{"defaults", defaults, false, map[string]string{
+			"Content-Security-Policy":             "script-src 'self' 'nonce-",
+			"Content-Security-Policy-Report-Only": "",
+			"Strict-Transport-Security":           "",
+			"X-Frame-Options":                     "DENY",
+			"Referrer-Policy":                     "strict-origin-when-cross-origin",
+			"X-Content-Type-Options":              "nosniff",
+		}},
+		{"production", defaults, true, map[string]string{
+			"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		}},
+		{"report only", reportOnly, false, map[string]string{
+			"Content-Security-Policy":             "",
+			"Content-Security-Policy-Report-Only": "report-uri /csp-report",
+		}},
+		{"turned off", none, true, map[string]string{
+			"Content-Security-Policy":   "",
+			"Strict-Transport-Security": "",
+			"X-Frame-Options":           "",
+			"Referrer-Policy":           "",
+			"X-Content-Type-Options":    "nosniff",
+		}},
+	}
+
+	saved := app
+	defer func() { app = saved }()
+
+	for _, e := range tests {
+		app.Security = e.security
+		app.InProduction = e.production
+
+		var nonce string
+		h := SecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nonce = helpers.CSPNonce(r)
+		}))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+		for header, want := range e.expected {
+			got := rr.Header().Get(header)
+			if (want == "") != (got == "") || !strings.Contains(got, want) {
+				t.Errorf("%s: expected %s to hold %q but got %q", e.name, header, want, got)
+			}
+		}
+
+		policy := rr.Header().Get("Content-Security-Policy") + rr.Header().Get("Content-Security-Policy-Report-Only")
+		if (policy == "") != (nonce == "") || (policy != "" && !strings.Contains(policy, "'nonce-"+nonce+"'")) {
+			t.Errorf("%s: expected the nonce %q of the request in the policy %q", e.name, nonce, policy)
+		}
+	}
+}
+
+func TestSecurityHeadersNewNonce(t *testing.T) {
+	saved := app
+	defer func() { app = saved }()
+	app.Security = config.DefaultSettings().Security
+
+	seen := map[string]bool{}
+	h := SecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen[helpers.CSPNonce(r)] = true
+	}))
+	for i := 0; i < 3; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected a new nonce on every request but got %v", seen)
+	}
+}
+
+func TestCSPReport(t *testing.T) {
+	var tests = []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedLog    string
+	}{
+		{"report-uri", `{"csp-report": {"document-uri": "https://example.com/about",
+			"violated-directive": "script-src-elem", "blocked-uri": "inline"}}`,
+			http.StatusNoContent, "directive=script-src-elem blocked=inline"},
+		{"reporting api", `[{"type": "csp-violation", "body": {"documentURL": "https://example.com/",
+			"effectiveDirective": "img-src", "blockedURL": "https://evil.example/x.png"}},
+			{"type": "deprecation", "body": {}}]`,
+			http.StatusNoContent, "directive=img-src blocked=https://evil.example/x.png"},
+		{"not json", "hello", http.StatusBadRequest, ""},
+		{"too large", `{"csp-report": {"blocked-uri": "` + strings.Repeat("a", maxCSPReportSize) + `"}}`,
+			http.StatusRequestEntityTooLarge, ""},
+	}
+
+	saved := app.Logger
+	defer func() { app.Logger = saved }()
+
+	for _, e := range tests {
+		var buf bytes.Buffer
+		logger, err := logging.New(&buf, "text", slog.LevelInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		app.Logger = logger
+
+		req := httptest.NewRequest("POST", cspReportPath, strings.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/csp-report")
+		rr := httptest.NewRecorder()
+		CSPReport(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if e.expectedLog != "" && !strings.Contains(buf.String(), e.expectedLog) {
+			t.Errorf("%s: expected %q in the log but got %q", e.name, e.expectedLog, buf.String())
+		}
+		if e.expectedLog == "" && buf.Len() != 0 {
+			t.Errorf("%s: expected nothing logged but got %q", e.name, buf.String())
+		}
+	}
+}
+
+// TestCSPReportWithoutToken checks browsers can post reports past the CSRF
+// check.
+func TestCSPReportWithoutToken(t *testing.T) {
+	h := NoSurf(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	var tests = []struct {
+		path     string
+		expected int
+	}{
+		{cspReportPath, http.StatusNoContent},
+		{"/make-reservation", http.StatusBadRequest},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", e.path, strings.NewReader("{}")))
+		if rr.Code != e.expected {
+			t.Errorf("%s: expected status %d but got %d", e.path, e.expected, rr.Code)
+		}
+	}
+}
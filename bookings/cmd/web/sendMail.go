@@ -1,43 +1,173 @@
 package main
 
 import (
-	"log"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
 	"time"
 
+	"github.com/chenemiken/goland/bookings/internal/metrics"
 	"github.com/chenemiken/goland/bookings/internal/models"
 	mail "github.com/xhit/go-simple-mail/v2"
 )
 
+// mailDone is closed when the mail goroutine has stopped, and mailStop
+// tells it to stop before the queue is empty.
+var mailDone = make(chan struct{})
+var mailStop = make(chan struct{})
+
+// mailStopTimeout is how long shutdown waits for the mail goroutine to stop
+// once told to. It only takes longer when it is sending an email, and it
+// takes no other after that one.
+var mailStopTimeout = 10 * time.Second
+
+// unqueued holds spooled mail that was not queued again before shutdown.
+var unqueued []models.MailData
+var unqueuedMu sync.Mutex
+
+// listenForMail sends the mail queued on app.MailChan until the channel is
+// closed or mailStop is.
 func listenForMail() {
 	go func() {
+		defer close(mailDone)
+
 		for {
-			msg := <-app.MailChan
-			sendMail(msg)
+			// a stop comes first, so no email is taken off the queue
+			// once it is being saved
+			select {
+			case <-mailStop:
+				return
+			default:
+			}
+
+			select {
+			case <-mailStop:
+				return
+			case msg, ok := <-app.MailChan:
+				if !ok {
+					return
+				}
+				sendMail(msg)
+			}
 		}
 	}()
 }
 
 func sendMail(msg models.MailData) {
 	server := mail.NewSMTPClient()
-	server.Host = "localhost"
-	server.Port = 1025
+	server.Host = app.SMTP.Host
+	server.Port = app.SMTP.Port
+	server.Username = app.SMTP.Username
+	server.Password = app.SMTP.Password
 	server.KeepAlive = false
 	server.ConnectTimeout = 10 * time.Second
 	server.SendTimeout = 10 * time.Second
 
+	logger := app.Logger.With("to", msg.To, "subject", msg.Subject)
+	if msg.RequestID != "" {
+		logger = logger.With("request_id", msg.RequestID)
+	}
+
 	client, err := server.Connect()
 	if err != nil {
-		errorLog.Println(err)
+		logger.Error("could not connect to the mail server", "err", err)
 	}
 
 	email := mail.NewMSG()
 	email.SetFrom(msg.From).AddTo(msg.To).SetSubject(msg.Subject)
 	email.SetBody(mail.TextHTML, msg.Content)
+	if msg.RequestID != "" {
+		email.AddHeader("X-Request-ID", msg.RequestID)
+	}
 
 	err = email.Send(client)
 	if err != nil {
-		errorLog.Println(err)
+		metrics.MailFailed.Inc()
+		logger.Error("could not send mail", "err", err)
 	} else {
-		log.Println("mail sent!")
+		metrics.MailSent.Inc()
+		logger.Info("mail sent")
+	}
+}
+
+// spoolMail saves the mail left on app.MailChan to app.MailSpool, so it is
+// sent on the next start instead of being lost.
+func spoolMail() error {
+	unqueuedMu.Lock()
+	pending := unqueued
+	unqueuedMu.Unlock()
+	for {
+		select {
+		case msg, ok := <-app.MailChan:
+			if ok {
+				pending = append(pending, msg)
+				continue
+			}
+		default:
+		}
+		break
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+	if app.MailSpool == "" {
+		for _, msg := range pending {
+			app.Logger.Error("dropping unsent mail", "to", msg.To, "subject", msg.Subject)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(app.MailSpool, data, 0600); err != nil {
+		return err
 	}
+	app.Logger.Info("saved unsent mail", "emails", len(pending), "file", app.MailSpool)
+	return nil
+}
+
+// requeueSpooledMail queues the mail saved by spoolMail at the last
+// shutdown and removes the spool file.
+func requeueSpooledMail() error {
+	if app.MailSpool == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(app.MailSpool)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var pending []models.MailData
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return err
+	}
+	if err := os.Remove(app.MailSpool); err != nil {
+		return err
+	}
+
+	app.Logger.Info("sending mail saved at the last shutdown", "emails", len(pending))
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		for i, msg := range pending {
+			select {
+			case app.MailChan <- msg:
+			case <-quit:
+				// spoolMail saves them again
+				unqueuedMu.Lock()
+				unqueued = pending[i:]
+				unqueuedMu.Unlock()
+				return
+			}
+		}
+	}()
+	return nil
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/repository/dbrepo"
+)
+
+const sessionCleanupInterval = 5 * time.Minute
+
+// sessionStore keeps the sessions in the database. It is nil with the
+// memory driver, whose sessions are kept in memory by scs.
+var sessionStore *dbrepo.SessionStore
+
+// listenForSessionCleanup periodically removes expired sessions, which scs
+// never asks the store for again.
+func listenForSessionCleanup() {
+	if sessionStore == nil {
+		return
+	}
+
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		ticker := time.NewTicker(sessionCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			cleanUpSessions()
+			select {
+			case <-ticker.C:
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+func cleanUpSessions() {
+	removed, err := sessionStore.DeleteExpired()
+	if err != nil {
+		app.Logger.Error("could not remove expired sessions", "err", err)
+		return
+	}
+
+	if removed > 0 {
+		app.Logger.Debug("removed expired sessions", "sessions", removed)
+	}
+}
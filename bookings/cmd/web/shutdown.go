@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/drivers"
+)
+
+// quit is closed on shutdown to stop the background jobs, which add
+// themselves to jobs while running.
+var quit = make(chan struct{})
+var jobs sync.WaitGroup
+
+// shutdown stops the server within app.ShutdownTimeout: it lets in-flight
+// requests finish, stops the background jobs, sends the queued mail and
+// closes the database. Mail that cannot be sent in time is saved to
+// app.MailSpool.
+func shutdown(server *http.Server, db *drivers.DB) {
+	ctx, cancel := context.WithTimeout(context.Background(), app.ShutdownTimeout)
+	defer cancel()
+
+	app.Logger.Info("shutting down")
+
+	clean := true
+	if err := server.Shutdown(ctx); err != nil {
+		app.Logger.Error("requests still running at shutdown", "err", err)
+		clean = false
+	}
+
+	close(quit)
+	if err := wait(ctx, &jobs); err != nil {
+		app.Logger.Error("background jobs still running at shutdown", "err", err)
+		clean = false
+	}
+
+	// with no request or job left to queue mail, the queue can be closed and
+	// drained; otherwise a late sender would panic on the closed channel
+	if clean {
+		close(app.MailChan)
+		select {
+		case <-mailDone:
+		case <-ctx.Done():
+			app.Logger.Error("mail still queued at shutdown", "queued", len(app.MailChan))
+		}
+	}
+	// the mail goroutine must be gone before the queue is saved, or it
+	// could take an email off the queue that is then neither sent nor saved
+	close(mailStop)
+	select {
+	case <-mailDone:
+	case <-time.After(mailStopTimeout):
+		app.Logger.Error("mail still sending at shutdown")
+	}
+	if err := spoolMail(); err != nil {
+		app.Logger.Error("could not save unsent mail", "err", err)
+	}
+
+	if db != nil {
+		if err := db.SQL.Close(); err != nil {
+			app.Logger.Error("could not close the database", "err", err)
+		}
+	}
+	app.Logger.Info("shut down")
+}
+
+// wait waits for wg or for ctx to be done.
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/drivers"
+	"github.com/chenemiken/goland/bookings/internal/models"
+)
+
+// resetShutdown gives each test fresh shutdown state.
+func resetShutdown(t *testing.T, queued int) *drivers.DB {
+	t.Helper()
+
+	quit = make(chan struct{})
+	mailDone = make(chan struct{})
+	mailStop = make(chan struct{})
+	unqueued = nil
+	jobs = sync.WaitGroup{}
+	mailStopTimeout = 200 * time.Millisecond
+
+	app.Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	app.ShutdownTimeout = 200 * time.Millisecond
+	app.MailSpool = filepath.Join(t.TempDir(), "mail-spool.json")
+	app.MailChan = make(chan models.MailData, queued)
+
+	conn, err := sql.Open("pgx", "host=localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &drivers.DB{SQL: conn}
+}
+
+func TestShutdownSpoolsMail(t *testing.T) {
+	db := resetShutdown(t, 2)
+	app.MailChan <- models.MailData{To: "a@email.com", Subject: "one"}
+	app.MailChan <- models.MailData{To: "b@email.com", Subject: "two"}
+
+	// no mail goroutine is running, so the queue cannot drain in time
+	shutdown(&http.Server{}, db)
+
+	if _, err := os.Stat(app.MailSpool); err != nil {
+		t.Fatalf("expected the queued mail to be saved: %s", err)
+	}
+
+	spool := app.MailSpool
+	resetShutdown(t, 0)
+	app.MailSpool = spool
+
+	if err := requeueSpooledMail(); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"one", "two"} {
+		select {
+		case msg := <-app.MailChan:
+			if msg.Subject != expected {
+				t.Errorf("expected mail %q but got %q", expected, msg.Subject)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("mail %q was not queued again", expected)
+		}
+	}
+	jobs.Wait()
+	if _, err := os.Stat(spool); !os.IsNotExist(err) {
+		t.Error("the spool file was not removed")
+	}
+}
+
+func TestShutdownRespoolsUnqueuedMail(t *testing.T) {
+	db := resetShutdown(t, 0)
+	if err := os.WriteFile(app.MailSpool, []byte(`[{"Subject":"one"},{"Subject":"two"}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := requeueSpooledMail(); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing reads the queue, so both emails are saved again
+	shutdown(&http.Server{}, db)
+
+	data, err := os.ReadFile(app.MailSpool)
+	if err != nil {
+		t.Fatalf("expected the mail to be saved again: %s", err)
+	}
+	if string(data) != `[{"To":"","From":"","Subject":"one","Content":""},{"To":"","From":"","Subject":"two","Content":""}]` {
+		t.Errorf("unexpected spool %s", data)
+	}
+}
+
+func TestShutdownWaitsForMailBeingSent(t *testing.T) {
+	db := resetShutdown(t, 2)
+	app.ShutdownTimeout = 20 * time.Millisecond
+	app.MailChan <- models.MailData{Subject: "one"}
+	app.MailChan <- models.MailData{Subject: "two"}
+
+	// stands in for the mail goroutine, still sending the first email when
+	// the shutdown times out
+	var sent []string
+	go func() {
+		defer close(mailDone)
+		msg := <-app.MailChan
+		time.Sleep(100 * time.Millisecond)
+		sent = append(sent, msg.Subject)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	shutdown(&http.Server{}, db)
+
+	if len(sent) != 1 {
+		t.Errorf("expected shutdown to wait for the email being sent but got %v", sent)
+	}
+	data, err := os.ReadFile(app.MailSpool)
+	if err != nil {
+		t.Fatalf("expected the mail left to be saved: %s", err)
+	}
+	if string(data) != `[{"To":"","From":"","Subject":"two","Content":""}]` {
+		t.Errorf("unexpected spool %s", data)
+	}
+}
+
+func TestShutdownWaitsForRequests(t *testing.T) {
+	db := resetShutdown(t, 0)
+	app.ShutdownTimeout = 2 * time.Second
+
+	started := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		app.MailChan <- models.MailData{Subject: "sent from a request"}
+		w.WriteHeader(http.StatusOK)
+	})}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+
+	// stands in for the mail goroutine
+	var sent []string
+	go func() {
+		defer close(mailDone)
+		for msg := range app.MailChan {
+			sent = append(sent, msg.Subject)
+		}
+	}()
+
+	result := make(chan int)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			result <- 0
+			return
+		}
+		resp.Body.Close()
+		result <- resp.StatusCode
+	}()
+
+	<-started
+	shutdown(server, db)
+
+	if code := <-result; code != http.StatusOK {
+		t.Errorf("the in-flight request was dropped, got code %d", code)
+	}
+	if len(sent) != 1 {
+		t.Errorf("expected the mail queued by the request to be sent but got %v", sent)
+	}
+	if _, err := os.Stat(app.MailSpool); !os.IsNotExist(err) {
+		t.Error("mail was saved although the queue drained")
+	}
+}
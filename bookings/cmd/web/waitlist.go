@@ -0,0 +1,42 @@
+package main
+
+import (
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/handlers"
+	"github.com/chenemiken/goland/bookings/internal/waitlist"
+)
+
+const holdExpiryInterval = 5 * time.Minute
+
+// listenForHoldExpiry periodically gives back rooms held for waitlisted
+// guests who did not book in time, and offers them to the next in line.
+func listenForHoldExpiry() {
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		ticker := time.NewTicker(holdExpiryInterval)
+		defer ticker.Stop()
+
+		for {
+			expireHolds()
+			select {
+			case <-ticker.C:
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+func expireHolds() {
+	released, err := waitlist.ExpireHolds(&app, handlers.Repo.DB)
+	if err != nil {
+		app.Logger.Error("could not expire waitlist holds", "err", err)
+		return
+	}
+
+	if released > 0 {
+		app.Logger.Info("released expired waitlist holds", "holds", released)
+	}
+}
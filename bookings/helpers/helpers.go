@@ -1,11 +1,14 @@
 package helpers
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"runtime/debug"
 
 	"github.com/chenemiken/goland/bookings/internal/config"
+	"github.com/chenemiken/goland/bookings/internal/i18n"
+	"github.com/chenemiken/goland/bookings/internal/logging"
+	"github.com/chenemiken/goland/bookings/internal/models"
 )
 
 var app *config.AppConfig
@@ -14,19 +17,104 @@ func NewHelpers(a *config.AppConfig) {
 	app = a
 }
 
-func ClientError(w http.ResponseWriter, status int) {
-	app.InfoLog.Println("Client error with status of", status)
+// ErrorPage writes the page shown for an HTTP error. The renderer registers
+// one with SetErrorPage; until then errors are written as plain text.
+type ErrorPage func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+var errorPage ErrorPage
+
+// SetErrorPage registers the function ClientError and ServerError use to
+// write their response.
+func SetErrorPage(p ErrorPage) {
+	errorPage = p
+}
+
+func ClientError(w http.ResponseWriter, r *http.Request, status int) {
+	app.Logger.InfoContext(r.Context(), "client error", "status", status)
+	writeError(w, r, status, nil)
+}
+
+func ServerError(w http.ResponseWriter, r *http.Request, err error) {
+	app.Logger.ErrorContext(r.Context(), "server error", "err", err,
+		"stack", string(debug.Stack()))
+	writeError(w, r, http.StatusInternalServerError, err)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if errorPage != nil {
+		errorPage(w, r, status, err)
+		return
+	}
 	http.Error(w, http.StatusText(status), status)
 }
 
-func ServerError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.ErrorLog.Println(trace)
-	http.Error(w, http.StatusText(http.StatusInternalServerError),
-		http.StatusInternalServerError)
+// RequestID returns the id the RequestID middleware gave the request, so
+// a visitor quoting it can be matched with the logs.
+func RequestID(r *http.Request) string {
+	return logging.RequestID(r.Context())
 }
 
 func IsAuthenticated(r *http.Request) bool {
 	exists := app.Session.Exists(r.Context(), "user_id")
 	return exists
 }
+
+type contextKey string
+
+const propertyKey contextKey = "property"
+const basePathKey contextKey = "base_path"
+const localeKey contextKey = "locale"
+const cspNonceKey contextKey = "csp_nonce"
+
+// WithProperty returns a copy of r carrying the property the request is for
+// and the path prefix the public site is mounted under ("" for host routing).
+func WithProperty(r *http.Request, p models.Property, basePath string) *http.Request {
+	ctx := context.WithValue(r.Context(), propertyKey, p)
+	ctx = context.WithValue(ctx, basePathKey, basePath)
+	return r.WithContext(ctx)
+}
+
+// CurrentProperty returns the property set on the request by WithProperty.
+func CurrentProperty(r *http.Request) models.Property {
+	p, _ := r.Context().Value(propertyKey).(models.Property)
+	return p
+}
+
+// BasePath returns the path prefix the current property is served under.
+func BasePath(r *http.Request) string {
+	path, _ := r.Context().Value(basePathKey).(string)
+	return path
+}
+
+// PropertyURL prefixes a public site path with the current property's base path.
+func PropertyURL(r *http.Request, path string) string {
+	return BasePath(r) + path
+}
+
+// WithLocale returns a copy of r carrying the locale the visitor is served in.
+func WithLocale(r *http.Request, locale string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), localeKey, locale))
+}
+
+// CurrentLocale returns the locale set on the request by WithLocale, or the
+// default locale when none was set.
+func CurrentLocale(r *http.Request) string {
+	locale, _ := r.Context().Value(localeKey).(string)
+	if locale == "" {
+		return i18n.Default
+	}
+	return locale
+}
+
+// WithCSPNonce returns a copy of r carrying the nonce the Content-Security-
+// Policy of the response allows inline scripts with.
+func WithCSPNonce(r *http.Request, nonce string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), cspNonceKey, nonce))
+}
+
+// CSPNonce returns the nonce set on the request by WithCSPNonce, or "" when
+// none was set.
+func CSPNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceKey).(string)
+	return nonce
+}
@@ -0,0 +1,250 @@
+// Package assets serves the static files with fingerprinted names, so
+// browsers can cache them forever and still pick up a new version as soon
+// as a file changes.
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// immutable is the Cache-Control of fingerprinted URLs, whose content never
+// changes.
+const immutable = "public, max-age=31536000, immutable"
+
+// compressible lists the extensions worth keeping a gzip copy of.
+var compressible = map[string]bool{
+	".css":  true,
+	".js":   true,
+	".map":  true,
+	".svg":  true,
+	".html": true,
+	".json": true,
+	".txt":  true,
+	".eot":  true,
+	".ttf":  true,
+}
+
+// Manifest knows the content hash of every static file. It is an
+// http.Handler serving the files under both their plain and fingerprinted
+// names.
+type Manifest struct {
+	fsys   fs.FS
+	prefix string
+	live   bool
+
+	mu       sync.RWMutex
+	files    map[string]*asset
+	byHashed map[string]*asset
+}
+
+type asset struct {
+	name    string
+	hashed  string
+	etag    string
+	modTime time.Time
+	gzipped []byte
+}
+
+// New hashes every file of fsys. URLs are built under prefix, the path the
+// handler is mounted on. When live is set a file is hashed again whenever
+// it changes, for development.
+func New(fsys fs.FS, prefix string, live bool) (*Manifest, error) {
+	m := &Manifest{
+		fsys:     fsys,
+		prefix:   strings.TrimSuffix(prefix, "/") + "/",
+		live:     live,
+		files:    map[string]*asset{},
+		byHashed: map[string]*asset{},
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		a, err := load(fsys, name)
+		if err != nil {
+			return err
+		}
+		m.add(a)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// load reads name and builds its fingerprint, ETag and gzip copy.
+func load(fsys fs.FS, name string) (*asset, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	ext := path.Ext(name)
+
+	a := &asset{
+		name:    name,
+		hashed:  strings.TrimSuffix(name, ext) + "." + hash[:12] + ext,
+		etag:    `"` + hash[:32] + `"`,
+		modTime: info.ModTime(),
+	}
+
+	if compressible[ext] {
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		// keep the copy only when it is worth it
+		if buf.Len() < len(data)*9/10 {
+			a.gzipped = buf.Bytes()
+		}
+	}
+
+	return a, nil
+}
+
+func (m *Manifest) add(a *asset) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if old, ok := m.files[a.name]; ok {
+		delete(m.byHashed, old.hashed)
+	}
+	m.files[a.name] = a
+	m.byHashed[a.hashed] = a
+}
+
+// lookup finds a file by its plain name, hashing it again first when it
+// changed on a live manifest.
+func (m *Manifest) lookup(name string) (*asset, bool) {
+	m.mu.RLock()
+	a, ok := m.files[name]
+	m.mu.RUnlock()
+
+	if !m.live {
+		return a, ok
+	}
+
+	info, err := fs.Stat(m.fsys, name)
+	if err != nil {
+		return nil, false
+	}
+	if ok && info.ModTime().Equal(a.modTime) {
+		return a, true
+	}
+
+	a, err = load(m.fsys, name)
+	if err != nil {
+		return nil, false
+	}
+	m.add(a)
+	return a, true
+}
+
+// Path returns the fingerprinted URL of the static file name, such as
+// /static/css/styles.0a1b2c3d4e5f.css for css/styles.css. Files it does not
+// know keep their plain URL.
+func (m *Manifest) Path(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if a, ok := m.lookup(name); ok {
+		return m.prefix + a.hashed
+	}
+	return m.prefix + name
+}
+
+// ServeHTTP serves the file named by the request path, which is relative to
+// the prefix. Fingerprinted names are cached for a year; plain names are
+// revalidated with their ETag. Clients accepting gzip get the compressed
+// copy when there is one.
+func (m *Manifest) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+
+	m.mu.RLock()
+	a, hashed := m.byHashed[name]
+	m.mu.RUnlock()
+
+	if !hashed {
+		var ok bool
+		a, ok = m.lookup(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	h := w.Header()
+	if hashed {
+		h.Set("Cache-Control", immutable)
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+	if ctype := mime.TypeByExtension(path.Ext(a.name)); ctype != "" {
+		h.Set("Content-Type", ctype)
+	}
+
+	if a.gzipped != nil {
+		h.Add("Vary", "Accept-Encoding")
+		if acceptsGzip(r) {
+			h.Set("Content-Encoding", "gzip")
+			h.Set("ETag", strings.TrimSuffix(a.etag, `"`)+`-gz"`)
+			http.ServeContent(w, r, a.name, a.modTime, bytes.NewReader(a.gzipped))
+			return
+		}
+	}
+	h.Set("ETag", a.etag)
+
+	f, err := m.fsys.Open(a.name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	http.ServeContent(w, r, a.name, a.modTime, content)
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var styles = strings.Repeat("body { margin: 0; }\n", 50)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"css/styles.css":  {Data: []byte(styles), ModTime: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)},
+		"images/logo.png": {Data: []byte("not really a png"), ModTime: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+}
+
+func TestPath(t *testing.T) {
+	m, err := New(testFS(), "/static", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		file     string
+		expected string
+	}{
+		{"css", "css/styles.css", `^/static/css/styles\.[0-9a-f]{12}\.css$`},
+		{"leading slash", "/images/logo.png", `^/static/images/logo\.[0-9a-f]{12}\.png$`},
+		{"unknown file", "js/missing.js", `^/static/js/missing\.js$`},
+	}
+
+	for _, e := range tests {
+		if got := m.Path(e.file); !regexp.MustCompile(e.expected).MatchString(got) {
+			t.Errorf("%s: %s does not match %s", e.name, got, e.expected)
+		}
+	}
+
+	if m.Path("css/styles.css") == m.Path("images/logo.png") {
+		t.Error("different files got the same URL")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	m, err := New(testFS(), "/static", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := strings.TrimPrefix(m.Path("css/styles.css"), "/static")
+	etag := m.files["css/styles.css"].etag
+
+	var tests = []struct {
+		name         string
+		url          string
+		headers      map[string]string
+		expectedCode int
+		cacheControl string
+		encoding     string
+		expectedBody string
+	}{
+		{"fingerprinted", hashed, nil, http.StatusOK, immutable, "", styles},
+		{"plain name", "/css/styles.css", nil, http.StatusOK, "no-cache", "", styles},
+		{"gzip", hashed, map[string]string{"Accept-Encoding": "br, gzip"},
+			http.StatusOK, immutable, "gzip", styles},
+		{"gzip refused", hashed, map[string]string{"Accept-Encoding": "gzip;q=0"},
+			http.StatusOK, immutable, "", styles},
+		{"not worth compressing", "/images/logo.png", map[string]string{"Accept-Encoding": "gzip"},
+			http.StatusOK, "no-cache", "", "not really a png"},
+		{"etag matches", hashed, map[string]string{"If-None-Match": etag},
+			http.StatusNotModified, immutable, "", ""},
+		{"missing", "/css/missing.css", nil, http.StatusNotFound, "", "", "404 page not found\n"},
+		{"outside the folder", "/../secret", nil, http.StatusNotFound, "", "", "404 page not found\n"},
+	}
+
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", e.url, nil)
+		for k, v := range e.headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: expected code %d but got %d", e.name, e.expectedCode, rr.Code)
+		}
+		if got := rr.Header().Get("Cache-Control"); got != e.cacheControl {
+			t.Errorf("%s: expected Cache-Control %q but got %q", e.name, e.cacheControl, got)
+		}
+		if got := rr.Header().Get("Content-Encoding"); got != e.encoding {
+			t.Errorf("%s: expected Content-Encoding %q but got %q", e.name, e.encoding, got)
+		}
+
+		body := rr.Body.Bytes()
+		if e.encoding == "gzip" {
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("%s: %s", e.name, err)
+			}
+			body, _ = io.ReadAll(zr)
+			if rr.Header().Get("ETag") == etag {
+				t.Errorf("%s: the gzip copy has the same ETag as the plain file", e.name)
+			}
+		}
+		if string(body) != e.expectedBody {
+			t.Errorf("%s: unexpected body %q", e.name, body)
+		}
+	}
+}
+
+func TestLive(t *testing.T) {
+	fsys := testFS()
+	m, err := New(fsys, "/static/", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := m.Path("css/styles.css")
+
+	fsys["css/styles.css"] = &fstest.MapFile{
+		Data:    []byte("body { margin: 1em; }"),
+		ModTime: time.Date(2050, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	after := m.Path("css/styles.css")
+	if after == before {
+		t.Fatal("an edited file kept its fingerprint")
+	}
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", strings.TrimPrefix(after, "/static"), nil))
+	if rr.Body.String() != "body { margin: 1em; }" {
+		t.Errorf("expected the edited file but got %q", rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", strings.TrimPrefix(before, "/static"), nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("the old fingerprint is still served with code %d", rr.Code)
+	}
+}
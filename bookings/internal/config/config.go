@@ -2,18 +2,32 @@ package config
 
 import (
 	"html/template"
-	"log"
+	"io/fs"
+	"log/slog"
+	"time"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/chenemiken/goland/bookings/internal/assets"
 	"github.com/chenemiken/goland/bookings/internal/models"
 )
 
 type AppConfig struct {
-	UseCache      bool
-	TemplateCache map[string]*template.Template
-	InfoLog       *log.Logger
-	ErrorLog      *log.Logger
-	InProduction  bool
-	Session       *scs.SessionManager
-	MailChan      chan models.MailData
+	UseCache        bool
+	TemplateCache   map[string]*template.Template
+	Templates       fs.FS
+	Static          fs.FS
+	Assets          *assets.Manifest
+	Logger          *slog.Logger
+	InProduction    bool
+	Session         *scs.SessionManager
+	MailChan        chan models.MailData
+	PurgeAfter      time.Duration
+	HoldFor         time.Duration
+	QueryCacheTTL   time.Duration
+	ShutdownTimeout time.Duration
+	MailSpool       string
+	BaseURL         string
+	Addr            string
+	SMTP            SMTPSettings
+	Security        SecuritySettings
 }
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML reads the subset of YAML config files need: nested mappings of
+// scalars, with comments. Keys are flattened to dotted names, so
+// "database:\n  host: db" and "database.host: db" are the same.
+func parseYAML(doc string) (map[string]string, error) {
+	values := map[string]string{}
+
+	// a level is a mapping: the indent of its own key and of its entries
+	type level struct {
+		keyIndent int
+		indent    int
+		prefix    string
+	}
+	stack := []level{{keyIndent: -1}}
+	open := false // the last key started a mapping
+
+	for n, line := range strings.Split(doc, "\n") {
+		text := strings.TrimRight(stripComment(line), " \r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", n+1)
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			return nil, fmt.Errorf("line %d: lists are not supported", n+1)
+		}
+		indent := len(text) - len(trimmed)
+
+		if open {
+			top := &stack[len(stack)-1]
+			if indent <= top.keyIndent {
+				return nil, fmt.Errorf("line %d: %s has no value", n+1, top.prefix)
+			}
+			top.indent = indent
+			open = false
+		}
+		for len(stack) > 1 && indent < stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		if indent != stack[len(stack)-1].indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", n+1)
+		}
+
+		key, value, found := strings.Cut(trimmed, ":")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected key: value", n+1)
+		}
+		key = strings.TrimSpace(key)
+		if parent := stack[len(stack)-1].prefix; parent != "" {
+			key = parent + "." + key
+		}
+
+		value = strings.TrimSpace(value)
+		if value == "" {
+			stack = append(stack, level{keyIndent: indent, prefix: key})
+			open = true
+			continue
+		}
+
+		v, err := unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		values[key] = v
+	}
+	if open {
+		return nil, fmt.Errorf("%s has no value", stack[len(stack)-1].prefix)
+	}
+
+	return values, nil
+}
+
+// parseTOML reads the subset of TOML config files need: tables of key =
+// value pairs, with comments. Keys are flattened to dotted names.
+func parseTOML(doc string) (map[string]string, error) {
+	values := map[string]string{}
+	table := ""
+
+	for n, line := range strings.Split(doc, "\n") {
+		text := strings.TrimSpace(stripComment(line))
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") || strings.HasPrefix(text, "[[") {
+				return nil, fmt.Errorf("line %d: expected [table]", n+1)
+			}
+			table = strings.TrimSpace(text[1 : len(text)-1])
+			continue
+		}
+
+		key, value, found := strings.Cut(text, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected key = value", n+1)
+		}
+		key = strings.TrimSpace(key)
+		if table != "" {
+			key = table + "." + key
+		}
+
+		v, err := unquote(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		values[key] = v
+	}
+
+	return values, nil
+}
+
+// stripComment cuts a # comment off a line, leaving # inside quotes alone.
+func stripComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		v, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("bad quoted value %s", value)
+		}
+		return v, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("bad quoted value %s", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	return value, nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	values, err := parseYAML(`---
+# comment
+port: 8080
+database:
+  host: 'db # not a comment'
+  credentials:
+    user: "bookings"
+  name: bookings
+smtp.host: mail
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"port":                      "8080",
+		"database.host":             "db # not a comment",
+		"database.credentials.user": "bookings",
+		"database.name":             "bookings",
+		"smtp.host":                 "mail",
+	}
+	if len(values) != len(expected) {
+		t.Errorf("expected %v but got %v", expected, values)
+	}
+	for k, v := range expected {
+		if values[k] != v {
+			t.Errorf("%s: expected %q but got %q", k, v, values[k])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var tests = []struct {
+		name     string
+		parse    func(string) (map[string]string, error)
+		doc      string
+		expected string
+	}{
+		{"yaml tabs", parseYAML, "database:\n\thost: db\n", "line 2: indent with spaces"},
+		{"yaml list", parseYAML, "hosts:\n  - a\n", "line 2: lists are not supported"},
+		{"yaml no colon", parseYAML, "port 8080\n", "line 1: expected key: value"},
+		{"yaml empty mapping", parseYAML, "database:\nport: 1\n", "database has no value"},
+		{"yaml bad indent", parseYAML, "database:\n    host: a\n  name: b\n", "line 3: unexpected indentation"},
+		{"yaml bad quote", parseYAML, "host: \"db\n", "line 1: bad quoted value"},
+		{"toml no equals", parseTOML, "port 8080\n", "line 1: expected key = value"},
+		{"toml array of tables", parseTOML, "[[hosts]]\n", "line 1: expected [table]"},
+		{"toml bad quote", parseTOML, "host = 'db\n", "line 1: bad quoted value"},
+	}
+
+	for _, e := range tests {
+		_, err := e.parse(e.doc)
+		if err == nil || !strings.Contains(err.Error(), e.expected) {
+			t.Errorf("%s: expected %q but got %v", e.name, e.expected, err)
+		}
+	}
+}
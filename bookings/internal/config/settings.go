@@ -0,0 +1,572 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// EnvPrefix starts the name of every environment variable read by Load.
+const EnvPrefix = "BOOKINGS_"
+
+// Settings are the options the server is started with.
+type Settings struct {
+	Production bool
+	Cache      bool
+	Port       int
+	BaseURL    string
+	PurgeAfter time.Duration
+	HoldFor    time.Duration
+	// QueryCacheTTL is how long rooms and the availability shown to guests
+	// are cached; 0 turns the cache off.
+	QueryCacheTTL time.Duration
+	// ShutdownTimeout bounds how long in-flight requests and queued mail
+	// are waited for on shutdown.
+	ShutdownTimeout time.Duration
+	// MailSpool is the file mail still queued at shutdown is saved to, to
+	// be sent on the next start.
+	MailSpool string
+	// LogFormat is text or json; LogLevel is debug, info, warn or error.
+	LogFormat string
+	LogLevel  string
+	Database  DatabaseSettings
+	Session   SessionSettings
+	SMTP      SMTPSettings
+	Security  SecuritySettings
+
+	// PrintConfig is set by --print-config: print the settings and exit.
+	PrintConfig bool
+	// Args are the command-line arguments left after the flags, such as
+	// the command of a tool sharing these settings.
+	Args []string
+
+	sources map[string]string
+}
+
+// DatabaseSettings tell which database to use and how to connect to it.
+type DatabaseSettings struct {
+	// Driver is postgres, mysql, or memory to keep everything in memory for
+	// local development, with no database server.
+	Driver   string
+	Host     string
+	Port     int
+	Name     string
+	User     string
+	Password string
+	SSLMode  string
+}
+
+// SessionSettings tell how long a session lasts. Sessions are kept in the
+// database, or in memory with the memory driver.
+type SessionSettings struct {
+	// IdleTimeout ends a session unused for that long; 0 never does.
+	IdleTimeout time.Duration
+	// Lifetime ends a session that long after it began, however active.
+	Lifetime time.Duration
+}
+
+// SecuritySettings are the security headers sent with every response. An
+// empty header is not sent.
+type SecuritySettings struct {
+	// CSP is the Content-Security-Policy, with {nonce} standing for the
+	// nonce inline scripts are allowed with, new on every request.
+	CSP string
+	// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only,
+	// so violations are reported but nothing is blocked.
+	CSPReportOnly bool
+	// HSTSMaxAge is how long browsers keep to https once told to in
+	// production; 0 does not tell them.
+	HSTSMaxAge     time.Duration
+	FrameOptions   string
+	ReferrerPolicy string
+}
+
+// DefaultCSP allows the app's own files, the CDNs the layouts load from and
+// inline scripts carrying the nonce. Violations are reported to
+// /csp-report, which the csp-endpoint reporting endpoint also names.
+const DefaultCSP = "default-src 'self'; " +
+	"script-src 'self' 'nonce-{nonce}' https://cdn.jsdelivr.net https://unpkg.com https://code.jquery.com; " +
+	"style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net https://unpkg.com; " +
+	"img-src 'self' data:; font-src 'self' data:; connect-src 'self'; " +
+	"object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'; " +
+	"report-uri /csp-report; report-to csp-endpoint"
+
+// SMTPSettings tell how to send email.
+type SMTPSettings struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// Level returns the parsed LogLevel, which Load has validated.
+func (s Settings) Level() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(s.LogLevel))
+	return level
+}
+
+// DSN returns the connection string for the database.
+func (d DatabaseSettings) DSN() string {
+	if d.Driver == "mysql" {
+		return d.mysqlDSN()
+	}
+	return fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s sslmode=%s",
+		d.Host, d.Port, d.Name, d.User, d.Password, d.SSLMode)
+}
+
+// mysqlDSN returns the connection string of a MySQL database. Dates are
+// read as times, and several statements may be sent at once as the seed
+// migrations do. The sslmode is mapped to the closest tls setting.
+func (d DatabaseSettings) mysqlDSN() string {
+	c := mysql.NewConfig()
+	c.User = d.User
+	c.Passwd = d.Password
+	c.Net = "tcp"
+	c.Addr = net.JoinHostPort(d.Host, strconv.Itoa(d.Port))
+	c.DBName = d.Name
+	c.ParseTime = true
+	c.MultiStatements = true
+	switch d.SSLMode {
+	case "allow", "prefer":
+		c.TLSConfig = "preferred"
+	case "require":
+		c.TLSConfig = "skip-verify"
+	case "verify-ca", "verify-full":
+		c.TLSConfig = "true"
+	}
+	return c.FormatDSN()
+}
+
+// Addr returns the address the server listens on.
+func (s Settings) Addr() string {
+	return fmt.Sprintf(":%d", s.Port)
+}
+
+// option is one setting, known by its key in config files, the environment
+// variable derived from the key and a flag of the same name.
+type option struct {
+	key    string
+	alias  string // flag name used before the config file existed
+	usage  string
+	secret bool
+	value  interface{}
+}
+
+func (s *Settings) options() []option {
+	return []option{
+		{"production", "", "run in production mode", false, &s.Production},
+		{"cache", "", "cache templates instead of reloading them on change", false, &s.Cache},
+		{"port", "", "port the server listens on", false, &s.Port},
+		{"base_url", "baseurl", "public URL of the site, used in links sent by email", false, &s.BaseURL},
+		{"purge_after", "purgeafter", "how long deleted reservations stay in the trash before being purged", false, &s.PurgeAfter},
+		{"hold_for", "holdfor", "how long a room freed for the waitlist is held for the guest offered it", false, &s.HoldFor},
+		{"query_cache_ttl", "", "how long rooms and availability are cached, 0 to not cache them", false, &s.QueryCacheTTL},
+		{"shutdown_timeout", "", "how long to wait for requests and queued mail when shutting down", false, &s.ShutdownTimeout},
+		{"mail_spool", "", "file that mail still queued at shutdown is saved to and sent from on the next start", false, &s.MailSpool},
+		{"log_format", "", "log format: text, or json for log collectors", false, &s.LogFormat},
+		{"log_level", "", "lowest level logged: debug, info, warn or error", false, &s.LogLevel},
+		{"database.driver", "", "database: postgres, mysql, or memory for local development without a database server", false, &s.Database.Driver},
+		{"database.host", "dbhost", "database host", false, &s.Database.Host},
+		{"database.port", "dbport", "database port", false, &s.Database.Port},
+		{"database.name", "dbname", "database name", false, &s.Database.Name},
+		{"database.user", "dbuser", "database user", false, &s.Database.User},
+		{"database.password", "dbpass", "database password", true, &s.Database.Password},
+		{"database.sslmode", "dbssl", "database ssl mode (disable, allow, prefer, require, verify-ca, verify-full)", false, &s.Database.SSLMode},
+		{"session.idle_timeout", "", "how long a session lasts unused, 0 for as long as its lifetime", false, &s.Session.IdleTimeout},
+		{"session.lifetime", "", "how long a session lasts however active, before logging in again", false, &s.Session.Lifetime},
+		{"security.csp", "", "Content-Security-Policy, with {nonce} for the nonce of inline scripts; empty to not send one", false, &s.Security.CSP},
+		{"security.csp_report_only", "", "only report Content-Security-Policy violations instead of blocking them", false, &s.Security.CSPReportOnly},
+		{"security.hsts_max_age", "", "how long browsers keep to https (Strict-Transport-Security) in production, 0 to not send it", false, &s.Security.HSTSMaxAge},
+		{"security.frame_options", "", "X-Frame-Options: DENY, SAMEORIGIN, or empty to not send it", false, &s.Security.FrameOptions},
+		{"security.referrer_policy", "", "Referrer-Policy, or empty to not send it", false, &s.Security.ReferrerPolicy},
+		{"smtp.host", "", "mail server host", false, &s.SMTP.Host},
+		{"smtp.port", "", "mail server port", false, &s.SMTP.Port},
+		{"smtp.username", "", "mail server user", false, &s.SMTP.Username},
+		{"smtp.password", "", "mail server password", true, &s.SMTP.Password},
+	}
+}
+
+// DefaultSettings returns the settings used for anything not configured.
+func DefaultSettings() Settings {
+	return Settings{
+		Production:      true,
+		Cache:           true,
+		Port:            8080,
+		BaseURL:         "http://localhost:8080",
+		PurgeAfter:      30 * 24 * time.Hour,
+		HoldFor:         24 * time.Hour,
+		QueryCacheTTL:   time.Minute,
+		ShutdownTimeout: 30 * time.Second,
+		MailSpool:       "mail-spool.json",
+		LogFormat:       "text",
+		LogLevel:        "info",
+		Database: DatabaseSettings{
+			Driver:  "postgres",
+			Host:    "localhost",
+			Port:    5432,
+			SSLMode: "disable",
+		},
+		Session: SessionSettings{
+			Lifetime: 24 * time.Hour,
+		},
+		SMTP: SMTPSettings{
+			Host: "localhost",
+			Port: 1025,
+		},
+		Security: SecuritySettings{
+			CSP:            DefaultCSP,
+			HSTSMaxAge:     365 * 24 * time.Hour,
+			FrameOptions:   "DENY",
+			ReferrerPolicy: "strict-origin-when-cross-origin",
+		},
+	}
+}
+
+// Load builds the settings from, in increasing order of precedence, the
+// defaults, a YAML or TOML config file, environment variables and the
+// command line flags in args.
+//
+// The config file is named by -config or BOOKINGS_CONFIG. Every key of it,
+// such as database.host, can also be set with BOOKINGS_DATABASE_HOST or
+// -database.host. Secrets can instead be read from a file named by the key
+// with _file added, as in BOOKINGS_DATABASE_PASSWORD_FILE, so they need not
+// be written in the config or the environment.
+//
+// The settings are returned with the error when they fail validation, so
+// --print-config can still show them.
+func Load(args []string, getenv func(string) string) (Settings, error) {
+	s := DefaultSettings()
+	s.sources = map[string]string{}
+	opts := s.options()
+
+	flags := map[string]string{}
+	fs := flag.NewFlagSet("bookings", flag.ContinueOnError)
+	configFile := fs.String("config", "", "YAML or TOML config file (env "+EnvPrefix+"CONFIG)")
+	fs.BoolVar(&s.PrintConfig, "print-config", false, "print the effective config, with secrets hidden, and exit")
+	for _, o := range opts {
+		v := flagValue{key: o.key, values: flags, isBool: isBool(o.value)}
+		fs.Var(v, o.key, o.usage+" (env "+envName(o.key)+")")
+		if o.alias != "" {
+			fs.Var(flagValue{key: o.key, values: flags, isBool: v.isBool}, o.alias,
+				"same as -"+o.key)
+		}
+		if o.secret {
+			fs.Var(flagValue{key: o.key + "_file", values: flags}, o.key+"_file",
+				"file holding the "+o.usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return s, err
+	}
+	s.Args = fs.Args()
+
+	env := map[string]string{}
+	for _, o := range opts {
+		for _, key := range []string{o.key, o.key + "_file"} {
+			if v, ok := lookupEnv(getenv, envName(key)); ok {
+				env[key] = v
+			}
+		}
+	}
+
+	path := *configFile
+	if path == "" {
+		path = getenv(EnvPrefix + "CONFIG")
+	}
+	var file map[string]string
+	if path != "" {
+		var err error
+		file, err = readConfigFile(path, opts)
+		if err != nil {
+			return s, err
+		}
+	}
+
+	layers := []struct {
+		name   string
+		values layerValues
+	}{
+		{path, file},
+		{"environment", env},
+		{"flags", flags},
+	}
+
+	var problems []string
+	for _, o := range opts {
+		for _, l := range layers {
+			raw, ok, err := l.values.lookup(o)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s in %s: %s", o.key, l.name, err))
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if err := set(o.value, raw); err != nil {
+				problems = append(problems, fmt.Sprintf("%s in %s: %s", o.key, l.name, err))
+				continue
+			}
+			s.sources[o.key] = l.name
+		}
+	}
+
+	problems = append(problems, s.validate()...)
+	if len(problems) > 0 {
+		return s, fmt.Errorf("invalid config:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	return s, nil
+}
+
+// layerValues are the raw values of one source of settings, by key.
+type layerValues map[string]string
+
+// lookup returns the value of o in a layer, read from a file for a secret
+// given as key_file.
+func (values layerValues) lookup(o option) (string, bool, error) {
+	raw, ok := values[o.key]
+	path, fromFile := values[o.key+"_file"]
+	if ok && fromFile {
+		return "", false, fmt.Errorf("set both %s and %s_file", o.key, o.key)
+	}
+	if !fromFile {
+		return raw, ok, nil
+	}
+	if !o.secret {
+		return "", false, fmt.Errorf("only secrets can be read from a file")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("reading secret: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+func (s *Settings) validate() []string {
+	var problems []string
+	required := func(key, value string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, fmt.Sprintf(
+				"%s is required: set it in the config file, with %s or with -%s",
+				key, envName(key), key))
+		}
+	}
+	port := func(key string, value int) {
+		if value < 1 || value > 65535 {
+			problems = append(problems, fmt.Sprintf("%s must be from 1 to 65535, not %d", key, value))
+		}
+	}
+	positive := func(key string, value time.Duration) {
+		if value <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be longer than 0, not %s", key, value))
+		}
+	}
+
+	port("port", s.Port)
+	positive("purge_after", s.PurgeAfter)
+	positive("hold_for", s.HoldFor)
+	positive("shutdown_timeout", s.ShutdownTimeout)
+	if s.QueryCacheTTL < 0 {
+		problems = append(problems, fmt.Sprintf("query_cache_ttl must be 0 or longer, not %s", s.QueryCacheTTL))
+	}
+
+	if s.LogFormat != "text" && s.LogFormat != "json" {
+		problems = append(problems, fmt.Sprintf("log_format must be text or json, not %q", s.LogFormat))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s.LogLevel)); err != nil {
+		problems = append(problems, fmt.Sprintf(
+			"log_level must be debug, info, warn or error, not %q", s.LogLevel))
+	}
+
+	u, err := url.Parse(s.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, fmt.Sprintf(
+			"base_url must be an http or https URL such as https://example.com, not %q", s.BaseURL))
+	}
+	s.BaseURL = strings.TrimSuffix(s.BaseURL, "/")
+
+	switch s.Database.Driver {
+	case "memory":
+	case "postgres", "mysql":
+		if _, set := s.sources["database.port"]; !set && s.Database.Driver == "mysql" {
+			s.Database.Port = 3306
+		}
+		required("database.host", s.Database.Host)
+		port("database.port", s.Database.Port)
+		required("database.name", s.Database.Name)
+		required("database.user", s.Database.User)
+		switch s.Database.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			problems = append(problems, fmt.Sprintf(
+				"database.sslmode must be one of disable, allow, prefer, require, verify-ca or verify-full, not %q",
+				s.Database.SSLMode))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf(
+			"database.driver must be postgres, mysql or memory, not %q", s.Database.Driver))
+	}
+
+	if s.Session.IdleTimeout < 0 {
+		problems = append(problems, fmt.Sprintf(
+			"session.idle_timeout must be 0 or longer, not %s", s.Session.IdleTimeout))
+	}
+	positive("session.lifetime", s.Session.Lifetime)
+
+	required("smtp.host", s.SMTP.Host)
+	port("smtp.port", s.SMTP.Port)
+
+	if s.Security.HSTSMaxAge < 0 {
+		problems = append(problems, fmt.Sprintf(
+			"security.hsts_max_age must be 0 or longer, not %s", s.Security.HSTSMaxAge))
+	}
+	switch s.Security.FrameOptions {
+	case "", "DENY", "SAMEORIGIN":
+	default:
+		problems = append(problems, fmt.Sprintf(
+			"security.frame_options must be DENY, SAMEORIGIN or empty, not %q", s.Security.FrameOptions))
+	}
+
+	return problems
+}
+
+// Print writes the settings as YAML, noting where each one came from and
+// hiding secrets.
+func (s Settings) Print(w io.Writer) {
+	for _, o := range s.options() {
+		value := format(o.value)
+		if o.secret && value != `""` {
+			value = `"********"`
+		}
+		source := s.sources[o.key]
+		if source == "" {
+			source = "default"
+		}
+		fmt.Fprintf(w, "%s: %s # %s\n", o.key, value, source)
+	}
+}
+
+// readConfigFile parses a config file by its extension and rejects keys
+// that are not settings.
+func readConfigFile(path string, opts []option) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		values, err = parseYAML(string(data))
+	case ".toml":
+		values, err = parseTOML(string(data))
+	default:
+		return nil, fmt.Errorf("config %s: use a .yml, .yaml or .toml file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+
+	known := map[string]bool{}
+	for _, o := range opts {
+		known[o.key] = true
+		if o.secret {
+			known[o.key+"_file"] = true
+		}
+	}
+	for key := range values {
+		if !known[key] {
+			return nil, fmt.Errorf("config %s: unknown setting %s", path, key)
+		}
+	}
+
+	return values, nil
+}
+
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// lookupEnv treats an empty variable as unset, like most shells do.
+func lookupEnv(getenv func(string) string, name string) (string, bool) {
+	v := getenv(name)
+	return v, v != ""
+}
+
+func set(value interface{}, raw string) error {
+	switch v := value.(type) {
+	case *string:
+		*v = raw
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		*v = b
+	case *int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		*v = n
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 90m or 720h", raw)
+		}
+		*v = d
+	default:
+		return errors.New("unsupported setting type")
+	}
+	return nil
+}
+
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case *string:
+		return strconv.Quote(*v)
+	case *bool:
+		return strconv.FormatBool(*v)
+	case *int:
+		return strconv.Itoa(*v)
+	case *time.Duration:
+		return v.String()
+	}
+	return ""
+}
+
+func isBool(value interface{}) bool {
+	_, ok := value.(*bool)
+	return ok
+}
+
+// flagValue records a flag as a raw string so flags can be applied after
+// the config file and environment.
+type flagValue struct {
+	key    string
+	values map[string]string
+	isBool bool
+}
+
+func (f flagValue) String() string { return "" }
+
+func (f flagValue) Set(s string) error {
+	f.values[f.key] = s
+	return nil
+}
+
+func (f flagValue) IsBoolFlag() bool { return f.isBool }
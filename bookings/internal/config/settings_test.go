@@ -0,0 +1,229 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func env(values map[string]string) func(string) string {
+	return func(name string) string { return values[name] }
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	secret := writeFile(t, "db_password", "from-file\n")
+	yml := writeFile(t, "bookings.yml", `
+port: 9000
+database:
+  host: db.internal   # the database host
+  name: bookings
+  user: file-user
+  password_file: `+secret+`
+smtp:
+  host: "mail.internal"
+`)
+
+	s, err := Load([]string{"-config", yml, "-dbuser", "flag-user", "-production=false"},
+		env(map[string]string{
+			"BOOKINGS_PORT":          "9100",
+			"BOOKINGS_DATABASE_USER": "env-user",
+			"BOOKINGS_HOLD_FOR":      "2h",
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		got      interface{}
+		expected interface{}
+	}{
+		{"default", s.Cache, true},
+		{"default duration", s.QueryCacheTTL, time.Minute},
+		{"file", s.Database.Host, "db.internal"},
+		{"quoted file value", s.SMTP.Host, "mail.internal"},
+		{"env over file", s.Port, 9100},
+		{"flag over env", s.Database.User, "flag-user"},
+		{"bool flag", s.Production, false},
+		{"duration", s.HoldFor, 2 * time.Hour},
+		{"secret file", s.Database.Password, "from-file"},
+		{"address", s.Addr(), ":9100"},
+	}
+
+	for _, e := range tests {
+		if e.got != e.expected {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expected, e.got)
+		}
+	}
+}
+
+func TestLoadArgs(t *testing.T) {
+	s, err := Load([]string{"-dbname", "b", "-dbuser", "u", "users", "list", "-x"}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(s.Args, " ") != "users list -x" {
+		t.Errorf("expected the arguments after the flags but got %q", s.Args)
+	}
+}
+
+func TestLoadMemoryDriver(t *testing.T) {
+	s, err := Load([]string{"-database.driver", "memory"}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Database.Driver != "memory" {
+		t.Errorf("got driver %q, want memory", s.Database.Driver)
+	}
+}
+
+func TestMySQLDSN(t *testing.T) {
+	s, err := Load([]string{"-database.driver", "mysql", "-dbname", "bookings", "-dbuser", "u",
+		"-dbpass", "p@ss", "-dbssl", "require"}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "u:p@ss@tcp(localhost:3306)/bookings?multiStatements=true&parseTime=true&tls=skip-verify"
+	if s.Database.DSN() != expected {
+		t.Errorf("expected %q but got %q", expected, s.Database.DSN())
+	}
+
+	s, err = Load([]string{"-database.driver", "mysql", "-dbname", "b", "-dbuser", "u", "-dbport", "3307"}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Database.Port != 3307 {
+		t.Errorf("expected the port given to be kept but got %d", s.Database.Port)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	toml := writeFile(t, "bookings.toml", `
+base_url = "https://example.com/"
+
+[database]
+name = "bookings" # trailing comment
+user = 'o''brien'
+port = 6543
+`)
+
+	s, err := Load(nil, env(map[string]string{"BOOKINGS_CONFIG": toml}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Database.User != "o'brien" || s.Database.Port != 6543 {
+		t.Errorf("unexpected database settings %+v", s.Database)
+	}
+	if s.BaseURL != "https://example.com" {
+		t.Errorf("expected the trailing slash trimmed but got %s", s.BaseURL)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	yml := writeFile(t, "bookings.yml", "database:\n  hots: db\n")
+	ini := writeFile(t, "bookings.ini", "port=1\n")
+
+	var tests = []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected []string
+	}{
+		{"missing database", nil, nil,
+			[]string{"database.name is required", "BOOKINGS_DATABASE_NAME", "-database.name",
+				"database.user is required"}},
+		{"bad values", []string{"-dbname", "b", "-dbuser", "u", "-port", "0", "-base_url", "example.com"},
+			map[string]string{"BOOKINGS_DATABASE_PORT": "five", "BOOKINGS_HOLD_FOR": "a day"},
+			[]string{"port must be from 1 to 65535", "database.port in environment", "hold_for in environment",
+				"base_url must be an http or https URL"}},
+		{"bad sslmode", []string{"-dbname", "b", "-dbuser", "u", "-dbssl", "required"}, nil,
+			[]string{"database.sslmode must be one of"}},
+		{"bad logging", []string{"-dbname", "b", "-dbuser", "u", "-log_format", "xml"},
+			map[string]string{"BOOKINGS_LOG_LEVEL": "loud"},
+			[]string{"log_format must be text or json", "log_level must be debug, info, warn or error"}},
+		{"negative cache ttl", []string{"-dbname", "b", "-dbuser", "u", "-query_cache_ttl", "-1m"}, nil,
+			[]string{"query_cache_ttl must be 0 or longer, not -1m0s"}},
+		{"bad session", []string{"-dbname", "b", "-dbuser", "u", "-session.idle_timeout", "-1h"},
+			map[string]string{"BOOKINGS_SESSION_LIFETIME": "0s"},
+			[]string{"session.idle_timeout must be 0 or longer", "session.lifetime must be longer than 0"}},
+		{"bad security", []string{"-dbname", "b", "-dbuser", "u", "-security.frame_options", "ALLOW"},
+			map[string]string{"BOOKINGS_SECURITY_HSTS_MAX_AGE": "-1s"},
+			[]string{"security.hsts_max_age must be 0 or longer", `security.frame_options must be DENY, SAMEORIGIN or empty, not "ALLOW"`}},
+		{"bad driver", []string{"-database.driver", "sqlite"}, nil,
+			[]string{`database.driver must be postgres, mysql or memory, not "sqlite"`}},
+		{"unknown key", []string{"-config", yml}, nil, []string{"unknown setting database.hots"}},
+		{"unknown format", []string{"-config", ini}, nil, []string{"use a .yml, .yaml or .toml file"}},
+		{"missing secret", []string{"-dbname", "b", "-dbuser", "u", "-database.password_file", "/no/such/file"}, nil,
+			[]string{"database.password in flags: reading secret"}},
+		{"secret twice", []string{"-dbname", "b", "-dbuser", "u"},
+			map[string]string{"BOOKINGS_DATABASE_PASSWORD": "x", "BOOKINGS_DATABASE_PASSWORD_FILE": "y"},
+			[]string{"set both database.password and database.password_file"}},
+		{"file for a plain setting", []string{"-dbname", "b", "-dbuser", "u"},
+			map[string]string{"BOOKINGS_DATABASE_HOST_FILE": "y"},
+			[]string{"only secrets can be read from a file"}},
+	}
+
+	for _, e := range tests {
+		_, err := Load(e.args, env(e.env))
+		if err == nil {
+			t.Errorf("%s: expected an error", e.name)
+			continue
+		}
+		for _, text := range e.expected {
+			if !strings.Contains(err.Error(), text) {
+				t.Errorf("%s: expected %q in %q", e.name, text, err)
+			}
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	s, err := Load([]string{"-dbname", "bookings", "-dbuser", "u", "-dbpass", "hunter2",
+		"--print-config"}, env(map[string]string{"BOOKINGS_PORT": "9000"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.PrintConfig {
+		t.Error("expected --print-config to be set")
+	}
+
+	var buf bytes.Buffer
+	s.Print(&buf)
+	out := buf.String()
+
+	for _, text := range []string{
+		`database.password: "********" # flags`,
+		`smtp.password: "" # default`,
+		"port: 9000 # environment",
+		`database.name: "bookings" # flags`,
+	} {
+		if !strings.Contains(out, text) {
+			t.Errorf("expected %q in\n%s", text, out)
+		}
+	}
+	if strings.Contains(out, "hunter2") {
+		t.Error("a secret was printed")
+	}
+
+	// the printed config can be read back
+	values, err := parseYAML(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["database.name"] != "bookings" || values["port"] != "9000" {
+		t.Errorf("unexpected values read back %v", values)
+	}
+}
@@ -0,0 +1,57 @@
+// Package dates keeps the dates of stays consistent. A stay date is a
+// calendar day with no time or zone of its own: it is always held as midnight
+// UTC, however it was parsed and whatever zone the server runs in.
+package dates
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// Layout is the format of stay dates in forms, URLs and JSON.
+	Layout = "2006-01-02"
+	// MonthLayout is the format of months in forms and URLs.
+	MonthLayout = "2006-01"
+	// ClockLayout is the format of check-in and check-out times.
+	ClockLayout = "15:04"
+)
+
+// Parse reads a stay date written as yyyy-mm-dd.
+func Parse(s string) (time.Time, error) {
+	return time.ParseInLocation(Layout, s, time.UTC)
+}
+
+// ParseMonth reads a month written as yyyy-mm and returns its first day.
+func ParseMonth(s string) (time.Time, error) {
+	return time.ParseInLocation(MonthLayout, s, time.UTC)
+}
+
+// Day returns the calendar day t falls on in its own location, as a stay
+// date.
+func Day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Today returns the current calendar day in loc, as a stay date.
+func Today(loc *time.Location) time.Time {
+	return Day(time.Now().In(loc))
+}
+
+// ParseClock reads a time of day written as hh:mm and returns it as an
+// offset from midnight.
+func ParseClock(s string) (time.Duration, error) {
+	t, err := time.Parse(ClockLayout, s)
+	if err != nil {
+		return 0, errors.New("time must be written as hh:mm")
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Overlap reports whether two stays share a night. Stays run from the
+// arrival day up to but not including the departure day, so a guest leaving
+// on the day another arrives never conflicts with them.
+func Overlap(aStart, aEnd, bStart, bEnd time.Time) bool {
+	return aStart.Before(bEnd) && bStart.Before(aEnd)
+}
@@ -0,0 +1,64 @@
+package dates
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	d, err := Parse("2050-03-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Location() != time.UTC || d.Hour() != 0 {
+		t.Errorf("expected midnight UTC but got %s", d)
+	}
+
+	if _, err := Parse("2050-13-10"); err == nil {
+		t.Error("expected an error for an invalid date")
+	}
+}
+
+func TestDay(t *testing.T) {
+	lagos, err := time.LoadLocation("Africa/Lagos")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 00:30 on the 10th in Lagos is still the 9th in UTC
+	instant := time.Date(2050, 3, 10, 0, 30, 0, 0, lagos)
+	if Day(instant) != time.Date(2050, 3, 10, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("expected the 10th but got %s", Day(instant))
+	}
+	if Day(instant.UTC()) != time.Date(2050, 3, 9, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("expected the 9th but got %s", Day(instant.UTC()))
+	}
+}
+
+var overlapTests = []struct {
+	name     string
+	aStart   string
+	aEnd     string
+	bStart   string
+	bEnd     string
+	expected bool
+}{
+	{"same day turnover", "2050-03-01", "2050-03-05", "2050-03-05", "2050-03-07", false},
+	{"turnover the other way", "2050-03-05", "2050-03-07", "2050-03-01", "2050-03-05", false},
+	{"one night shared", "2050-03-01", "2050-03-05", "2050-03-04", "2050-03-07", true},
+	{"inside", "2050-03-01", "2050-03-10", "2050-03-04", "2050-03-05", true},
+	{"apart", "2050-03-01", "2050-03-03", "2050-03-05", "2050-03-07", false},
+}
+
+func TestOverlap(t *testing.T) {
+	for _, e := range overlapTests {
+		aStart, _ := Parse(e.aStart)
+		aEnd, _ := Parse(e.aEnd)
+		bStart, _ := Parse(e.bStart)
+		bEnd, _ := Parse(e.bEnd)
+
+		if got := Overlap(aStart, aEnd, bStart, bEnd); got != e.expected {
+			t.Errorf("%s: expected %t but got %t", e.name, e.expected, got)
+		}
+	}
+}
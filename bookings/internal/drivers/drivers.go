@@ -2,8 +2,10 @@ package drivers
 
 import (
 	"database/sql"
+	"fmt"
 	"time"
 
+	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/jackc/pgconn"
 	_ "github.com/jackc/pgx/v4"
 	_ "github.com/jackc/pgx/v4/stdlib"
@@ -11,6 +13,15 @@ import (
 
 type DB struct {
 	SQL *sql.DB
+	// Driver is the database connected to, postgres or mysql.
+	Driver string
+}
+
+// sqlDrivers are the database/sql drivers of the databases ConnectSQL
+// supports.
+var sqlDrivers = map[string]string{
+	"postgres": "pgx",
+	"mysql":    "mysql",
 }
 
 var dbConn = &DB{}
@@ -19,8 +30,9 @@ var maxOpenDBConn = 10
 var maxIdleDbConn = 5
 var maxDbConnLifetime = 5 * time.Minute
 
-func ConnectSQL(dsn string) (*DB, error) {
-	db, err := NewDatabase(dsn)
+// ConnectSQL connects to a postgres or mysql database.
+func ConnectSQL(driver, dsn string) (*DB, error) {
+	db, err := NewDatabase(driver, dsn)
 	if err != nil {
 		panic(err)
 	}
@@ -30,6 +42,7 @@ func ConnectSQL(dsn string) (*DB, error) {
 	db.SetConnMaxLifetime(maxDbConnLifetime)
 
 	dbConn.SQL = db
+	dbConn.Driver = driver
 
 	err = testDB(db)
 	if err != nil {
@@ -46,8 +59,13 @@ func testDB(d *sql.DB) error {
 	return nil
 }
 
-func NewDatabase(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("pgx", dsn)
+func NewDatabase(driver, dsn string) (*sql.DB, error) {
+	name, ok := sqlDrivers[driver]
+	if !ok {
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+
+	db, err := sql.Open(name, dsn)
 	if err != nil {
 		return nil, err
 	}
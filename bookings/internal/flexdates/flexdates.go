@@ -0,0 +1,60 @@
+// Package flexdates builds the alternative date ranges tried when a guest is
+// flexible about when they stay.
+package flexdates
+
+import (
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/rules"
+)
+
+// Range is a stay from Start (arrival) to End (departure).
+type Range struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Nights returns the number of nights in the range.
+func (r Range) Nights() int {
+	return rules.Nights(r.Start, r.End)
+}
+
+// Around returns the stays of the same length as start to end shifted by up
+// to flex days either way, nearest first and earlier first on a tie. The
+// requested range itself and stays arriving before today are left out.
+func Around(start, end time.Time, flex int, today time.Time) []Range {
+	var ranges []Range
+
+	for shift := 1; shift <= flex; shift++ {
+		for _, d := range []int{-shift, shift} {
+			r := Range{Start: start.AddDate(0, 0, d), End: end.AddDate(0, 0, d)}
+			if r.Start.Before(today) {
+				continue
+			}
+			ranges = append(ranges, r)
+		}
+	}
+
+	return ranges
+}
+
+// InMonth returns every stay of the given number of nights arriving in the
+// month of month, in date order. Stays arriving before today are left out.
+func InMonth(month time.Time, nights int, today time.Time) []Range {
+	var ranges []Range
+	if nights < 1 {
+		return ranges
+	}
+
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	next := first.AddDate(0, 1, 0)
+
+	for d := first; d.Before(next); d = d.AddDate(0, 0, 1) {
+		if d.Before(today) {
+			continue
+		}
+		ranges = append(ranges, Range{Start: d, End: d.AddDate(0, 0, nights)})
+	}
+
+	return ranges
+}
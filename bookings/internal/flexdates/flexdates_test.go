@@ -0,0 +1,61 @@
+package flexdates
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+func TestAround(t *testing.T) {
+	ranges := Around(date("2050-03-10"), date("2050-03-13"), 2, date("2050-01-01"))
+
+	expected := []string{"2050-03-09", "2050-03-11", "2050-03-08", "2050-03-12"}
+	if len(ranges) != len(expected) {
+		t.Fatalf("expected %d ranges but got %d", len(expected), len(ranges))
+	}
+	for i, r := range ranges {
+		if r.Start.Format("2006-01-02") != expected[i] {
+			t.Errorf("range %d: expected arrival %s but got %s", i, expected[i],
+				r.Start.Format("2006-01-02"))
+		}
+		if r.Nights() != 3 {
+			t.Errorf("range %d: expected 3 nights but got %d", i, r.Nights())
+		}
+	}
+}
+
+func TestAroundSkipsPast(t *testing.T) {
+	ranges := Around(date("2050-03-10"), date("2050-03-13"), 2, date("2050-03-10"))
+
+	for _, r := range ranges {
+		if r.Start.Before(date("2050-03-10")) {
+			t.Errorf("got a range arriving in the past: %s", r.Start)
+		}
+	}
+	if len(ranges) != 2 {
+		t.Errorf("expected 2 ranges but got %d", len(ranges))
+	}
+}
+
+func TestInMonth(t *testing.T) {
+	ranges := InMonth(date("2050-02-14"), 3, date("2050-01-01"))
+	if len(ranges) != 28 {
+		t.Fatalf("expected 28 ranges but got %d", len(ranges))
+	}
+	if ranges[0].Start != date("2050-02-01") || ranges[0].End != date("2050-02-04") {
+		t.Errorf("first range is %s to %s", ranges[0].Start, ranges[0].End)
+	}
+
+	ranges = InMonth(date("2050-02-01"), 3, date("2050-02-20"))
+	if len(ranges) != 9 {
+		t.Errorf("expected 9 ranges from the 20th but got %d", len(ranges))
+	}
+
+	if len(InMonth(date("2050-02-01"), 0, date("2050-01-01"))) != 0 {
+		t.Error("expected no ranges for zero nights")
+	}
+}
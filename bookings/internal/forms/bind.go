@@ -0,0 +1,426 @@
+package forms
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/chenemiken/goland/bookings/internal/dates"
+)
+
+// Validator checks a bound field value for the func= rule. It returns the
+// message to show next to the field, or "" when the value is acceptable.
+type Validator func(value interface{}) string
+
+var (
+	validatorsMu sync.RWMutex
+	validators   = map[string]Validator{}
+	bounds       = map[string]int{}
+	patterns     sync.Map
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// phonePattern accepts 7 to 15 digits, optionally grouped with spaces, dots,
+// dashes or brackets and led by a +.
+var phonePattern = regexp.MustCompile(`^\+?[0-9 ().-]{7,30}$`)
+
+// RegisterValidator makes v available to struct tags as func=name. It is
+// meant to be called from init functions.
+func RegisterValidator(name string, v Validator) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	validators[name] = v
+}
+
+// RegisterBound makes n available to range rules as name, so a limit kept in
+// a constant need not be repeated in struct tags. It is meant to be called
+// from init functions.
+func RegisterBound(name string, n int) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	bounds[name] = n
+}
+
+type boundField struct {
+	name   string
+	value  reflect.Value
+	rules  string
+	layout string
+	ok     bool
+}
+
+// Bind decodes the form into the struct dst points to and validates it,
+// adding any problems to f.Errors under the form field name.
+//
+// Each exported field is read from the form value named by its `form` tag
+// ("-" skips the field). Strings, ints, uints, floats, bools, time.Time and
+// slices of those are supported; dates are read with the `layout` tag, or
+// dates.Layout. A field whose form value is empty keeps the value it had, so
+// defaults can be set before binding.
+//
+// The `validate` tag holds comma separated rules, checked in order until one
+// fails. Apart from required they skip empty values.
+//
+//	required      the value must not be empty
+//	min=n, max=n  length of a string, count of a slice or size of a number
+//	range=lo:hi   whole number from lo to hi, each a number or a name
+//	              added with RegisterBound
+//	email, phone  an email address or a phone number
+//	oneof=a|b     one of the listed values, for every item of a slice
+//	after=field   a date later than the date in field
+//	func=name     a Validator added with RegisterValidator
+//	regex=expr    must match expr; being free text, it has to come last
+func (f *Form) Bind(dst interface{}) bool {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("forms: Bind needs a pointer to a struct, not %T", dst))
+	}
+	v = v.Elem()
+
+	// decode everything first so after= can look at fields declared later
+	var fields []*boundField
+	byName := make(map[string]*boundField)
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		name := sf.Tag.Get("form")
+		if sf.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		b := &boundField{
+			name:   name,
+			value:  v.Field(i),
+			rules:  sf.Tag.Get("validate"),
+			layout: sf.Tag.Get("layout"),
+		}
+		if b.layout == "" {
+			b.layout = dates.Layout
+		}
+		b.ok = f.decode(b)
+
+		fields = append(fields, b)
+		byName[name] = b
+	}
+
+	for _, b := range fields {
+		if b.ok {
+			f.validate(b, byName)
+		}
+	}
+
+	return f.Valid()
+}
+
+func (f *Form) decode(b *boundField) bool {
+	if b.value.Kind() == reflect.Slice {
+		var items []string
+		for _, item := range f.Values[b.name] {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		if len(items) == 0 {
+			return true
+		}
+
+		s := reflect.MakeSlice(b.value.Type(), 0, len(items))
+		for _, item := range items {
+			ev := reflect.New(b.value.Type().Elem()).Elem()
+			if msg := parse(item, ev, b.layout); msg != "" {
+				f.Errors.Add(b.name, f.T(msg))
+				return false
+			}
+			s = reflect.Append(s, ev)
+		}
+		b.value.Set(s)
+		return true
+	}
+
+	raw := strings.TrimSpace(f.Get(b.name))
+	if raw == "" {
+		return true
+	}
+	if msg := parse(raw, b.value, b.layout); msg != "" {
+		f.Errors.Add(b.name, f.T(msg))
+		return false
+	}
+	return true
+}
+
+// parse sets v from s and returns the message to show when s does not fit.
+func parse(s string, v reflect.Value, layout string) string {
+	if v.Type() == timeType {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return "Invalid date"
+		}
+		v.Set(reflect.ValueOf(t))
+		return ""
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return "This field must be a whole number"
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return "This field must be a whole number"
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return "This field must be a number"
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		switch strings.ToLower(s) {
+		case "1", "on", "true", "yes":
+			v.SetBool(true)
+		case "0", "off", "false", "no":
+			v.SetBool(false)
+		default:
+			return "Invalid value"
+		}
+	default:
+		panic(fmt.Sprintf("forms: cannot bind a field of type %s", v.Type()))
+	}
+	return ""
+}
+
+func (f *Form) validate(b *boundField, byName map[string]*boundField) {
+	empty := strings.TrimSpace(f.Get(b.name)) == ""
+	if b.value.Kind() == reflect.Slice {
+		empty = b.value.Len() == 0
+	}
+
+	for _, rule := range splitRules(b.rules) {
+		name, arg, _ := strings.Cut(rule, "=")
+
+		if name == "required" {
+			if empty {
+				f.Errors.Add(b.name, f.T("This field is required"))
+				return
+			}
+			continue
+		}
+		if empty {
+			return
+		}
+
+		if msg := f.checkRule(name, arg, b, byName); msg != "" {
+			f.Errors.Add(b.name, msg)
+			return
+		}
+	}
+}
+
+// checkRule applies one rule to a field that has a value and returns the
+// translated message when it fails.
+func (f *Form) checkRule(name, arg string, b *boundField,
+	byName map[string]*boundField) string {
+
+	v := b.value
+	switch name {
+	case "min", "max":
+		limit, err := strconv.Atoi(arg)
+		if err != nil {
+			panic(fmt.Sprintf("forms: %s needs a whole number, not %q", name, arg))
+		}
+		return f.checkSize(name, limit, v)
+
+	case "range":
+		lo, hi, err := parseRange(arg)
+		if err != nil {
+			panic(fmt.Sprintf("forms: range needs lo:hi, not %q", arg))
+		}
+		n := numberOf(v)
+		if n < float64(lo) || n > float64(hi) {
+			return f.T("This field must be a number from %d to %d", lo, hi)
+		}
+
+	case "email":
+		if !govalidator.IsEmail(v.String()) {
+			return f.T("Invalid email address")
+		}
+
+	case "phone":
+		digits := 0
+		for _, c := range v.String() {
+			if c >= '0' && c <= '9' {
+				digits++
+			}
+		}
+		if !phonePattern.MatchString(v.String()) || digits < 7 || digits > 15 {
+			return f.T("Invalid phone number")
+		}
+
+	case "regex":
+		if !pattern(arg).MatchString(v.String()) {
+			return f.T("This field is not in the expected format")
+		}
+
+	case "oneof":
+		options := strings.Split(arg, "|")
+		items := []reflect.Value{v}
+		if v.Kind() == reflect.Slice {
+			items = items[:0]
+			for i := 0; i < v.Len(); i++ {
+				items = append(items, v.Index(i))
+			}
+		}
+		for _, item := range items {
+			if !contains(options, fmt.Sprint(item.Interface())) {
+				return f.T("Pick one of the listed options")
+			}
+		}
+
+	case "after":
+		other, ok := byName[arg]
+		if !ok || other.value.Type() != timeType || v.Type() != timeType {
+			panic(fmt.Sprintf("forms: after=%s needs two date fields", arg))
+		}
+		earlier := other.value.Interface().(time.Time)
+		if !other.ok || earlier.IsZero() {
+			// the other field has its own message
+			return ""
+		}
+		if !v.Interface().(time.Time).After(earlier) {
+			return f.T("This date must be after %s", earlier.Format(other.layout))
+		}
+
+	case "func":
+		validatorsMu.RLock()
+		fn, ok := validators[arg]
+		validatorsMu.RUnlock()
+		if !ok {
+			panic(fmt.Sprintf("forms: no validator registered as %q", arg))
+		}
+		if msg := fn(v.Interface()); msg != "" {
+			return f.T(msg)
+		}
+
+	default:
+		panic(fmt.Sprintf("forms: unknown validation rule %q", name))
+	}
+
+	return ""
+}
+
+func (f *Form) checkSize(name string, limit int, v reflect.Value) string {
+	switch {
+	case v.Kind() == reflect.String:
+		n := utf8.RuneCountInString(v.String())
+		if name == "min" && n < limit {
+			return f.T("This field must be at least %d characters long", limit)
+		}
+		if name == "max" && n > limit {
+			return f.T("This field must be at most %d characters long", limit)
+		}
+	case v.Kind() == reflect.Slice:
+		if name == "min" && v.Len() < limit {
+			return f.T("Pick at least %d options", limit)
+		}
+		if name == "max" && v.Len() > limit {
+			return f.T("Pick at most %d options", limit)
+		}
+	default:
+		n := numberOf(v)
+		if name == "min" && n < float64(limit) {
+			return f.T("This field must be at least %v", limit)
+		}
+		if name == "max" && n > float64(limit) {
+			return f.T("This field must be at most %v", limit)
+		}
+	}
+	return ""
+}
+
+// splitRules splits a validate tag at its commas, leaving a trailing regex
+// rule whole.
+func splitRules(tag string) []string {
+	var rules []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(rules, tag)
+		}
+		rule, rest, found := strings.Cut(tag, ",")
+		rules = append(rules, strings.TrimSpace(rule))
+		if !found {
+			break
+		}
+		tag = rest
+	}
+	return rules
+}
+
+func parseRange(arg string) (int, int, error) {
+	lo, hi, _ := strings.Cut(arg, ":")
+	min, err := parseBound(lo)
+	if err != nil {
+		return 0, 0, err
+	}
+	max, err := parseBound(hi)
+	if err != nil {
+		return 0, 0, err
+	}
+	return min, max, nil
+}
+
+// parseBound reads one end of a range, a whole number or a registered name.
+func parseBound(s string) (int, error) {
+	validatorsMu.RLock()
+	n, ok := bounds[s]
+	validatorsMu.RUnlock()
+	if ok {
+		return n, nil
+	}
+	return strconv.Atoi(s)
+}
+
+func numberOf(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	panic(fmt.Sprintf("forms: cannot compare a field of type %s with a number", v.Type()))
+}
+
+// pattern compiles a regex rule once and keeps it for later requests.
+func pattern(expr string) *regexp.Regexp {
+	if re, ok := patterns.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(expr)
+	patterns.Store(expr, re)
+	return re
+}
+
+func contains(options []string, s string) bool {
+	for _, o := range options {
+		if o == s {
+			return true
+		}
+	}
+	return false
+}
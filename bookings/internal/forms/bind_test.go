@@ -0,0 +1,208 @@
+package forms
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bookingInput struct {
+	Name      string    `form:"name" validate:"required,min=3,max=10"`
+	Email     string    `form:"email" validate:"email"`
+	Phone     string    `form:"phone" validate:"phone"`
+	Start     time.Time `form:"start" validate:"required"`
+	End       time.Time `form:"end" validate:"required,after=start"`
+	Month     time.Time `form:"month" layout:"2006-01"`
+	Adults    int       `form:"adults" validate:"range=1:4"`
+	Rooms     int       `form:"rooms" validate:"range=1:maxRooms"`
+	Rate      float64   `form:"rate" validate:"min=10,max=500"`
+	Breakfast bool      `form:"breakfast"`
+	Meal      string    `form:"meal" validate:"oneof=none|half|full"`
+	Extras    []string  `form:"extras" validate:"max=2,oneof=cot|parking|pet"`
+	Nights    []int     `form:"nights"`
+	Code      string    `form:"code" validate:"regex=^[A-Z]{3}-[0-9]{2,}$"`
+	Promo     string    `form:"promo" validate:"func=promo"`
+	Ignored   string    `form:"-"`
+	internal  string
+}
+
+func init() {
+	RegisterBound("maxRooms", 3)
+	RegisterValidator("promo", func(value interface{}) string {
+		if !strings.HasPrefix(value.(string), "SUMMER") {
+			return "Invalid value"
+		}
+		return ""
+	})
+}
+
+func TestBind(t *testing.T) {
+	postedData := url.Values{}
+	postedData.Add("name", " John ")
+	postedData.Add("email", "john@email.com")
+	postedData.Add("phone", "+44 (0)20 7946-0958")
+	postedData.Add("start", "2050-01-02")
+	postedData.Add("end", "2050-01-04")
+	postedData.Add("month", "2050-03")
+	postedData.Add("adults", "2")
+	postedData.Add("rooms", "3")
+	postedData.Add("rate", "99.5")
+	postedData.Add("breakfast", "on")
+	postedData.Add("meal", "half")
+	postedData.Add("extras", "cot")
+	postedData.Add("extras", "parking")
+	postedData.Add("nights", "1")
+	postedData.Add("nights", "3")
+	postedData.Add("code", "ABC-123")
+	postedData.Add("promo", "SUMMER50")
+	postedData.Add("Ignored", "x")
+
+	var input bookingInput
+	form := New(postedData)
+	if !form.Bind(&input) {
+		t.Fatalf("expected a valid form but got errors %v", form.Errors)
+	}
+
+	if input.Name != "John" {
+		t.Errorf("expected trimmed name but got %q", input.Name)
+	}
+	if !input.Start.Equal(time.Date(2050, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("start bound to %s", input.Start)
+	}
+	if !input.Month.Equal(time.Date(2050, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("month bound to %s", input.Month)
+	}
+	if input.Adults != 2 || input.Rooms != 3 || input.Rate != 99.5 || !input.Breakfast {
+		t.Errorf("numbers and bools bound to %d, %d, %v, %v", input.Adults, input.Rooms,
+			input.Rate, input.Breakfast)
+	}
+	if len(input.Extras) != 2 || input.Extras[1] != "parking" {
+		t.Errorf("extras bound to %v", input.Extras)
+	}
+	if len(input.Nights) != 2 || input.Nights[1] != 3 {
+		t.Errorf("nights bound to %v", input.Nights)
+	}
+	if input.Ignored != "" {
+		t.Error("a field tagged - was bound")
+	}
+}
+
+func TestBindDefaults(t *testing.T) {
+	postedData := url.Values{}
+	postedData.Add("name", "John")
+	postedData.Add("start", "2050-01-02")
+	postedData.Add("end", "2050-01-04")
+
+	input := bookingInput{Adults: 1, Meal: "none"}
+	form := New(postedData)
+	if !form.Bind(&input) {
+		t.Fatalf("expected a valid form but got errors %v", form.Errors)
+	}
+	if input.Adults != 1 || input.Meal != "none" {
+		t.Errorf("empty values overwrote the defaults: %d, %q", input.Adults, input.Meal)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	var tests = []struct {
+		name     string
+		field    string
+		value    []string
+		expected string
+	}{
+		{"missing required", "name", []string{""}, "This field is required"},
+		{"too short", "name", []string{"Jo"}, "This field must be at least 3 characters long"},
+		{"too long", "name", []string{"Johnathan Smith"}, "This field must be at most 10 characters long"},
+		{"bad email", "email", []string{"john"}, "Invalid email address"},
+		{"bad phone", "phone", []string{"call me"}, "Invalid phone number"},
+		{"short phone", "phone", []string{"12 34"}, "Invalid phone number"},
+		{"bad date", "start", []string{"2050-13-01"}, "Invalid date"},
+		{"end before start", "end", []string{"2050-01-01"}, "This date must be after 2050-01-02"},
+		{"end on start", "end", []string{"2050-01-02"}, "This date must be after 2050-01-02"},
+		{"not a number", "adults", []string{"two"}, "This field must be a whole number"},
+		{"out of range", "adults", []string{"5"}, "This field must be a number from 1 to 4"},
+		{"out of named range", "rooms", []string{"4"}, "This field must be a number from 1 to 3"},
+		{"not a float", "rate", []string{"cheap"}, "This field must be a number"},
+		{"below min", "rate", []string{"9.99"}, "This field must be at least 10"},
+		{"above max", "rate", []string{"501"}, "This field must be at most 500"},
+		{"bad bool", "breakfast", []string{"maybe"}, "Invalid value"},
+		{"not one of", "meal", []string{"brunch"}, "Pick one of the listed options"},
+		{"slice item not one of", "extras", []string{"cot", "pool"}, "Pick one of the listed options"},
+		{"too many items", "extras", []string{"cot", "parking", "pet"}, "Pick at most 2 options"},
+		{"bad slice item", "nights", []string{"1", "x"}, "This field must be a whole number"},
+		{"regex", "code", []string{"AB-1"}, "This field is not in the expected format"},
+		{"custom func", "promo", []string{"WINTER"}, "Invalid value"},
+	}
+
+	for _, e := range tests {
+		postedData := url.Values{}
+		postedData.Add("name", "John")
+		postedData.Add("start", "2050-01-02")
+		postedData.Add("end", "2050-01-04")
+		postedData[e.field] = e.value
+
+		var input bookingInput
+		form := New(postedData)
+		if form.Bind(&input) {
+			t.Errorf("%s: expected an invalid form", e.name)
+		}
+		if got := form.Errors.Get(e.field); got != e.expected {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, got)
+		}
+		if len(form.Errors) != 1 {
+			t.Errorf("%s: expected one field in error but got %v", e.name, form.Errors)
+		}
+	}
+}
+
+func TestBindLocale(t *testing.T) {
+	postedData := url.Values{}
+	postedData.Add("start", "2050-01-04")
+	postedData.Add("end", "2050-01-02")
+
+	var input bookingInput
+	form := New(postedData)
+	form.Locale = "es"
+	form.Bind(&input)
+
+	if form.Errors.Get("name") != "Este campo es obligatorio" {
+		t.Errorf("expected a Spanish message but got %q", form.Errors.Get("name"))
+	}
+	if form.Errors.Get("end") != "Esta fecha debe ser posterior al 2050-01-04" {
+		t.Errorf("expected a Spanish message but got %q", form.Errors.Get("end"))
+	}
+}
+
+func TestBindPanics(t *testing.T) {
+	var tests = []struct {
+		name string
+		dst  interface{}
+	}{
+		{"not a pointer", bookingInput{}},
+		{"unknown rule", &struct {
+			Name string `form:"name" validate:"shiny"`
+		}{}},
+		{"unknown bound", &struct {
+			Name string `form:"name" validate:"range=1:nope"`
+		}{}},
+		{"unknown func", &struct {
+			Name string `form:"name" validate:"func=nope"`
+		}{}},
+		{"unsupported type", &struct {
+			Name map[string]string `form:"name"`
+		}{}},
+	}
+
+	for _, e := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected Bind to panic", e.name)
+				}
+			}()
+			form := New(url.Values{"name": []string{"John"}})
+			form.Bind(e.dst)
+		}()
+	}
+}
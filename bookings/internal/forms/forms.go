@@ -1,16 +1,20 @@
 package forms
 
 import (
-	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/chenemiken/goland/bookings/internal/i18n"
 )
 
 type Form struct {
 	url.Values
 	Errors errors
+	// Locale is the language validation messages are written in, English
+	// when empty.
+	Locale string
 }
 
 func (f *Form) Valid() bool {
@@ -21,6 +25,7 @@ func New(data url.Values) *Form {
 	return &Form{
 		data,
 		errors(map[string][]string{}),
+		"",
 	}
 }
 
@@ -37,7 +42,7 @@ func (f *Form) Required(fields ...string) {
 	for _, field := range fields {
 		value := f.Get(field)
 		if strings.TrimSpace(value) == "" {
-			f.Errors.Add(field, "This field is required")
+			f.Errors.Add(field, f.T("This field is required"))
 		}
 	}
 }
@@ -46,7 +51,7 @@ func (f *Form) MinLength(field string, length int) bool {
 	value := f.Get(field)
 
 	if len(strings.TrimSpace(value)) < length {
-		f.Errors.Add(field, fmt.Sprintf("This field must be at least %d characters long",
+		f.Errors.Add(field, f.T("This field must be at least %d characters long",
 			length))
 		return false
 	}
@@ -55,6 +60,28 @@ func (f *Form) MinLength(field string, length int) bool {
 
 func (f *Form) IsEmail(field string) {
 	if !govalidator.IsEmail(f.Get(field)) {
-		f.Errors.Add(field, "Invalid email address")
+		f.Errors.Add(field, f.T("Invalid email address"))
 	}
 }
+
+// IntRange checks that field holds a whole number between min and max,
+// inclusive. Empty fields are left to Required.
+func (f *Form) IntRange(field string, min, max int) bool {
+	value := strings.TrimSpace(f.Get(field))
+	if value == "" {
+		return true
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		f.Errors.Add(field, f.T("This field must be a number from %d to %d",
+			min, max))
+		return false
+	}
+	return true
+}
+
+// T translates a validation message into the locale of the form.
+func (f *Form) T(msg string, args ...interface{}) string {
+	return i18n.T(f.Locale, msg, args...)
+}
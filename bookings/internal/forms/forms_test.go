@@ -106,3 +106,45 @@ func TestIsEmail(t *testing.T) {
 		t.Errorf("isEmail validator failed for value %s", form.Get("email2"))
 	}
 }
+
+func TestForm_IntRange(t *testing.T) {
+	postData := url.Values{}
+	postData.Add("adults", "2")
+	postData.Add("children", "-1")
+	postData.Add("pets", "two")
+
+	form := New(postData)
+
+	if !form.IntRange("adults", 1, 10) {
+		t.Error("got out of range for a number inside the range")
+	}
+	if form.IntRange("children", 0, 10) {
+		t.Error("got in range for a number below the range")
+	}
+	if form.IntRange("pets", 0, 10) {
+		t.Error("got in range for a value that is not a number")
+	}
+	if !form.IntRange("missing", 0, 10) {
+		t.Error("got out of range for an empty field")
+	}
+	if form.Errors.Get("children") == "" {
+		t.Error("should have an error for children but did not get one")
+	}
+}
+
+func TestForm_Locale(t *testing.T) {
+	postedData := url.Values{}
+	postedData.Add("email", "not an email")
+	form := New(postedData)
+	form.Locale = "fr"
+
+	form.Required("name")
+	form.IsEmail("email")
+
+	if form.Errors.Get("name") != "Ce champ est obligatoire" {
+		t.Errorf("expected a French required message but got %q", form.Errors.Get("name"))
+	}
+	if form.Errors.Get("email") != "Adresse e-mail invalide" {
+		t.Errorf("expected a French email message but got %q", form.Errors.Get("email"))
+	}
+}
@@ -1,36 +1,68 @@
 package handlers
 
 import (
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/chenemiken/goland/bookings/helpers"
 	"github.com/chenemiken/goland/bookings/internal/config"
+	"github.com/chenemiken/goland/bookings/internal/dates"
 	"github.com/chenemiken/goland/bookings/internal/drivers"
+	"github.com/chenemiken/goland/bookings/internal/flexdates"
 	"github.com/chenemiken/goland/bookings/internal/forms"
+	"github.com/chenemiken/goland/bookings/internal/i18n"
+	"github.com/chenemiken/goland/bookings/internal/metrics"
 	"github.com/chenemiken/goland/bookings/internal/models"
 	"github.com/chenemiken/goland/bookings/internal/render"
 	"github.com/chenemiken/goland/bookings/internal/repository"
 	"github.com/chenemiken/goland/bookings/internal/repository/dbrepo"
+	"github.com/chenemiken/goland/bookings/internal/rules"
+	"github.com/chenemiken/goland/bookings/internal/waitlist"
 	// "github.com/go-chi/chi/v5"
 )
 
 type Repository struct {
 	App *config.AppConfig
 	DB  repository.DatabaseRepo
+	// Sessions is nil when scs keeps the sessions in memory, where they
+	// cannot be told apart by user.
+	Sessions UserSessions
+}
+
+// UserSessions ends every session a user is logged in with.
+type UserSessions interface {
+	DeleteUserSessions(userID int) (int, error)
 }
 
 var Repo *Repository
 
+// NewRepo creates a repository for the database, caching rooms and
+// availability for a.QueryCacheTTL when it is set.
 func NewRepo(db *drivers.DB, a *config.AppConfig) *Repository {
+	repo := dbrepo.NewSQLRepo(db.Driver, db.SQL, a)
+	if a.QueryCacheTTL > 0 {
+		repo = dbrepo.NewCachedRepo(repo, a.QueryCacheTTL)
+	}
+	return &Repository{
+		App: a,
+		DB:  repo,
+	}
+}
+
+// NewMemoryRepo creates a repository keeping its data in memory, for
+// running the app without a database server.
+func NewMemoryRepo(a *config.AppConfig) *Repository {
 	return &Repository{
 		App: a,
-		DB:  dbrepo.NewPostgresRepo(db.SQL, a),
+		DB:  dbrepo.NewMemoryRepo(a),
 	}
 }
 
@@ -46,29 +78,54 @@ func NewHandlers(r *Repository) {
 }
 
 func (m *Repository) Home(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "home.page.html", &models.TemplateData{})
+	renderPage(w, r, "home.page.html", &models.TemplateData{})
 }
 
 func (m *Repository) About(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "about.page.html", &models.TemplateData{})
+	renderPage(w, r, "about.page.html", &models.TemplateData{})
 }
 
 func (m *Repository) Reservation(w http.ResponseWriter, r *http.Request) {
 	resvn, ok := m.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 	if !ok {
 		m.App.Session.Put(r.Context(), "error", "could not get session from session")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
 		return
 	}
 	data := make(map[string]interface{})
 
-	room, err := m.DB.GetRoomById(resvn.RoomID)
-	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "could not get room by id")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+	var room models.Room
+	if resvn.RoomID == 0 && resvn.RoomTypeID > 0 {
+		// the guest picked a room type, a unit is assigned when they book
+		roomType, err := m.DB.GetRoomTypeByID(resvn.RoomTypeID)
+		if err != nil {
+			m.App.Session.Put(r.Context(), "error", "could not get room type by id")
+			http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+			return
+		}
+		room = models.Room{
+			RoomName:   roomType.TypeName,
+			PropertyID: roomType.PropertyID,
+			RoomTypeID: roomType.ID,
+		}
+	} else {
+		var err error
+		room, err = m.DB.GetRoomById(resvn.RoomID)
+		if err != nil {
+			m.App.Session.Put(r.Context(), "error", "could not get room by id")
+			http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+			return
+		}
+	}
+	if room.PropertyID != helpers.CurrentProperty(r).ID {
+		m.App.Session.Put(r.Context(), "error", "room does not belong to this property")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
 		return
 	}
 	resvn.Room = room
+	if resvn.Adults < 1 {
+		resvn.Adults = 1
+	}
 	m.App.Session.Put(r.Context(), "reservation", resvn)
 	data["reservation"] = resvn
 
@@ -79,7 +136,7 @@ func (m *Repository) Reservation(w http.ResponseWriter, r *http.Request) {
 	stringData["start_date"] = sd
 	stringData["end_date"] = ed
 
-	render.Template(w, r, "make-reservation.page.html", &models.TemplateData{
+	renderPage(w, r, "make-reservation.page.html", &models.TemplateData{
 		Form:      forms.New(nil),
 		Data:      data,
 		StringMap: stringData,
@@ -90,59 +147,47 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		m.App.Session.Put(r.Context(), "error", "could not parse form")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
-		return
-	}
-
-	sd := r.Form.Get("start_date")
-	ed := r.Form.Get("end_date")
-
-	startDate, err := time.Parse("2006-01-02", sd)
-	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "could not parse start_date")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
 		return
 	}
 
-	endDate, err := time.Parse("2006-01-02", ed)
-	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "could not parse end_date")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
-		return
-	}
-
-	roomID, err := strconv.Atoi(r.Form.Get("room_id"))
-	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "could not parse room_id")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
-		return
+	input := reservationInput{Adults: 1}
+	form := forms.New(r.PostForm)
+	form.Locale = helpers.CurrentLocale(r)
+	form.Bind(&input)
+
+	// the stay and room come from hidden fields, so a bad value there is not
+	// something the guest can fix on the form
+	for _, hidden := range []string{"start_date", "end_date", "room_id", "room_type_id"} {
+		if form.Errors.Get(hidden) != "" {
+			m.App.Session.Put(r.Context(), "error", "could not parse "+hidden)
+			http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+			return
+		}
 	}
 
 	reservation := models.Reservation{
-		FirstName: r.Form.Get("first_name"),
-		LastName:  r.Form.Get("last_name"),
-		Email:     r.Form.Get("email"),
-		Phone:     r.Form.Get("phone"),
-		StartDate: startDate,
-		EndDate:   endDate,
-		RoomID:    roomID,
+		FirstName:  input.FirstName,
+		LastName:   input.LastName,
+		Email:      input.Email,
+		Phone:      input.Phone,
+		StartDate:  input.StartDate,
+		EndDate:    input.EndDate,
+		RoomID:     input.RoomID,
+		RoomTypeID: input.RoomTypeID,
+		Adults:     input.Adults,
+		Children:   input.Children,
 	}
 
 	stringData := make(map[string]string)
-	stringData["start_date"] = sd
-	stringData["end_date"] = ed
-
-	form := forms.New(r.PostForm)
-	// form.Has("first_name", r)
-	form.Required("first_name", "last_name", "email")
-	form.MinLength("first_name", 3)
-	form.IsEmail("email")
+	stringData["start_date"] = r.Form.Get("start_date")
+	stringData["end_date"] = r.Form.Get("end_date")
 
 	if !form.Valid() {
 		data := make(map[string]interface{})
 		data["reservation"] = reservation
 		http.Error(w, "invalid form input", http.StatusSeeOther)
-		render.Template(w, r, "make-reservation.page.html", &models.TemplateData{
+		renderPage(w, r, "make-reservation.page.html", &models.TemplateData{
 			Form:      form,
 			Data:      data,
 			StringMap: stringData,
@@ -150,114 +195,622 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newResID, err := m.DB.InsertReservation(reservation)
+	// the room or room type is posted, so it must be checked to belong to
+	// this property as on the form
+	if reservation.RoomID == 0 {
+		roomType, err := m.DB.GetRoomTypeByID(reservation.RoomTypeID)
+		if err != nil {
+			m.App.Session.Put(r.Context(), "error", "could not get room type by id")
+			http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+			return
+		}
+		if roomType.PropertyID != helpers.CurrentProperty(r).ID {
+			m.App.Session.Put(r.Context(), "error", "room does not belong to this property")
+			http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+			return
+		}
+	} else {
+		var err error
+		reservation.Room, err = m.DB.GetRoomById(reservation.RoomID)
+		if err != nil {
+			m.App.Session.Put(r.Context(), "error", "could not get room by id")
+			http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+			return
+		}
+		if reservation.Room.PropertyID != helpers.CurrentProperty(r).ID {
+			m.App.Session.Put(r.Context(), "error", "room does not belong to this property")
+			http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+			return
+		}
+	}
+
+	// the other free units of the room type, tried in turn should the one
+	// assigned be taken by someone booking at the same time
+	var otherUnits []models.Room
+	if reservation.RoomID == 0 {
+		// assign the first free unit of the chosen room type
+		rooms, err := m.DB.AvailableRoomsForType(reservation.RoomTypeID,
+			reservation.StartDate, reservation.EndDate)
+		if err != nil {
+			m.App.Session.Put(r.Context(), "error", "could not search rooms from DB")
+			http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+			return
+		}
+		if len(rooms) == 0 {
+			m.App.Session.Put(r.Context(), "error",
+				"sorry, this room type has just been fully booked for your dates")
+			http.Redirect(w, r, helpers.PropertyURL(r, "/search-availability"),
+				http.StatusSeeOther)
+			return
+		}
+		reservation.Room = rooms[0]
+		for _, room := range rooms {
+			if roomSleeps(room, reservation.Guests()) {
+				reservation.Room = room
+				break
+			}
+		}
+		reservation.RoomID = reservation.Room.ID
+		for _, room := range rooms {
+			if room.ID != reservation.RoomID && roomSleeps(room, reservation.Guests()) {
+				otherUnits = append(otherUnits, room)
+			}
+		}
+	}
+
+	roomRules, err := m.DB.GetRulesForRoomByDate(reservation.RoomID,
+		reservation.StartDate, reservation.EndDate)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "could not insert reservation to DB")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		m.App.Session.Put(r.Context(), "error", "could not search stay rules from DB")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+		return
+	}
+	if violations := rules.Check(roomRules, reservation.StartDate,
+		reservation.EndDate, helpers.CurrentLocale(r)); len(violations) > 0 {
+		m.App.Session.Put(r.Context(), "error", strings.Join(violations, ". "))
+		http.Redirect(w, r, helpers.PropertyURL(r, "/search-availability"),
+			http.StatusSeeOther)
+		return
+	}
+
+	if reservation.Room.ID != reservation.RoomID {
+		reservation.Room, err = m.DB.GetRoomById(reservation.RoomID)
+		if err != nil {
+			m.App.Session.Put(r.Context(), "error", "could not get room by id")
+			http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+			return
+		}
+	}
+	if !roomSleeps(reservation.Room, reservation.Guests()) {
+		form.Errors.Add("adults", form.T("This room sleeps at most %d guests",
+			reservation.Room.MaxOccupancy))
+		data := make(map[string]interface{})
+		data["reservation"] = reservation
+		http.Error(w, "invalid form input", http.StatusSeeOther)
+		renderPage(w, r, "make-reservation.page.html", &models.TemplateData{
+			Form:      form,
+			Data:      data,
+			StringMap: stringData,
+		})
 		return
 	}
-	restriction := models.RoomRestrictions{
-		StartDate:     reservation.StartDate,
-		EndDate:       reservation.EndDate,
-		RoomID:        reservation.RoomID,
-		RestrictionID: 1,
-		ReservationID: newResID,
+
+	var heldID int
+	if hold, ok := m.waitlistHold(r); ok && hold.HoldRoomID == reservation.RoomID {
+		heldID = hold.HoldRestrictionID
 	}
 
-	err = m.DB.InsertRoomRestriction(restriction)
+	reservation.ID, err = m.reserveRoom(&reservation, otherUnits, heldID)
+	if errors.Is(err, repository.ErrRoomTaken) {
+		msg := "sorry, this room has just been booked for your dates"
+		if input.RoomID == 0 {
+			msg = "sorry, this room type has just been fully booked for your dates"
+		}
+		m.App.Session.Put(r.Context(), "error", msg)
+		http.Redirect(w, r, helpers.PropertyURL(r, "/search-availability"),
+			http.StatusSeeOther)
+		return
+	}
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "could not insert restriction to DB")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		m.App.Session.Put(r.Context(), "error", "could not insert reservation to DB")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
 		return
 	}
+	metrics.ReservationsCreated.Inc()
 
-	htmlMsg := fmt.Sprintf(`
-		<strong>Reservation Confirmation</strong><br>
+	m.completeWaitlistHold(r, reservation)
 
-		Hi %s,
-		This is to confirm your reservation from %s to %s
-	`, reservation.FirstName, reservation.StartDate.Format("2006-01-01"),
-		reservation.EndDate.Format("2006-01-01"))
+	property := helpers.CurrentProperty(r)
+	locale := helpers.CurrentLocale(r)
+	htmlMsg := fmt.Sprintf(`
+		<strong>%s</strong><br>
+
+		%s
+		%s
+		%s
+	`, t(r, "Reservation Confirmation"),
+		t(r, "Hi %s,", reservation.FirstName),
+		t(r, "This is to confirm your reservation from %s to %s.",
+			i18n.FormatDate(locale, reservation.StartDate),
+			i18n.FormatDate(locale, reservation.EndDate)),
+		t(r, "Check-in is from %s and check-out is by %s, %s time.",
+			property.CheckIn(), property.CheckOut(), property.Location()))
 
 	msg := models.MailData{
-		To:      reservation.Email,
-		From:    "sjol@hub.co",
-		Subject: "Reservation Confirmation",
-		Content: htmlMsg,
+		To:        reservation.Email,
+		From:      "sjol@hub.co",
+		Subject:   t(r, "Reservation Confirmation"),
+		Content:   htmlMsg,
+		RequestID: helpers.RequestID(r),
 	}
 
 	m.App.MailChan <- msg
 
 	m.App.Session.Put(r.Context(), "reservation", reservation)
 
-	http.Redirect(w, r, "reservation-summary", http.StatusSeeOther)
+	http.Redirect(w, r, helpers.PropertyURL(r, "/reservation-summary"), http.StatusSeeOther)
 }
 
 func (m *Repository) Generals(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "generals.page.html", &models.TemplateData{})
+	renderPage(w, r, "generals.page.html", &models.TemplateData{})
 }
 
 func (m *Repository) Majors(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "majors.page.html", &models.TemplateData{})
+	renderPage(w, r, "majors.page.html", &models.TemplateData{})
 }
 
 func (m *Repository) Availability(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "search-availability.page.html", &models.TemplateData{})
+	renderPage(w, r, "search-availability.page.html", &models.TemplateData{})
 }
 func (m *Repository) PostAvailability(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
-		m.App.ErrorLog.Println("could not parse form")
+		m.App.Logger.ErrorContext(r.Context(), "could not parse form", "err", err)
 		m.App.Session.Put(r.Context(), "error", "could not parse form")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+		return
+	}
+
+	if r.Form.Get("month") != "" {
+		m.postAvailabilityInMonth(w, r)
 		return
 	}
+
 	start := r.Form.Get("start")
 	end := r.Form.Get("end")
 
-	layout := "2006-01-02"
-	startDate, err := time.Parse(layout, start)
+	startDate, err := dates.Parse(start)
 	if err != nil {
-		m.App.ErrorLog.Println("could not parse start_date \n", err)
+		m.App.Logger.ErrorContext(r.Context(), "could not parse start_date", "err", err)
 		m.App.Session.Put(r.Context(), "error", "could not parse start_date")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
 		return
 	}
-	endDate, err := time.Parse(layout, end)
+	endDate, err := dates.Parse(end)
 	if err != nil {
-		m.App.ErrorLog.Println("could not parse end_date \n", err)
+		m.App.Logger.ErrorContext(r.Context(), "could not parse end_date", "err", err)
 		m.App.Session.Put(r.Context(), "error", "could not parse end_date")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+		return
+	}
+	if !endDate.After(startDate) {
+		m.App.Session.Put(r.Context(), "error", "departure must be after arrival")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/search-availability"),
+			http.StatusSeeOther)
+		return
+	}
+
+	flex, _ := strconv.Atoi(r.Form.Get("flex"))
+	if flex < 0 {
+		flex = 0
+	}
+	if flex > maxFlexDays {
+		flex = maxFlexDays
+	}
+
+	adults, children := partySize(r.Form)
+	guests := adults + children
+	propertyID := helpers.CurrentProperty(r).ID
+
+	candidates := flexdates.Around(startDate, endDate, flex, today(r))
+	searchStart, searchEnd := startDate, endDate
+	for _, c := range candidates {
+		if c.Start.Before(searchStart) {
+			searchStart = c.Start
+		}
+		if c.End.After(searchEnd) {
+			searchEnd = c.End
+		}
+	}
+
+	propertyRules, err := m.DB.GetRulesForPropertyByDate(propertyID,
+		searchStart, searchEnd)
+	if err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "could not search stay rules from DB", "err", err)
+		m.App.Session.Put(r.Context(), "error", "could not search rooms from DB")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
 		return
 	}
 
-	rooms, err := m.DB.SearchAvailabilityForAllRooms(startDate, endDate)
+	roomTypes, violations, err := m.searchRoomTypes(propertyID,
+		flexdates.Range{Start: startDate, End: endDate}, guests, propertyRules,
+		helpers.CurrentLocale(r))
 	if err != nil {
-		m.App.ErrorLog.Println("could not search rooms from DB \n", err)
+		m.App.Logger.ErrorContext(r.Context(), "could not search rooms from DB", "err", err)
 		m.App.Session.Put(r.Context(), "error", "could not search rooms from DB")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
 		return
 	}
 
-	if len(rooms) < 1 {
-		m.App.Session.Put(r.Context(), "error", "No availability")
-		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+	alternatives, err := m.searchAlternatives(propertyID, candidates, guests,
+		propertyRules)
+	if err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "could not search rooms from DB", "err", err)
+		m.App.Session.Put(r.Context(), "error", "could not search rooms from DB")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+		return
+	}
+
+	if len(roomTypes) < 1 && len(alternatives) < 1 && len(violations) > 0 {
+		m.App.Session.Put(r.Context(), "error", strings.Join(violations, ". "))
+		http.Redirect(w, r, helpers.PropertyURL(r, "/search-availability"),
+			http.StatusSeeOther)
+		return
+	}
+
+	if len(roomTypes) < 1 && len(alternatives) < 1 {
+		m.App.Session.Put(r.Context(), "error",
+			"No availability, join the waitlist and we will email you if a room frees up")
+		http.Redirect(w, r, helpers.PropertyURL(r, fmt.Sprintf(
+			"/waitlist?start=%s&end=%s&adults=%d&children=%d",
+			start, end, adults, children)), http.StatusSeeOther)
+		return
 	}
 	reservation := models.Reservation{
 		StartDate: startDate,
 		EndDate:   endDate,
+		Adults:    adults,
+		Children:  children,
 	}
 	m.App.Session.Put(r.Context(), "reservation", reservation)
 	data := make(map[string]interface{})
-	data["rooms"] = rooms
+	data["room_types"] = roomTypes
+	data["alternatives"] = alternatives
 
-	render.Template(w, r, "choose-room.page.html", &models.TemplateData{
+	renderPage(w, r, "choose-room.page.html", &models.TemplateData{
 		Data: data,
 	})
 
 	// w.Write([]byte(fmt.Sprintf("The selected start is %s and end date is %s", start, end)))
 }
 
+// postAvailabilityInMonth handles searches for a number of nights sometime
+// in a month, such as three nights in March.
+func (m *Repository) postAvailabilityInMonth(w http.ResponseWriter, r *http.Request) {
+	month, err := dates.ParseMonth(r.Form.Get("month"))
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "could not parse month")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/search-availability"),
+			http.StatusSeeOther)
+		return
+	}
+
+	nights, err := strconv.Atoi(r.Form.Get("nights"))
+	if err != nil || nights < 1 || nights > maxFlexNights {
+		m.App.Session.Put(r.Context(), "error",
+			t(r, "pick from 1 to %d nights", maxFlexNights))
+		http.Redirect(w, r, helpers.PropertyURL(r, "/search-availability"),
+			http.StatusSeeOther)
+		return
+	}
+
+	adults, children := partySize(r.Form)
+	propertyID := helpers.CurrentProperty(r).ID
+
+	candidates := flexdates.InMonth(month, nights, today(r))
+	if len(candidates) == 0 {
+		m.App.Session.Put(r.Context(), "error", "that month is already over")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/search-availability"),
+			http.StatusSeeOther)
+		return
+	}
+
+	propertyRules, err := m.DB.GetRulesForPropertyByDate(propertyID,
+		candidates[0].Start, candidates[len(candidates)-1].End)
+	if err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "could not search stay rules from DB", "err", err)
+		m.App.Session.Put(r.Context(), "error", "could not search rooms from DB")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+		return
+	}
+
+	alternatives, err := m.searchAlternatives(propertyID, candidates,
+		adults+children, propertyRules)
+	if err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "could not search rooms from DB", "err", err)
+		m.App.Session.Put(r.Context(), "error", "could not search rooms from DB")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+		return
+	}
+
+	if len(alternatives) < 1 {
+		m.App.Session.Put(r.Context(), "error", t(r,
+			"No availability for %d nights in %s", nights,
+			i18n.FormatMonth(helpers.CurrentLocale(r), month)))
+		http.Redirect(w, r, helpers.PropertyURL(r, "/search-availability"),
+			http.StatusSeeOther)
+		return
+	}
+
+	reservation := models.Reservation{
+		Adults:   adults,
+		Children: children,
+	}
+	m.App.Session.Put(r.Context(), "reservation", reservation)
+	data := make(map[string]interface{})
+	data["alternatives"] = alternatives
+
+	stringMap := make(map[string]string)
+	stringMap["month_search"] = t(r, "%d nights in %s", nights,
+		i18n.FormatMonth(helpers.CurrentLocale(r), month))
+
+	renderPage(w, r, "choose-room.page.html", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+	})
+}
+
+const (
+	// maxFlexDays is the widest shift either way offered by the search form.
+	maxFlexDays = 7
+	// maxFlexNights caps the length of a month search.
+	maxFlexNights = 30
+	// maxAlternatives is the number of other stays listed per room type.
+	maxAlternatives = 3
+)
+
+// alternative lists other stays a room type is free for, nearest first.
+type alternative struct {
+	RoomType models.RoomType
+	Ranges   []flexdates.Range
+}
+
+// searchRoomTypes returns the room types with rooms free for the whole stay
+// that pass the stay rules and sleep the party, along with the reasons rooms
+// were left out.
+func (m *Repository) searchRoomTypes(propertyID int, stay flexdates.Range,
+	guests int, propertyRules []models.RoomRule,
+	locale string) ([]models.RoomType, []string, error) {
+
+	roomTypes, err := m.DB.SearchAvailabilityForAllRooms(propertyID,
+		stay.Start, stay.End)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roomTypes, violations := filterRoomTypes(roomTypes,
+		stayProblems(stay, guests, propertyRules, locale))
+
+	return roomTypes, violations, nil
+}
+
+// stayProblems returns the check of a room against the stay rules and the
+// size of the party, with its messages translated into locale.
+func stayProblems(stay flexdates.Range, guests int, propertyRules []models.RoomRule,
+	locale string) func(room models.Room) []string {
+
+	return func(room models.Room) []string {
+		problems := rules.Check(rules.ForRoom(propertyRules, room.ID),
+			stay.Start, stay.End, locale)
+		if !roomSleeps(room, guests) {
+			problems = append(problems,
+				i18n.T(locale, "No room can sleep a party of %d", guests))
+		}
+		return problems
+	}
+}
+
+// searchAlternatives tries every candidate stay in order and lists, per room
+// type, the first few the room type is free for. The rooms and what is
+// booked over all the candidates are read once, and each candidate is
+// checked against them in memory.
+func (m *Repository) searchAlternatives(propertyID int, candidates []flexdates.Range,
+	guests int, propertyRules []models.RoomRule) ([]alternative, error) {
+
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	roomTypes, err := m.propertyRoomTypes(propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	window := candidates[0]
+	for _, c := range candidates {
+		if c.Start.Before(window.Start) {
+			window.Start = c.Start
+		}
+		if c.End.After(window.End) {
+			window.End = c.End
+		}
+	}
+	booked, err := m.DB.GetRestrictionsForPropertyByDate(propertyID,
+		window.Start, window.End)
+	if err != nil {
+		return nil, err
+	}
+
+	var alternatives []alternative
+	index := make(map[int]int)
+
+	for _, c := range candidates {
+		free, _ := filterRoomTypes(roomTypes, func(room models.Room) []string {
+			for _, rr := range booked {
+				if rr.RoomID == room.ID &&
+					dates.Overlap(c.Start, c.End, rr.StartDate, rr.EndDate) {
+					return []string{"the room is booked"}
+				}
+			}
+			// the messages of the alternatives are not shown
+			return stayProblems(c, guests, propertyRules, i18n.Default)(room)
+		})
+
+		for _, rt := range free {
+			i, ok := index[rt.ID]
+			if !ok {
+				i = len(alternatives)
+				index[rt.ID] = i
+				alternatives = append(alternatives, alternative{RoomType: rt})
+			}
+			if len(alternatives[i].Ranges) < maxAlternatives {
+				alternatives[i].Ranges = append(alternatives[i].Ranges, c)
+			}
+		}
+	}
+
+	return alternatives, nil
+}
+
+// propertyRoomTypes returns the room types of a property with all their
+// rooms listed in AvailableRooms, ordered by name like the search results.
+func (m *Repository) propertyRoomTypes(propertyID int) ([]models.RoomType, error) {
+	rooms, err := m.DB.AllRooms(propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var roomTypes []models.RoomType
+	index := make(map[int]int)
+	for _, room := range rooms {
+		i, ok := index[room.RoomTypeID]
+		if !ok {
+			rt, err := m.DB.GetRoomTypeByID(room.RoomTypeID)
+			if err != nil {
+				return nil, err
+			}
+			i = len(roomTypes)
+			index[room.RoomTypeID] = i
+			roomTypes = append(roomTypes, rt)
+		}
+		roomTypes[i].AvailableRooms = append(roomTypes[i].AvailableRooms, room)
+	}
+
+	sort.SliceStable(roomTypes, func(i, j int) bool {
+		return roomTypes[i].TypeName < roomTypes[j].TypeName
+	})
+	return roomTypes, nil
+}
+
+// t translates msg into the language the request is served in.
+func t(r *http.Request, msg string, args ...interface{}) string {
+	return i18n.T(helpers.CurrentLocale(r), msg, args...)
+}
+
+// renderPage renders a page template, showing the error page instead when
+// the template cannot be rendered.
+func renderPage(w http.ResponseWriter, r *http.Request, tmpl string,
+	td *models.TemplateData) {
+
+	if err := render.Template(w, r, tmpl, td); err != nil {
+		helpers.ServerError(w, r, err)
+	}
+}
+
+// today returns the current day at the property being browsed.
+func today(r *http.Request) time.Time {
+	return helpers.CurrentProperty(r).Today()
+}
+
+// filterRoomTypes keeps only the available rooms for which check returns no
+// messages, drops room types left without rooms, and returns the messages
+// of the rooms that were removed.
+func filterRoomTypes(roomTypes []models.RoomType,
+	check func(room models.Room) []string) ([]models.RoomType, []string) {
+
+	var kept []models.RoomType
+	var messages []string
+	seen := make(map[string]bool)
+
+	for _, rt := range roomTypes {
+		var rooms []models.Room
+		for _, room := range rt.AvailableRooms {
+			problems := check(room)
+			if len(problems) == 0 {
+				rooms = append(rooms, room)
+				continue
+			}
+			for _, p := range problems {
+				if !seen[p] {
+					seen[p] = true
+					messages = append(messages, p)
+				}
+			}
+		}
+
+		if len(rooms) > 0 {
+			rt.AvailableRooms = rooms
+			kept = append(kept, rt)
+		}
+	}
+
+	return kept, messages
+}
+
+// maxPartySize caps the adults and children accepted on a single booking.
+const maxPartySize = 20
+
+func init() {
+	forms.RegisterBound("maxPartySize", maxPartySize)
+}
+
+// reservationInput is what the make reservation page posts.
+type reservationInput struct {
+	StartDate  time.Time `form:"start_date" validate:"required"`
+	EndDate    time.Time `form:"end_date" validate:"required"`
+	RoomID     int       `form:"room_id" validate:"required"`
+	RoomTypeID int       `form:"room_type_id"`
+	FirstName  string    `form:"first_name" validate:"required,min=3,max=100"`
+	LastName   string    `form:"last_name" validate:"required,max=100"`
+	Email      string    `form:"email" validate:"required,email"`
+	Phone      string    `form:"phone" validate:"phone"`
+	Adults     int       `form:"adults" validate:"range=1:maxPartySize"`
+	Children   int       `form:"children" validate:"range=0:maxPartySize"`
+}
+
+// waitlistInput is what the waitlist page posts. A room id of 0 means any
+// room of the property.
+type waitlistInput struct {
+	Start     time.Time `form:"start" validate:"required"`
+	End       time.Time `form:"end" validate:"required,after=start"`
+	RoomID    int       `form:"room_id"`
+	FirstName string    `form:"first_name" validate:"required,max=100"`
+	LastName  string    `form:"last_name" validate:"required,max=100"`
+	Email     string    `form:"email" validate:"required,email"`
+	Adults    int       `form:"adults" validate:"range=1:maxPartySize"`
+	Children  int       `form:"children" validate:"range=0:maxPartySize"`
+}
+
+// partySize reads the adults and children fields of a form. A missing or
+// unparsable adults count means one adult, a missing children count none.
+func partySize(form url.Values) (int, int) {
+	adults, err := strconv.Atoi(form.Get("adults"))
+	if err != nil || adults < 1 {
+		adults = 1
+	}
+	children, err := strconv.Atoi(form.Get("children"))
+	if err != nil || children < 0 {
+		children = 0
+	}
+	return adults, children
+}
+
+// roomSleeps reports whether room can host the given number of guests. Rooms
+// without a recorded max occupancy are not limited.
+func roomSleeps(room models.Room, guests int) bool {
+	return room.MaxOccupancy == 0 || guests <= room.MaxOccupancy
+}
+
 type jsonResponse struct {
 	OK        bool      `json:"ok"`
 	Message   string    `json:"message"`
@@ -283,8 +836,8 @@ func (m *Repository) PostAvailabilityJson(w http.ResponseWriter, r *http.Request
 
 	sd := r.PostForm.Get("start")
 	ed := r.PostForm.Get("end")
-	startDate, _ := time.Parse("2006-01-02", sd)
-	endDate, _ := time.Parse("2006-01-02", ed)
+	startDate, _ := dates.Parse(sd)
+	endDate, _ := dates.Parse(ed)
 	roomId, _ := strconv.Atoi(r.PostForm.Get("room_id"))
 
 	available, err := m.DB.SearchAvailabilityByDatesByRoomId(startDate, endDate, roomId)
@@ -300,9 +853,31 @@ func (m *Repository) PostAvailabilityJson(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	message := ""
+	if available {
+		roomRules, err := m.DB.GetRulesForRoomByDate(roomId, startDate, endDate)
+		if err != nil {
+			resp := jsonResponse{
+				OK:      false,
+				Message: "Failed to search the db",
+			}
+
+			out, _ := json.MarshalIndent(resp, "", "     ")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(out)
+			return
+		}
+
+		if violations := rules.Check(roomRules, startDate, endDate,
+			helpers.CurrentLocale(r)); len(violations) > 0 {
+			available = false
+			message = strings.Join(violations, ". ")
+		}
+	}
+
 	resp := jsonResponse{
 		OK:        available,
-		Message:   "",
+		Message:   message,
 		StartDate: startDate,
 		EndDate:   endDate,
 		RoomID:    roomId,
@@ -314,15 +889,141 @@ func (m *Repository) PostAvailabilityJson(w http.ResponseWriter, r *http.Request
 	w.Write(out)
 }
 
-func (m *Repository) Contact(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "contact.page.html", &models.TemplateData{})
+// maxCalendarMonths caps the months returned by a single room calendar request.
+const maxCalendarMonths = 12
+
+type calendarDay struct {
+	Date              string `json:"date"`
+	Available         bool   `json:"available"`
+	Price             int    `json:"price"`
+	MinStay           int    `json:"min_stay"`
+	ClosedToArrival   bool   `json:"closed_to_arrival"`
+	ClosedToDeparture bool   `json:"closed_to_departure"`
 }
 
-func (m *Repository) ReservationSummary(w http.ResponseWriter, r *http.Request) {
-	resvn, ok := m.App.Session.Get(r.Context(), "reservation").(models.Reservation)
-	if !ok {
-		m.App.Session.Put(r.Context(), "error", "no reservation found")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+type calendarResponse struct {
+	OK      bool          `json:"ok"`
+	Message string        `json:"message,omitempty"`
+	RoomID  int           `json:"room_id"`
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Days    []calendarDay `json:"days"`
+}
+
+// RoomCalendarJson returns the availability of a room for every day from the
+// first of the from month to the end of the to month (both as yyyy-mm), so
+// date pickers can disable the days that cannot be booked.
+func (m *Repository) RoomCalendarJson(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.URL.Path, "/")
+	roomID, err := strconv.Atoi(exploded[len(exploded)-1])
+	if err != nil {
+		writeCalendar(w, r, http.StatusBadRequest,
+			calendarResponse{Message: "invalid room id"})
+		return
+	}
+
+	thisMonth := today(r).AddDate(0, 0, 1-today(r).Day())
+	from, to := thisMonth, thisMonth
+	if v := r.URL.Query().Get("from"); v != "" {
+		from, err = dates.ParseMonth(v)
+		if err != nil {
+			writeCalendar(w, r, http.StatusBadRequest,
+				calendarResponse{Message: "from must be a month like 2006-01"})
+			return
+		}
+		to = from
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		to, err = dates.ParseMonth(v)
+		if err != nil {
+			writeCalendar(w, r, http.StatusBadRequest,
+				calendarResponse{Message: "to must be a month like 2006-01"})
+			return
+		}
+	}
+	if to.Before(from) || to.After(from.AddDate(0, maxCalendarMonths-1, 0)) {
+		writeCalendar(w, r, http.StatusBadRequest, calendarResponse{
+			Message: fmt.Sprintf("ask for 1 to %d months at a time", maxCalendarMonths),
+		})
+		return
+	}
+
+	room, err := m.DB.GetRoomById(roomID)
+	if err != nil || room.PropertyID != helpers.CurrentProperty(r).ID {
+		writeCalendar(w, r, http.StatusNotFound,
+			calendarResponse{Message: "room not found"})
+		return
+	}
+
+	last := to.AddDate(0, 1, -1)
+	days, err := m.DB.RoomCalendar(roomID, from, last)
+	if err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "could not build room calendar", "err", err)
+		writeCalendar(w, r, http.StatusInternalServerError,
+			calendarResponse{Message: "Failed to search the db"})
+		return
+	}
+
+	resp := calendarResponse{
+		OK:     true,
+		RoomID: roomID,
+		From:   from.Format("2006-01-02"),
+		To:     last.Format("2006-01-02"),
+	}
+	for _, d := range days {
+		resp.Days = append(resp.Days, calendarDay{
+			Date:              d.Date.Format("2006-01-02"),
+			Available:         d.Available,
+			Price:             d.Price,
+			MinStay:           d.MinStay,
+			ClosedToArrival:   d.ClosedToArrival,
+			ClosedToDeparture: d.ClosedToDeparture,
+		})
+	}
+
+	writeCalendar(w, r, http.StatusOK, resp)
+}
+
+// writeCalendar sends a room calendar with an ETag so that browsers
+// revalidate cheaply, answering 304 when their copy is still current.
+func writeCalendar(w http.ResponseWriter, r *http.Request, status int,
+	resp calendarResponse) {
+
+	out, _ := json.MarshalIndent(resp, "", "     ")
+
+	w.Header().Set("Content-Type", "application/json")
+	if status != http.StatusOK {
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		w.Write(out)
+		return
+	}
+
+	sum := sha256.Sum256(out)
+	etag := fmt.Sprintf(`"%x"`, sum[:16])
+
+	// availability changes with every booking, so copies only stay fresh
+	// for a minute
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Write(out)
+}
+
+func (m *Repository) Contact(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "contact.page.html", &models.TemplateData{})
+}
+
+func (m *Repository) ReservationSummary(w http.ResponseWriter, r *http.Request) {
+	resvn, ok := m.App.Session.Get(r.Context(), "reservation").(models.Reservation)
+	if !ok {
+		m.App.Session.Put(r.Context(), "error", "no reservation found")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
 		return
 	}
 
@@ -336,34 +1037,56 @@ func (m *Repository) ReservationSummary(w http.ResponseWriter, r *http.Request)
 	stringData["start_date"] = sd
 	stringData["end_date"] = ed
 
-	render.Template(w, r, "reservation-summary.page.html", &models.TemplateData{
+	renderPage(w, r, "reservation-summary.page.html", &models.TemplateData{
 		Data:      data,
 		StringMap: stringData,
 	})
 }
 
+// ChooseRoom stores the room type picked on the choose-room page; the unit
+// itself is assigned in PostReservation.
 func (m *Repository) ChooseRoom(w http.ResponseWriter, r *http.Request) {
-	exploded := strings.Split(r.RequestURI, "/")
-	roomId, err := strconv.Atoi(exploded[2])
+	path := strings.SplitN(r.RequestURI, "?", 2)[0]
+	exploded := strings.Split(path, "/")
+	roomTypeID, err := strconv.Atoi(exploded[len(exploded)-1])
 	if err != nil {
-		m.App.ErrorLog.Println("missing url parameter")
+		m.App.Logger.ErrorContext(r.Context(), "missing url parameter")
 		m.App.Session.Put(r.Context(), "error", "missing url parameter")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
 		return
 	}
 
 	res, ok := m.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 	if !ok {
-		m.App.ErrorLog.Println("unable to get reservation details from session")
+		m.App.Logger.ErrorContext(r.Context(), "unable to get reservation details from session")
 		m.App.Session.Put(r.Context(), "error", "unable to get reservation details from session")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
 		return
 	}
 
-	res.RoomID = roomId
+	// alternative stays from a flexible search carry their own dates
+	if sd, ed := r.URL.Query().Get("s"), r.URL.Query().Get("e"); sd != "" && ed != "" {
+		startDate, err := dates.Parse(sd)
+		if err != nil {
+			m.App.Session.Put(r.Context(), "error", "could not parse start date")
+			http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+			return
+		}
+		endDate, err := dates.Parse(ed)
+		if err != nil {
+			m.App.Session.Put(r.Context(), "error", "could not parse end date")
+			http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+			return
+		}
+		res.StartDate = startDate
+		res.EndDate = endDate
+	}
+
+	res.RoomID = 0
+	res.RoomTypeID = roomTypeID
 
 	m.App.Session.Put(r.Context(), "reservation", res)
-	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
+	http.Redirect(w, r, helpers.PropertyURL(r, "/make-reservation"), http.StatusSeeOther)
 }
 
 func (m *Repository) BookRoom(w http.ResponseWriter, r *http.Request) {
@@ -371,8 +1094,8 @@ func (m *Repository) BookRoom(w http.ResponseWriter, r *http.Request) {
 	sd := r.URL.Query().Get("s")
 	ed := r.URL.Query().Get("e")
 
-	startDate, _ := time.Parse("2006-01-02", sd)
-	endDate, _ := time.Parse("2006-01-02", ed)
+	startDate, _ := dates.Parse(sd)
+	endDate, _ := dates.Parse(ed)
 
 	reservation := models.Reservation{
 		RoomID:    roomID,
@@ -381,11 +1104,11 @@ func (m *Repository) BookRoom(w http.ResponseWriter, r *http.Request) {
 	}
 
 	m.App.Session.Put(r.Context(), "reservation", reservation)
-	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
+	http.Redirect(w, r, helpers.PropertyURL(r, "/make-reservation"), http.StatusSeeOther)
 }
 
 func (m *Repository) ShowLogin(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "login.page.html", &models.TemplateData{
+	renderPage(w, r, "login.page.html", &models.TemplateData{
 		Form: forms.New(nil),
 	})
 }
@@ -395,16 +1118,17 @@ func (m *Repository) PostLogin(w http.ResponseWriter, r *http.Request) {
 
 	err := r.ParseForm()
 	if err != nil {
-		log.Println(err)
+		m.App.Logger.ErrorContext(r.Context(), "could not parse form", "err", err)
 	}
 
 	email := r.Form.Get("email")
 	password := r.Form.Get("password")
 
 	form := forms.New(r.Form)
+	form.Locale = helpers.CurrentLocale(r)
 	form.Required("email", "password")
 	if !form.Valid() {
-		render.Template(w, r, "login.page.html", &models.TemplateData{
+		renderPage(w, r, "login.page.html", &models.TemplateData{
 			Form: form,
 		})
 	}
@@ -418,7 +1142,7 @@ func (m *Repository) PostLogin(w http.ResponseWriter, r *http.Request) {
 
 	m.App.Session.Put(r.Context(), "user_id", id)
 	m.App.Session.Put(r.Context(), "flash", "logged in successfully")
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusSeeOther)
 
 }
 
@@ -430,38 +1154,38 @@ func (m *Repository) Logout(w http.ResponseWriter, r *http.Request) {
 }
 
 func (m *Repository) AdminDashboard(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "admin-dashboard.page.html", &models.TemplateData{})
+	renderPage(w, r, "admin-dashboard.page.html", &models.TemplateData{})
 }
 func (m *Repository) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
-	reservations, err := m.DB.AllNewReservations()
+	reservations, err := m.DB.AllNewReservations(helpers.CurrentProperty(r).ID)
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 	data := make(map[string]interface{})
 	data["reservations"] = reservations
 
-	render.Template(w, r, "admin-new-reservations.page.html", &models.TemplateData{
+	renderPage(w, r, "admin-new-reservations.page.html", &models.TemplateData{
 		Data: data,
 	})
 }
 
 func (m *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
-	reservations, err := m.DB.AllReservations()
+	reservations, err := m.DB.AllReservations(helpers.CurrentProperty(r).ID)
 	if err != nil {
 		// m.App.Session.Put(r.Context(), "error", "could not fetch all reservations")
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 	data := make(map[string]interface{})
 	data["reservations"] = reservations
 
-	render.Template(w, r, "admin-all-reservations.page.html", &models.TemplateData{
+	renderPage(w, r, "admin-all-reservations.page.html", &models.TemplateData{
 		Data: data,
 	})
 }
 func (m *Repository) AdminReservationCalendar(w http.ResponseWriter, r *http.Request) {
-	now := time.Now()
+	now := today(r)
 
 	if r.URL.Query().Get("y") != "" {
 		year, _ := strconv.Atoi(r.URL.Query().Get("y"))
@@ -486,7 +1210,7 @@ func (m *Repository) AdminReservationCalendar(w http.ResponseWriter, r *http.Req
 	stringMap["next_month"] = nextMonth
 	stringMap["next_month_year"] = nextMonthYear
 
-	stringMap["this_month"] = now.Format("02")
+	stringMap["this_month"] = now.Format("01")
 	stringMap["this_month_year"] = now.Format("2006")
 
 	currentYear, currentMonth, _ := now.Date()
@@ -497,18 +1221,19 @@ func (m *Repository) AdminReservationCalendar(w http.ResponseWriter, r *http.Req
 	intMap := make(map[string]int)
 	intMap["days_in_month"] = lastOfMonth.Day()
 
-	rooms, err := m.DB.AllRooms()
+	rooms, err := m.DB.AllRooms(helpers.CurrentProperty(r).ID)
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
+		return
 	}
 
 	data["rooms"] = rooms
 
-	reservationMap := make(map[string]int)
-	blockMap := make(map[string]int)
-
 	for _, x := range rooms {
-		for d := firstOfMonth; d.After(lastOfMonth); d.AddDate(0, 0, 1) {
+		reservationMap := make(map[string]int)
+		blockMap := make(map[string]int)
+
+		for d := firstOfMonth; !d.After(lastOfMonth); d = d.AddDate(0, 0, 1) {
 			reservationMap[d.Format("2006-01-2")] = 0
 			blockMap[d.Format("2006-01-2")] = 0
 		}
@@ -516,53 +1241,196 @@ func (m *Repository) AdminReservationCalendar(w http.ResponseWriter, r *http.Req
 		restrictions, err := m.DB.GetRestrictionForRoomByDate(x.ID,
 			firstOfMonth, lastOfMonth)
 		if err != nil {
-			helpers.ServerError(w, err)
+			helpers.ServerError(w, r, err)
 			return
 		}
 		for _, y := range restrictions {
 
 			if y.ReservationID > 0 {
-				//it is a reservation
-				for d := y.StartDate; d.After(y.EndDate); d.AddDate(0, 0, 1) {
+				//it is a reservation, the departure day is free for the next guest
+				for d := y.StartDate; d.Before(y.EndDate); d = d.AddDate(0, 0, 1) {
 					reservationMap[d.Format("2006-01-2")] = y.ReservationID
 				}
-			} else {
+			} else if y.RestrictionID == models.RestrictionOwnerBlock {
 				//it is a block
 				blockMap[y.StartDate.Format("2006-01-2")] = y.ID
 			}
 		}
 		data[fmt.Sprintf("reservation_map_%d", x.ID)] = reservationMap
 		data[fmt.Sprintf("block_map_%d", x.ID)] = blockMap
-		log.Println(blockMap)
+
+		roomRules, err := m.DB.GetRulesForRoomByDate(x.ID, firstOfMonth, lastOfMonth)
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+		data[fmt.Sprintf("rules_%d", x.ID)] = roomRules
 
 		m.App.Session.Put(r.Context(), fmt.Sprintf("block_map_%d", x.ID), blockMap)
 	}
 
-	render.Template(w, r, "admin-reservation-calendar.page.html", &models.TemplateData{
+	data["rule_names"] = rules.Names
+
+	renderPage(w, r, "admin-reservation-calendar.page.html", &models.TemplateData{
 		StringMap: stringMap,
 		Data:      data,
 		IntMap:    intMap,
+		Form:      forms.New(nil),
 	})
 }
 
+// AdminPostRoomRule adds a stay rule to a room from the reservation calendar.
+func (m *Repository) AdminPostRoomRule(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	calendarURL := fmt.Sprintf("/admin/reservation-calendar?y=%s&m=%s",
+		r.Form.Get("y"), r.Form.Get("m"))
+
+	form := forms.New(r.PostForm)
+	form.Required("room_id", "restriction_id", "start", "end")
+	if !form.Valid() {
+		m.App.Session.Put(r.Context(), "error", "room, rule and dates are required")
+		http.Redirect(w, r, calendarURL, http.StatusSeeOther)
+		return
+	}
+
+	roomID, err := strconv.Atoi(r.Form.Get("room_id"))
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+	restrictionID, err := strconv.Atoi(r.Form.Get("restriction_id"))
+	if err != nil || !rules.IsRule(restrictionID) {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	startDate, err := dates.Parse(r.Form.Get("start"))
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "could not parse start date")
+		http.Redirect(w, r, calendarURL, http.StatusSeeOther)
+		return
+	}
+	endDate, err := dates.Parse(r.Form.Get("end"))
+	if err != nil || endDate.Before(startDate) {
+		m.App.Session.Put(r.Context(), "error", "end date must be on or after the start date")
+		http.Redirect(w, r, calendarURL, http.StatusSeeOther)
+		return
+	}
+
+	value, _ := strconv.Atoi(r.Form.Get("value"))
+	if (restrictionID == models.RuleMinStay || restrictionID == models.RuleMaxStay) &&
+		value < 1 {
+		m.App.Session.Put(r.Context(), "error", "minimum and maximum stays need a number of nights")
+		http.Redirect(w, r, calendarURL, http.StatusSeeOther)
+		return
+	}
+
+	room, err := m.DB.GetRoomById(roomID)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	if room.PropertyID != helpers.CurrentProperty(r).ID {
+		helpers.ClientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	_, err = m.DB.InsertRoomRule(models.RoomRule{
+		RoomID:        roomID,
+		RestrictionID: restrictionID,
+		StartDate:     startDate,
+		EndDate:       endDate,
+		Value:         value,
+	})
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "stay rule added")
+	http.Redirect(w, r, calendarURL, http.StatusSeeOther)
+}
+
+// AdminDeleteRoomRule removes a stay rule from a room.
+func (m *Repository) AdminDeleteRoomRule(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	calendarURL := fmt.Sprintf("/admin/reservation-calendar?y=%s&m=%s",
+		r.Form.Get("y"), r.Form.Get("m"))
+
+	id, err := strconv.Atoi(r.Form.Get("rule_id"))
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+	roomID, err := strconv.Atoi(r.Form.Get("room_id"))
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	room, err := m.DB.GetRoomById(roomID)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	if room.PropertyID != helpers.CurrentProperty(r).ID {
+		helpers.ClientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	err = m.DB.DeleteRoomRule(id, roomID)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "stay rule removed")
+	http.Redirect(w, r, calendarURL, http.StatusSeeOther)
+}
+
 func (m *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Request) {
 	exploded := strings.Split(r.RequestURI, "/")
 	id, err := strconv.Atoi(exploded[4])
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
+		return
 	}
 
 	stringMap := make(map[string]string)
 	stringMap["src"] = exploded[3]
 
-	res, err := m.DB.GetReservationByID(id)
-	if err != nil {
-		helpers.ServerError(w, err)
+	res, err := m.adminReservation(r, id)
+	if err == errNotInProperty {
+		helpers.ClientError(w, r, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, r, err)
+		return
 	}
 	data := make(map[string]interface{})
 	data["reservation"] = res
 
-	render.Template(w, r, "admin-reservation-show.page.html", &models.TemplateData{
+	if res.Room.RoomTypeID > 0 && res.DeletedAt.IsZero() {
+		rooms, err := m.DB.AvailableRoomsForType(res.Room.RoomTypeID,
+			res.StartDate, res.EndDate)
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+		data["available_rooms"] = rooms
+	}
+
+	renderPage(w, r, "admin-reservation-show.page.html", &models.TemplateData{
 		Data:      data,
 		StringMap: stringMap,
 		Form:      forms.New(nil),
@@ -573,18 +1441,62 @@ func (m *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.Req
 	exploded := strings.Split(r.RequestURI, "/")
 	id, err := strconv.Atoi(exploded[4])
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
+		return
 	}
 
 	src := exploded[3]
 	stringMap := make(map[string]string)
 	stringMap["src"] = src
 
+	res, err := m.adminReservation(r, id)
+	if err == errNotInProperty {
+		helpers.ClientError(w, r, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
 	err = r.ParseForm()
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
+
+	if r.Form.Get("start_date") != "" && r.Form.Get("end_date") != "" {
+		showURL := fmt.Sprintf("/admin/reservations/%s/%d", src, id)
+
+		start, err := dates.Parse(r.Form.Get("start_date"))
+		if err != nil {
+			helpers.ClientError(w, r, http.StatusBadRequest)
+			return
+		}
+		end, err := dates.Parse(r.Form.Get("end_date"))
+		if err != nil {
+			helpers.ClientError(w, r, http.StatusBadRequest)
+			return
+		}
+
+		if !start.Equal(res.StartDate) || !end.Equal(res.EndDate) {
+			if start.Before(res.StartDate) || end.After(res.EndDate) ||
+				!end.After(start) {
+				m.App.Session.Put(r.Context(), "error",
+					"a stay can only be shortened here, book the extra nights separately")
+				http.Redirect(w, r, showURL, http.StatusSeeOther)
+				return
+			}
+
+			err = m.DB.ShortenReservation(id, start, end)
+			if err != nil {
+				helpers.ServerError(w, r, err)
+				return
+			}
+			m.offerFreedNights(r, res.RoomID, res.StartDate, start)
+			m.offerFreedNights(r, res.RoomID, end, res.EndDate)
+		}
+	}
+
 	reservation := models.Reservation{
 		ID:        id,
 		FirstName: r.Form.Get("first_name"),
@@ -595,7 +1507,8 @@ func (m *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.Req
 
 	err = m.DB.UpdateReservation(reservation)
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
+		return
 	}
 
 	http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
@@ -605,16 +1518,27 @@ func (m *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.Requ
 	exploded := strings.Split(r.RequestURI, "/")
 	id, err := strconv.Atoi(exploded[4])
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
+		return
 	}
 
 	src := exploded[3]
 	stringMap := make(map[string]string)
 	stringMap["src"] = src
 
+	_, err = m.adminReservation(r, id)
+	if err == errNotInProperty {
+		helpers.ClientError(w, r, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
 	err = m.DB.UpdateReservationProcessed(id, 1)
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
+		return
 	}
 	m.App.Session.Put(r.Context(), "flash", "reservation processed")
 	http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
@@ -623,17 +1547,681 @@ func (m *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Reque
 	exploded := strings.Split(r.RequestURI, "/")
 	id, err := strconv.Atoi(exploded[4])
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
+		return
 	}
 
 	src := exploded[3]
 	stringMap := make(map[string]string)
 	stringMap["src"] = src
 
+	res, err := m.adminReservation(r, id)
+	if err == errNotInProperty {
+		helpers.ClientError(w, r, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
 	err = m.DB.DeleteReservation(id)
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
+		return
 	}
-	m.App.Session.Put(r.Context(), "flash", "reservation deleted")
+	m.offerFreedNights(r, res.RoomID, res.StartDate, res.EndDate)
+	m.App.Session.Put(r.Context(), "flash", "reservation moved to trash")
 	http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
 }
+
+// AdminReassignReservation moves a reservation to another unit of the same
+// room type that is free for the whole stay.
+func (m *Repository) AdminReassignReservation(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[4])
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	src := exploded[3]
+
+	res, err := m.adminReservation(r, id)
+	if err == errNotInProperty {
+		helpers.ClientError(w, r, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	roomID, err := strconv.Atoi(r.Form.Get("room_id"))
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	showURL := fmt.Sprintf("/admin/reservations/%s/%d", src, id)
+
+	room, err := m.DB.GetRoomById(roomID)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	if room.RoomTypeID != res.Room.RoomTypeID {
+		m.App.Session.Put(r.Context(), "error",
+			"a reservation can only be moved to a room of the same type")
+		http.Redirect(w, r, showURL, http.StatusSeeOther)
+		return
+	}
+
+	available, err := m.DB.SearchAvailabilityByDatesByRoomId(res.StartDate,
+		res.EndDate, roomID)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	if !available {
+		m.App.Session.Put(r.Context(), "error",
+			"that room is not available for the whole stay")
+		http.Redirect(w, r, showURL, http.StatusSeeOther)
+		return
+	}
+
+	err = m.DB.ReassignReservation(id, roomID)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	m.offerFreedNights(r, res.RoomID, res.StartDate, res.EndDate)
+
+	m.App.Session.Put(r.Context(), "flash", "reservation moved")
+	http.Redirect(w, r, showURL, http.StatusSeeOther)
+}
+
+func (m *Repository) AdminTrashReservations(w http.ResponseWriter, r *http.Request) {
+	reservations, err := m.DB.AllDeletedReservations(helpers.CurrentProperty(r).ID)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	data := make(map[string]interface{})
+	data["reservations"] = reservations
+
+	intMap := make(map[string]int)
+	intMap["purge_after_days"] = int(m.App.PurgeAfter.Hours() / 24)
+
+	renderPage(w, r, "admin-reservations-trash.page.html", &models.TemplateData{
+		Data:   data,
+		IntMap: intMap,
+	})
+}
+
+// AdminRestoreReservation takes a reservation out of the trash, as long as
+// its room has not been booked or blocked for the same dates in the meantime.
+func (m *Repository) AdminRestoreReservation(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[3])
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	_, err = m.adminReservation(r, id)
+	if err == errNotInProperty {
+		helpers.ClientError(w, r, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	err = m.DB.RestoreReservation(id)
+	if errors.Is(err, repository.ErrRoomTaken) {
+		m.App.Session.Put(r.Context(), "error",
+			"reservation cannot be restored, the room is no longer available for those dates")
+		http.Redirect(w, r, "/admin/reservations-trash", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "reservation restored")
+	http.Redirect(w, r, "/admin/reservations-trash", http.StatusSeeOther)
+}
+
+// AdminProperties returns the properties the logged in user may manage:
+// every property for administrators and only the ones they have been
+// assigned to for everyone else, so none for unassigned users.
+func (m *Repository) AdminProperties(r *http.Request) ([]models.Property, error) {
+	userID := m.App.Session.GetInt(r.Context(), "user_id")
+	if userID == 0 {
+		return nil, nil
+	}
+
+	user, err := m.DB.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.AccessLevel >= models.AccessAdmin {
+		return m.DB.AllProperties()
+	}
+
+	return m.DB.GetPropertiesForUser(userID)
+}
+
+func (m *Repository) AdminAllProperties(w http.ResponseWriter, r *http.Request) {
+	properties, err := m.AdminProperties(r)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	data := make(map[string]interface{})
+	data["properties"] = properties
+
+	renderPage(w, r, "admin-properties.page.html", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminPostProperty updates the timezone and check-in and check-out times of
+// a property. Check-out must come no later than check-in so a room can be
+// turned over on the day one guest leaves and the next arrives.
+func (m *Repository) AdminPostProperty(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[3])
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	properties, err := m.AdminProperties(r)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	var property models.Property
+	for _, p := range properties {
+		if p.ID == id {
+			property = p
+		}
+	}
+	if property.ID == 0 {
+		helpers.ClientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	property.Timezone = strings.TrimSpace(r.Form.Get("timezone"))
+	property.CheckInTime = strings.TrimSpace(r.Form.Get("check_in_time"))
+	property.CheckOutTime = strings.TrimSpace(r.Form.Get("check_out_time"))
+
+	_, err = time.LoadLocation(property.Timezone)
+	if property.Timezone == "" || err != nil {
+		m.App.Session.Put(r.Context(), "error", "unknown timezone")
+		http.Redirect(w, r, "/admin/properties", http.StatusSeeOther)
+		return
+	}
+
+	checkIn, err := dates.ParseClock(property.CheckInTime)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "check-in "+err.Error())
+		http.Redirect(w, r, "/admin/properties", http.StatusSeeOther)
+		return
+	}
+
+	checkOut, err := dates.ParseClock(property.CheckOutTime)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "check-out "+err.Error())
+		http.Redirect(w, r, "/admin/properties", http.StatusSeeOther)
+		return
+	}
+
+	if checkIn < checkOut {
+		m.App.Session.Put(r.Context(), "error",
+			"check-in cannot be earlier than check-out")
+		http.Redirect(w, r, "/admin/properties", http.StatusSeeOther)
+		return
+	}
+
+	err = m.DB.UpdateProperty(property)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash",
+		fmt.Sprintf("saved settings for %s", property.PropertyName))
+	http.Redirect(w, r, "/admin/properties", http.StatusSeeOther)
+}
+
+// AdminSwitchProperty changes the property the admin pages are scoped to.
+func (m *Repository) AdminSwitchProperty(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[3])
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	properties, err := m.AdminProperties(r)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	for _, p := range properties {
+		if p.ID == id {
+			m.App.Session.Put(r.Context(), "property_id", p.ID)
+			m.App.Session.Put(r.Context(), "flash",
+				fmt.Sprintf("now managing %s", p.PropertyName))
+			http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+			return
+		}
+	}
+
+	helpers.ClientError(w, r, http.StatusForbidden)
+}
+
+// isAdmin reports whether the logged in user administers every property.
+func (m *Repository) isAdmin(r *http.Request) (bool, error) {
+	userID := m.App.Session.GetInt(r.Context(), "user_id")
+	if userID == 0 {
+		return false, nil
+	}
+
+	user, err := m.DB.GetUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	return user.AccessLevel >= models.AccessAdmin, nil
+}
+
+// AdminUsers lists the users, for administrators to sign them out.
+func (m *Repository) AdminUsers(w http.ResponseWriter, r *http.Request) {
+	admin, err := m.isAdmin(r)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	if !admin {
+		helpers.ClientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	users, err := m.DB.AllUsers()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	data := make(map[string]interface{})
+	data["users"] = users
+
+	renderPage(w, r, "admin-users.page.html", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminSignOutUser ends every session of a user, wherever they are logged
+// in.
+func (m *Repository) AdminSignOutUser(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[3])
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	admin, err := m.isAdmin(r)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	if !admin {
+		helpers.ClientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	user, err := m.DB.GetUserByID(id)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	if m.Sessions == nil {
+		m.App.Session.Put(r.Context(), "error",
+			"sessions are kept in memory and cannot be ended by user")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	n, err := m.Sessions.DeleteUserSessions(user.ID)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash",
+		fmt.Sprintf("ended %d sessions of %s", n, user.Email))
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+var errNotInProperty = errors.New("reservation does not belong to this property")
+
+// adminReservation fetches a reservation and makes sure it belongs to the
+// property the admin is currently working on.
+func (m *Repository) adminReservation(r *http.Request, id int) (models.Reservation, error) {
+	res, err := m.DB.GetReservationByID(id)
+	if err != nil {
+		return res, err
+	}
+
+	if res.Room.PropertyID != helpers.CurrentProperty(r).ID {
+		return res, errNotInProperty
+	}
+
+	return res, nil
+}
+
+// AdminPostReservationCalendar saves the owner blocks ticked and unticked on
+// the reservation calendar.
+func (m *Repository) AdminPostReservationCalendar(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	year, _ := strconv.Atoi(r.Form.Get("y"))
+	month, _ := strconv.Atoi(r.Form.Get("m"))
+	calendarURL := fmt.Sprintf("/admin/reservation-calendar?y=%d&m=%d", year, month)
+
+	rooms, err := m.DB.AllRooms(helpers.CurrentProperty(r).ID)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	inProperty := make(map[int]bool)
+
+	for _, x := range rooms {
+		inProperty[x.ID] = true
+
+		// blocks shown on the calendar that are no longer ticked get removed
+		curMap, _ := m.App.Session.Get(r.Context(),
+			fmt.Sprintf("block_map_%d", x.ID)).(map[string]int)
+		for name, value := range curMap {
+			if value == 0 || form.Has(fmt.Sprintf("remove_block_%d_%s", x.ID, name)) {
+				continue
+			}
+
+			err := m.DB.DeleteBlockByID(value, x.ID)
+			if err != nil {
+				helpers.ServerError(w, r, err)
+				return
+			}
+
+			night, err := time.Parse("2006-01-2", name)
+			if err == nil {
+				m.offerFreedNights(r, x.ID, night, night.AddDate(0, 0, 1))
+			}
+		}
+	}
+
+	for name := range r.PostForm {
+		if !strings.HasPrefix(name, "add_block_") {
+			continue
+		}
+		exploded := strings.Split(name, "_")
+		if len(exploded) != 4 {
+			continue
+		}
+
+		roomID, err := strconv.Atoi(exploded[2])
+		if err != nil || !inProperty[roomID] {
+			helpers.ClientError(w, r, http.StatusBadRequest)
+			return
+		}
+		night, err := time.Parse("2006-01-2", exploded[3])
+		if err != nil {
+			helpers.ClientError(w, r, http.StatusBadRequest)
+			return
+		}
+
+		err = m.DB.InsertBlockForRoom(roomID, night)
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "changes saved")
+	http.Redirect(w, r, calendarURL, http.StatusSeeOther)
+}
+
+// Waitlist shows the form to join the waitlist, prefilled with the dates of
+// the last search.
+func (m *Repository) Waitlist(w http.ResponseWriter, r *http.Request) {
+	rooms, err := m.DB.AllRooms(helpers.CurrentProperty(r).ID)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "could not get rooms from DB")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+		return
+	}
+
+	adults, children := partySize(r.URL.Query())
+
+	data := make(map[string]interface{})
+	data["rooms"] = rooms
+	data["entry"] = models.WaitlistEntry{
+		Adults:   adults,
+		Children: children,
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["start"] = r.URL.Query().Get("start")
+	stringMap["end"] = r.URL.Query().Get("end")
+
+	renderPage(w, r, "waitlist.page.html", &models.TemplateData{
+		Form:      forms.New(nil),
+		Data:      data,
+		StringMap: stringMap,
+	})
+}
+
+// PostWaitlist adds a guest to the waitlist of the current property.
+func (m *Repository) PostWaitlist(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "could not parse form")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+		return
+	}
+
+	property := helpers.CurrentProperty(r)
+
+	input := waitlistInput{Adults: 1}
+	form := forms.New(r.PostForm)
+	form.Locale = helpers.CurrentLocale(r)
+	form.Bind(&input)
+
+	entry := models.WaitlistEntry{
+		Locale:     helpers.CurrentLocale(r),
+		PropertyID: property.ID,
+		RoomID:     input.RoomID,
+		FirstName:  input.FirstName,
+		LastName:   input.LastName,
+		Email:      input.Email,
+		StartDate:  input.Start,
+		EndDate:    input.End,
+		Adults:     input.Adults,
+		Children:   input.Children,
+	}
+
+	rooms, err := m.DB.AllRooms(property.ID)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "could not get rooms from DB")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+		return
+	}
+
+	if entry.RoomID != 0 {
+		room, err := m.DB.GetRoomById(entry.RoomID)
+		if err != nil || room.PropertyID != property.ID {
+			form.Errors.Add("room_id", form.T("Pick a room of this property"))
+		}
+	}
+
+	if !form.Valid() {
+		data := make(map[string]interface{})
+		data["rooms"] = rooms
+		data["entry"] = entry
+
+		stringMap := make(map[string]string)
+		stringMap["start"] = r.Form.Get("start")
+		stringMap["end"] = r.Form.Get("end")
+
+		renderPage(w, r, "waitlist.page.html", &models.TemplateData{
+			Form:      form,
+			Data:      data,
+			StringMap: stringMap,
+		})
+		return
+	}
+
+	_, err = m.DB.InsertWaitlistEntry(entry)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "could not add you to the waitlist")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash",
+		"you are on the waitlist, we will email you if a room frees up")
+	http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusSeeOther)
+}
+
+// WaitlistHold starts a booking for the room held by the link emailed to a
+// guest on the waitlist.
+func (m *Repository) WaitlistHold(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.URL.Path, "/")
+	token := exploded[len(exploded)-1]
+
+	entry, err := m.DB.GetWaitlistEntryByToken(token)
+	if err != nil || entry.PropertyID != helpers.CurrentProperty(r).ID {
+		m.App.Session.Put(r.Context(), "error", "that hold link is not valid")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/"), http.StatusTemporaryRedirect)
+		return
+	}
+
+	if !entry.HoldActive(time.Now()) {
+		m.App.Session.Put(r.Context(), "error",
+			"sorry, this hold has expired, please search again")
+		http.Redirect(w, r, helpers.PropertyURL(r, "/search-availability"),
+			http.StatusSeeOther)
+		return
+	}
+
+	reservation := models.Reservation{
+		FirstName: entry.FirstName,
+		LastName:  entry.LastName,
+		Email:     entry.Email,
+		StartDate: entry.StartDate,
+		EndDate:   entry.EndDate,
+		RoomID:    entry.HoldRoomID,
+		Adults:    entry.Adults,
+		Children:  entry.Children,
+	}
+
+	m.App.Session.Put(r.Context(), "reservation", reservation)
+	m.App.Session.Put(r.Context(), "waitlist_token", token)
+	http.Redirect(w, r, helpers.PropertyURL(r, "/make-reservation"), http.StatusSeeOther)
+}
+
+// reserveRoom books the room of res, or when it has just been taken the
+// first of the other units free of stay rules, and sets res to the room
+// booked. It returns repository.ErrRoomTaken when every one is taken.
+func (m *Repository) reserveRoom(res *models.Reservation, otherUnits []models.Room,
+	heldID int) (int, error) {
+
+	id, err := m.DB.ReserveRoom(*res, heldID)
+	for _, room := range otherUnits {
+		if !errors.Is(err, repository.ErrRoomTaken) {
+			break
+		}
+
+		roomRules, rulesErr := m.DB.GetRulesForRoomByDate(room.ID, res.StartDate, res.EndDate)
+		if rulesErr != nil {
+			return 0, rulesErr
+		}
+		if len(rules.Check(roomRules, res.StartDate, res.EndDate, i18n.Default)) > 0 {
+			continue
+		}
+
+		res.RoomID, res.Room = room.ID, room
+		id, err = m.DB.ReserveRoom(*res, 0)
+	}
+
+	return id, err
+}
+
+// waitlistHold returns the waitlist entry of the hold the guest is booking
+// through, and whether there is one still active.
+func (m *Repository) waitlistHold(r *http.Request) (models.WaitlistEntry, bool) {
+	token := m.App.Session.GetString(r.Context(), "waitlist_token")
+	if token == "" {
+		return models.WaitlistEntry{}, false
+	}
+
+	entry, err := m.DB.GetWaitlistEntryByToken(token)
+	if err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "could not find waitlist hold", "err", err)
+		return models.WaitlistEntry{}, false
+	}
+
+	return entry, entry.HoldActive(time.Now())
+}
+
+// completeWaitlistHold releases the hold a guest booked through, once their
+// reservation is in place.
+func (m *Repository) completeWaitlistHold(r *http.Request, res models.Reservation) {
+	entry, ok := m.waitlistHold(r)
+	m.App.Session.Remove(r.Context(), "waitlist_token")
+	if !ok || entry.HoldRoomID != res.RoomID {
+		return
+	}
+
+	err := m.DB.CompleteWaitlistHold(entry.ID)
+	if err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "could not complete waitlist hold", "err", err)
+	}
+}
+
+// offerFreedNights hands nights that just became free in a room to the
+// guests waiting for them. Failures are logged and never block the change
+// that freed the nights.
+func (m *Repository) offerFreedNights(r *http.Request, roomID int, start, end time.Time) {
+	held, err := waitlist.Offer(m.App, m.DB, helpers.CurrentProperty(r).ID,
+		roomID, start, end)
+	if err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "could not offer freed nights", "err", err)
+	}
+	if held > 0 {
+		m.App.Logger.InfoContext(r.Context(), "offered waitlist holds", "holds", held,
+			"room_id", roomID)
+	}
+}
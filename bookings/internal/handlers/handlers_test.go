@@ -10,9 +10,12 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/chenemiken/goland/bookings/helpers"
 	"github.com/chenemiken/goland/bookings/internal/drivers"
 	"github.com/chenemiken/goland/bookings/internal/models"
+	"github.com/chenemiken/goland/bookings/internal/repository"
 )
 
 type postData struct {
@@ -33,6 +36,7 @@ var theTests = []struct {
 	{"sa", "/search-availability", "GET", http.StatusOK},
 	{"rs", "/reservation-summary", "GET", http.StatusOK},
 	{"contact", "/contact", "GET", http.StatusOK},
+	{"waitlist", "/waitlist?start=2050-01-02&end=2050-01-04", "GET", http.StatusOK},
 }
 
 func TestNewRepo(t *testing.T) {
@@ -65,6 +69,57 @@ func TestHandlers(t *testing.T) {
 	}
 }
 
+func TestRepositoryLocale(t *testing.T) {
+	var tests = []struct {
+		name     string
+		locale   string
+		expected []string
+	}{
+		{"english", "en", []string{`<html lang="en">`, "Make Reservation Now"}},
+		{"french", "fr", []string{`<html lang="fr">`, "Réserver maintenant", "Accueil"}},
+		{"spanish", "es", []string{`<html lang="es">`, "Reservar ahora", "Inicio"}},
+	}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		req = helpers.WithLocale(req.WithContext(ctx), e.locale)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.Home)
+
+		handler.ServeHTTP(rr, req)
+
+		for _, text := range e.expected {
+			if !strings.Contains(rr.Body.String(), text) {
+				t.Errorf("%s: page does not contain %q", e.name, text)
+			}
+		}
+	}
+
+	// form errors follow the locale of the request
+	req, err := http.NewRequest("POST", "/waitlist",
+		strings.NewReader("start=2050-01-02&end=2050-01-04"))
+	if err != nil {
+		t.Error(err)
+	}
+	ctx := getctx(req)
+	req = helpers.WithLocale(req.WithContext(ctx), "fr")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.PostWaitlist)
+
+	handler.ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Body.String(), "Ce champ est obligatoire") {
+		t.Error("waitlist form errors are not in French")
+	}
+}
+
 func TestRepositoryReservation(t *testing.T) {
 	reservation := models.Reservation{
 		RoomID: 1,
@@ -353,6 +408,270 @@ func TestRepositoryPostReservation(t *testing.T) {
 	}
 }
 
+func TestRepositoryPostReservationValidation(t *testing.T) {
+	base := "start_date=2050-01-02&end_date=2050-01-04&room_id=1" +
+		"&first_name=John&last_name=Sule&email=sule@email.com"
+
+	var tests = []struct {
+		name               string
+		reqBody            string
+		expectedStatusCode int
+		expectedText       string
+	}{
+		{"bad phone", base + "&phone=call+me", http.StatusSeeOther,
+			"Invalid phone number"},
+		{"adults not a number", base + "&adults=two", http.StatusSeeOther,
+			"This field must be a whole number"},
+		{"long last name", strings.Replace(base, "Sule", strings.Repeat("x", 101), 1),
+			http.StatusSeeOther, "This field must be at most 100 characters long"},
+		{"missing room", "start_date=2050-01-02&end_date=2050-01-04" +
+			"&first_name=John&last_name=Sule&email=sule@email.com",
+			http.StatusTemporaryRedirect, ""},
+	}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("POST", "/make-reservation",
+			strings.NewReader(e.reqBody))
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostReservation)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), e.expectedText) {
+			t.Errorf("%s: page does not show %q", e.name, e.expectedText)
+		}
+	}
+}
+
+// TestRepositoryPostReservationOtherProperty checks a room or room type of
+// another property cannot be booked by posting its id.
+func TestRepositoryPostReservationOtherProperty(t *testing.T) {
+	base := "start_date=2050-01-02&end_date=2050-01-04" +
+		"&first_name=John&last_name=Sule&email=sule@email.com"
+
+	var tests = []struct {
+		name    string
+		reqBody string
+	}{
+		{"room", base + "&room_id=1"},
+		{"room type", base + "&room_id=0&room_type_id=1"},
+	}
+
+	property := models.Property{ID: 2, PropertyName: "Major's Suite", Slug: "majors-suite"}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("POST", "/make-reservation",
+			strings.NewReader(e.reqBody))
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		req = helpers.WithProperty(req.WithContext(ctx), property, "")
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostReservation)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusTemporaryRedirect {
+			t.Errorf("%s: expected %d but got %d", e.name, http.StatusTemporaryRedirect, rr.Code)
+		}
+		if session.GetString(ctx, "error") != "room does not belong to this property" {
+			t.Errorf("%s: expected the room to be refused but got error %q", e.name,
+				session.GetString(ctx, "error"))
+		}
+	}
+}
+
+// TestRepositoryPostReservationTaken checks a guest is told when the nights
+// they are booking have just been taken. Room 1 is taken from 2050-06-01 in
+// the test repo, but for the guest holding it.
+func TestRepositoryPostReservationTaken(t *testing.T) {
+	base := "start_date=2050-06-01&end_date=2050-06-03" +
+		"&first_name=John&last_name=Sule&email=sule@email.com"
+
+	var tests = []struct {
+		name             string
+		reqBody          string
+		token            string
+		expectedLocation string
+		expectedError    string
+	}{
+		{"room", base + "&room_id=1", "", "/search-availability",
+			"sorry, this room has just been booked for your dates"},
+		{"room type", base + "&room_id=0&room_type_id=1", "", "/search-availability",
+			"sorry, this room type has just been fully booked for your dates"},
+		{"held by the guest", base + "&room_id=1", "held", "/reservation-summary", ""},
+	}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("POST", "/make-reservation",
+			strings.NewReader(e.reqBody))
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		if e.token != "" {
+			session.Put(ctx, "waitlist_token", e.token)
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostReservation)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("%s: expected %d but got %d", e.name, http.StatusSeeOther, rr.Code)
+		}
+		if rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %s but got %s", e.name,
+				e.expectedLocation, rr.Header().Get("Location"))
+		}
+		if session.GetString(ctx, "error") != e.expectedError {
+			t.Errorf("%s: expected error %q but got %q", e.name, e.expectedError,
+				session.GetString(ctx, "error"))
+		}
+	}
+}
+
+func TestRepositoryPostReservationRoomType(t *testing.T) {
+	var tests = []struct {
+		name               string
+		roomTypeID         string
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{"unit assigned", "1", http.StatusSeeOther, "/reservation-summary"},
+		{"fully booked", "2", http.StatusSeeOther, "/search-availability"},
+		{"search fails", "3", http.StatusTemporaryRedirect, "/"},
+		{"invalid room type", "invalid", http.StatusTemporaryRedirect, "/"},
+	}
+
+	for _, e := range tests {
+		reqBody := "start_date=2050-01-02"
+		reqBody = fmt.Sprintf("%s&%s", reqBody, "end_date=2050-01-04")
+		reqBody = fmt.Sprintf("%s&%s", reqBody, "first_name=John")
+		reqBody = fmt.Sprintf("%s&%s", reqBody, "last_name=Sule")
+		reqBody = fmt.Sprintf("%s&%s", reqBody, "email=sule@email.com")
+		reqBody = fmt.Sprintf("%s&%s", reqBody, "phone=20544 343 334")
+		reqBody = fmt.Sprintf("%s&%s", reqBody, "room_id=0")
+		reqBody = fmt.Sprintf("%s&room_type_id=%s", reqBody, e.roomTypeID)
+
+		req, err := http.NewRequest("POST", "/make-reservation", strings.NewReader(reqBody))
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostReservation)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %s but got %s", e.name,
+				e.expectedLocation, rr.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRepositoryPostReservationStayRules(t *testing.T) {
+	// room 4 has a 30 night minimum stay in the test repo
+	reqBody := "start_date=2050-01-02"
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "end_date=2050-01-04")
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "first_name=John")
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "last_name=Sule")
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "email=sule@email.com")
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "room_id=4")
+
+	req, err := http.NewRequest("POST", "/make-reservation", strings.NewReader(reqBody))
+	if err != nil {
+		t.Error(err)
+	}
+	ctx := getctx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.PostReservation)
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("post reservation did not return appropriate response code, "+
+			"expected %d but got %d", http.StatusSeeOther, rr.Code)
+	}
+	if !strings.Contains(session.GetString(ctx, "error"), "minimum stay of 30 nights") {
+		t.Errorf("post reservation did not explain the stay rule, got %q",
+			session.GetString(ctx, "error"))
+	}
+}
+
+func TestRepositoryPostReservationCapacity(t *testing.T) {
+	// room 1 and the units of room type 1 sleep two guests in the test repo
+	var tests = []struct {
+		name               string
+		party              string
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{"fits room", "room_id=1&adults=2&children=0", http.StatusSeeOther,
+			"/reservation-summary"},
+		{"too many guests", "room_id=1&adults=2&children=1", http.StatusSeeOther, ""},
+		{"no adults", "room_id=1&adults=0", http.StatusSeeOther, ""},
+		{"too many guests for type", "room_id=0&room_type_id=1&adults=3",
+			http.StatusSeeOther, ""},
+	}
+
+	for _, e := range tests {
+		reqBody := "start_date=2050-01-02"
+		reqBody = fmt.Sprintf("%s&%s", reqBody, "end_date=2050-01-04")
+		reqBody = fmt.Sprintf("%s&%s", reqBody, "first_name=John")
+		reqBody = fmt.Sprintf("%s&%s", reqBody, "last_name=Sule")
+		reqBody = fmt.Sprintf("%s&%s", reqBody, "email=sule@email.com")
+		reqBody = fmt.Sprintf("%s&%s", reqBody, e.party)
+
+		req, err := http.NewRequest("POST", "/make-reservation", strings.NewReader(reqBody))
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostReservation)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %q but got %q", e.name,
+				e.expectedLocation, rr.Header().Get("Location"))
+		}
+	}
+}
+
 func TestRepositoryPostAvailability(t *testing.T) {
 	// test for when parsing form fails
 	req, err := http.NewRequest(
@@ -490,6 +809,86 @@ func TestRepositoryPostAvailability(t *testing.T) {
 			"expected %d but got %d", http.StatusOK, rr.Code)
 	}
 
+	// test for when no available room sleeps the party
+	reqBody = "start=2025-01-01"
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "end=2025-12-12")
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "adults=3&children=2")
+	req, err = http.NewRequest(
+		"POST", "/search-availability", strings.NewReader(reqBody))
+	if err != nil {
+		t.Error(err)
+	}
+
+	ctx = getctx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+	handler = http.HandlerFunc(Repo.PostAvailability)
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("Post search availability returned wrong response, "+
+			"expected %d but got %d", http.StatusSeeOther, rr.Code)
+	}
+	if !strings.Contains(session.GetString(ctx, "error"), "party of 5") {
+		t.Errorf("Post search availability did not explain the party size, got %q",
+			session.GetString(ctx, "error"))
+	}
+}
+
+func TestRepositoryPostAvailabilityFlexible(t *testing.T) {
+	// the test repo only has rooms for stays arriving on 2050-03-11
+	var tests = []struct {
+		name               string
+		reqBody            string
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{"exact dates", "start=2050-03-10&end=2050-03-13", http.StatusSeeOther,
+			"/waitlist?start=2050-03-10&end=2050-03-13&adults=1&children=0"},
+		{"flexible dates", "start=2050-03-10&end=2050-03-13&flex=2",
+			http.StatusOK, ""},
+		{"not flexible enough", "start=2050-03-08&end=2050-03-10&flex=1",
+			http.StatusSeeOther,
+			"/waitlist?start=2050-03-08&end=2050-03-10&adults=1&children=0"},
+		{"nights in month", "month=2050-03&nights=3", http.StatusOK, ""},
+		{"nights in full month", "month=2050-04&nights=3", http.StatusSeeOther,
+			"/search-availability"},
+		{"no nights", "month=2050-03&nights=0", http.StatusSeeOther,
+			"/search-availability"},
+		{"invalid month", "month=march&nights=3", http.StatusSeeOther,
+			"/search-availability"},
+		{"departure before arrival", "start=2050-03-13&end=2050-03-10",
+			http.StatusSeeOther, "/search-availability"},
+		{"departure on arrival", "start=2050-03-10&end=2050-03-10",
+			http.StatusSeeOther, "/search-availability"},
+	}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("POST", "/search-availability",
+			strings.NewReader(e.reqBody))
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostAvailability)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %q but got %q", e.name,
+				e.expectedLocation, rr.Header().Get("Location"))
+		}
+	}
 }
 
 func TestRepositoryPostAvailabilityJson(t *testing.T) {
@@ -610,6 +1009,76 @@ func TestRepositoryPostAvailabilityJson(t *testing.T) {
 	}
 }
 
+func TestRepositoryRoomCalendarJson(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedDays       int
+	}{
+		{"one month", "/room-calendar/1?from=2050-03", http.StatusOK, 31},
+		{"month range", "/room-calendar/1?from=2050-02&to=2050-03", http.StatusOK, 59},
+		{"invalid month", "/room-calendar/1?from=march", http.StatusBadRequest, 0},
+		{"range backwards", "/room-calendar/1?from=2050-03&to=2050-02",
+			http.StatusBadRequest, 0},
+		{"range too long", "/room-calendar/1?from=2050-01&to=2051-01",
+			http.StatusBadRequest, 0},
+		{"unknown room", "/room-calendar/100?from=2050-03", http.StatusNotFound, 0},
+		{"invalid room", "/room-calendar/abc", http.StatusBadRequest, 0},
+		{"db error", "/room-calendar/2?from=2050-03", http.StatusInternalServerError, 0},
+	}
+
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", e.url, nil)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.RoomCalendarJson)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+
+		var resp calendarResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &resp)
+		if err != nil {
+			t.Errorf("%s: failed to parse json: %s", e.name, err)
+		}
+		if len(resp.Days) != e.expectedDays {
+			t.Errorf("%s: expected %d days but got %d", e.name, e.expectedDays,
+				len(resp.Days))
+		}
+	}
+
+	// the 5th is booked in the test repo
+	req := httptest.NewRequest("GET", "/room-calendar/1?from=2050-03", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Repo.RoomCalendarJson).ServeHTTP(rr, req)
+
+	var resp calendarResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Days[4].Date != "2050-03-05" || resp.Days[4].Available {
+		t.Errorf("expected 2050-03-05 to be unavailable, got %+v", resp.Days[4])
+	}
+	if rr.Header().Get("Cache-Control") == "" || rr.Header().Get("ETag") == "" {
+		t.Error("room calendar is missing caching headers")
+	}
+
+	// a matching ETag is answered without a body
+	req = httptest.NewRequest("GET", "/room-calendar/1?from=2050-03", nil)
+	req.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Repo.RoomCalendarJson).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected %d for a matching ETag but got %d",
+			http.StatusNotModified, rr.Code)
+	}
+	if rr.Body.Len() != 0 {
+		t.Error("not modified response should not have a body")
+	}
+}
+
 func TestRepositoryReservationSummary(t *testing.T) {
 	//Testing instance where there is no reservation in session
 	req, err := http.NewRequest("GET", "/reservation-summary", nil)
@@ -684,6 +1153,30 @@ func TestRepositoryChooseRoom(t *testing.T) {
 		t.Errorf("ChooseRoom returned wrong response, "+
 			"expected %d but got %d", http.StatusSeeOther, rr.Code)
 	}
+
+	// Test picking an alternative stay from a flexible search
+	req, err = http.NewRequest("GET", "/choose-room/1?s=2050-03-11&e=2050-03-14", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	ctx = getctx(req)
+	req = req.WithContext(ctx)
+	req.RequestURI = "/choose-room/1?s=2050-03-11&e=2050-03-14"
+	session.Put(ctx, "reservation", models.Reservation{})
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("ChooseRoom returned wrong response, "+
+			"expected %d but got %d", http.StatusSeeOther, rr.Code)
+	}
+	res, _ := session.Get(ctx, "reservation").(models.Reservation)
+	if res.StartDate.Format("2006-01-02") != "2050-03-11" ||
+		res.EndDate.Format("2006-01-02") != "2050-03-14" || res.RoomTypeID != 1 {
+		t.Errorf("ChooseRoom did not store the alternative stay, got %v to %v",
+			res.StartDate, res.EndDate)
+	}
 }
 
 func TestRepositoryBookRoom(t *testing.T) {
@@ -705,6 +1198,463 @@ func TestRepositoryBookRoom(t *testing.T) {
 	}
 }
 
+func TestRepositoryAdminRestoreReservation(t *testing.T) {
+	// Test restoring when the room is still free
+	req, err := http.NewRequest("GET", "/admin/restore-reservation/2", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	ctx := getctx(req)
+	req = req.WithContext(ctx)
+	req.RequestURI = "/admin/restore-reservation/2"
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminRestoreReservation)
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("AdminRestoreReservation returned wrong response, "+
+			"expected %d but got %d", http.StatusSeeOther, rr.Code)
+	}
+	if session.GetString(ctx, "flash") != "reservation restored" {
+		t.Error("AdminRestoreReservation did not restore an available reservation")
+	}
+
+	// Test restoring when the room has been taken since
+	req, err = http.NewRequest("GET", "/admin/restore-reservation/1", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	ctx = getctx(req)
+	req = req.WithContext(ctx)
+	req.RequestURI = "/admin/restore-reservation/1"
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("AdminRestoreReservation returned wrong response, "+
+			"expected %d but got %d", http.StatusSeeOther, rr.Code)
+	}
+	if session.GetString(ctx, "error") == "" {
+		t.Error("AdminRestoreReservation restored a reservation over a taken room")
+	}
+
+	// Test a reservation that cannot be found
+	req, err = http.NewRequest("GET", "/admin/restore-reservation/2000", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	ctx = getctx(req)
+	req = req.WithContext(ctx)
+	req.RequestURI = "/admin/restore-reservation/2000"
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("AdminRestoreReservation returned wrong response, "+
+			"expected %d but got %d", http.StatusInternalServerError, rr.Code)
+	}
+}
+
+func TestRepositoryAdminSwitchProperty(t *testing.T) {
+	// Test switching to a property the user may manage
+	req, err := http.NewRequest("GET", "/admin/switch-property/1", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	ctx := getctx(req)
+	session.Put(ctx, "user_id", 1)
+	req = req.WithContext(ctx)
+	req.RequestURI = "/admin/switch-property/1"
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminSwitchProperty)
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("AdminSwitchProperty returned wrong response, "+
+			"expected %d but got %d", http.StatusSeeOther, rr.Code)
+	}
+	if session.GetInt(ctx, "property_id") != 1 {
+		t.Error("AdminSwitchProperty did not store the chosen property")
+	}
+
+	// Test switching to a property the user may not manage
+	req, err = http.NewRequest("GET", "/admin/switch-property/5", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	ctx = getctx(req)
+	session.Put(ctx, "user_id", 1)
+	req = req.WithContext(ctx)
+	req.RequestURI = "/admin/switch-property/5"
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("AdminSwitchProperty returned wrong response, "+
+			"expected %d but got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestRepositoryAdminProperties(t *testing.T) {
+	var tests = []struct {
+		name     string
+		userID   int
+		expected int
+	}{
+		{"not logged in", 0, 0},
+		{"administrator", 1, 1},
+		{"assigned", 2, 1},
+		{"not assigned", 3, 0},
+	}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("GET", "/admin/properties", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		if e.userID > 0 {
+			session.Put(ctx, "user_id", e.userID)
+		}
+		req = req.WithContext(ctx)
+
+		properties, err := Repo.AdminProperties(req)
+		if err != nil {
+			t.Errorf("%s: %v", e.name, err)
+		}
+		if len(properties) != e.expected {
+			t.Errorf("%s: expected %d properties but got %+v", e.name, e.expected, properties)
+		}
+	}
+}
+
+func TestRepositoryAdminPostProperty(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		body               string
+		expectedStatusCode int
+		expectedError      string
+	}{
+		{"valid settings", "/admin/properties/1",
+			"timezone=Europe/London&check_in_time=15:00&check_out_time=11:00",
+			http.StatusSeeOther, ""},
+		{"same-day turnover", "/admin/properties/1",
+			"timezone=America/New_York&check_in_time=12:00&check_out_time=12:00",
+			http.StatusSeeOther, ""},
+		{"unknown timezone", "/admin/properties/1",
+			"timezone=Mars/Olympus&check_in_time=15:00&check_out_time=11:00",
+			http.StatusSeeOther, "unknown timezone"},
+		{"missing timezone", "/admin/properties/1",
+			"check_in_time=15:00&check_out_time=11:00",
+			http.StatusSeeOther, "unknown timezone"},
+		{"bad check-in", "/admin/properties/1",
+			"timezone=UTC&check_in_time=3pm&check_out_time=11:00",
+			http.StatusSeeOther, "check-in time must be written as hh:mm"},
+		{"bad check-out", "/admin/properties/1",
+			"timezone=UTC&check_in_time=15:00&check_out_time=25:00",
+			http.StatusSeeOther, "check-out time must be written as hh:mm"},
+		{"check-in before check-out", "/admin/properties/1",
+			"timezone=UTC&check_in_time=10:00&check_out_time=11:00",
+			http.StatusSeeOther, "check-in cannot be earlier than check-out"},
+		{"not managed", "/admin/properties/5",
+			"timezone=UTC&check_in_time=15:00&check_out_time=11:00",
+			http.StatusForbidden, ""},
+	}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("POST", e.url, strings.NewReader(e.body))
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		session.Put(ctx, "user_id", 1)
+		req = req.WithContext(ctx)
+		req.RequestURI = e.url
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostProperty)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if session.GetString(ctx, "error") != e.expectedError {
+			t.Errorf("%s: expected error %q but got %q", e.name, e.expectedError,
+				session.GetString(ctx, "error"))
+		}
+	}
+}
+
+func TestRepositoryAdminReassignReservation(t *testing.T) {
+	var tests = []struct {
+		name               string
+		roomID             string
+		expectedStatusCode int
+		expectedFlash      string
+	}{
+		{"room free", "2", http.StatusSeeOther, "reservation moved"},
+		{"room taken", "1", http.StatusSeeOther, ""},
+		{"missing room", "invalid", http.StatusBadRequest, ""},
+	}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("POST", "/admin/reassign-reservation/all/1",
+			strings.NewReader("room_id="+e.roomID))
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		req = req.WithContext(ctx)
+		req.RequestURI = "/admin/reassign-reservation/all/1"
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminReassignReservation)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if session.GetString(ctx, "flash") != e.expectedFlash {
+			t.Errorf("%s: expected flash %q but got %q", e.name, e.expectedFlash,
+				session.GetString(ctx, "flash"))
+		}
+	}
+}
+
+func TestRepositoryAdminPostRoomRule(t *testing.T) {
+	var tests = []struct {
+		name               string
+		body               string
+		expectedStatusCode int
+		expectedFlash      string
+	}{
+		{"valid rule", "room_id=1&restriction_id=3&start=2050-03-01&end=2050-03-31&value=3",
+			http.StatusSeeOther, "stay rule added"},
+		{"missing dates", "room_id=1&restriction_id=3&value=3",
+			http.StatusSeeOther, ""},
+		{"min stay without nights", "room_id=1&restriction_id=3&start=2050-03-01&end=2050-03-31",
+			http.StatusSeeOther, ""},
+		{"end before start", "room_id=1&restriction_id=5&start=2050-03-10&end=2050-03-01",
+			http.StatusSeeOther, ""},
+		{"not a rule", "room_id=1&restriction_id=1&start=2050-03-01&end=2050-03-31",
+			http.StatusBadRequest, ""},
+	}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("POST", "/admin/room-rules", strings.NewReader(e.body))
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostRoomRule)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if session.GetString(ctx, "flash") != e.expectedFlash {
+			t.Errorf("%s: expected flash %q but got %q", e.name, e.expectedFlash,
+				session.GetString(ctx, "flash"))
+		}
+	}
+}
+
+func TestRepositoryPostWaitlist(t *testing.T) {
+	var tests = []struct {
+		name               string
+		reqBody            string
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{"valid", "start=2050-01-02&end=2050-01-04&first_name=John&last_name=Sule" +
+			"&email=sule@email.com&adults=2", http.StatusSeeOther, "/"},
+		{"any room", "start=2050-01-02&end=2050-01-04&first_name=John&last_name=Sule" +
+			"&email=sule@email.com&room_id=0", http.StatusSeeOther, "/"},
+		{"missing email", "start=2050-01-02&end=2050-01-04&first_name=John" +
+			"&last_name=Sule", http.StatusOK, ""},
+		{"end before start", "start=2050-01-04&end=2050-01-02&first_name=John" +
+			"&last_name=Sule&email=sule@email.com", http.StatusOK, ""},
+		{"unknown room", "start=2050-01-02&end=2050-01-04&first_name=John" +
+			"&last_name=Sule&email=sule@email.com&room_id=1000", http.StatusOK, ""},
+	}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("POST", "/waitlist", strings.NewReader(e.reqBody))
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostWaitlist)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %q but got %q", e.name,
+				e.expectedLocation, rr.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRepositoryWaitlistHold(t *testing.T) {
+	var tests = []struct {
+		name               string
+		token              string
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{"active hold", "held", http.StatusSeeOther, "/make-reservation"},
+		{"expired hold", "expired", http.StatusSeeOther, "/search-availability"},
+		{"unknown token", "nope", http.StatusTemporaryRedirect, "/"},
+	}
+
+	property := models.Property{ID: 1, PropertyName: "Fort Smythe", Slug: "fort-smythe"}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("GET", "/waitlist/hold/"+e.token, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		req = helpers.WithProperty(req.WithContext(ctx), property, "")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.WaitlistHold)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %q but got %q", e.name,
+				e.expectedLocation, rr.Header().Get("Location"))
+		}
+		if e.token == "held" {
+			res, ok := session.Get(ctx, "reservation").(models.Reservation)
+			if !ok || res.RoomID != 1 {
+				t.Errorf("%s: hold did not put the held room in the session", e.name)
+			}
+			if session.GetString(ctx, "waitlist_token") != "held" {
+				t.Errorf("%s: hold did not remember the token", e.name)
+			}
+		}
+	}
+}
+
+func TestRepositoryAdminPostReservationCalendar(t *testing.T) {
+	var tests = []struct {
+		name               string
+		reqBody            string
+		blocks             map[string]int
+		expectedStatusCode int
+	}{
+		{"remove block", "y=2050&m=01", map[string]int{"2050-01-3": 7},
+			http.StatusSeeOther},
+		{"keep block", "y=2050&m=01&remove_block_1_2050-01-3=7",
+			map[string]int{"2050-01-3": 7}, http.StatusSeeOther},
+		{"add block", "y=2050&m=01&add_block_1_2050-01-5=on", nil,
+			http.StatusSeeOther},
+		{"room of another property", "y=2050&m=01&add_block_1000_2050-01-5=on", nil,
+			http.StatusBadRequest},
+		{"bad date", "y=2050&m=01&add_block_1_2050-13-5=on", nil,
+			http.StatusBadRequest},
+	}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("POST", "/admin/reservation-calendar",
+			strings.NewReader(e.reqBody))
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if e.blocks != nil {
+			session.Put(ctx, "block_map_1", e.blocks)
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostReservationCalendar)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+	}
+}
+
+func TestRepositoryAdminShortenReservation(t *testing.T) {
+	// reservations in the test repo run from 2050-01-02 to 2050-01-06
+	var tests = []struct {
+		name          string
+		dates         string
+		expectedURL   string
+		expectedError string
+	}{
+		{"shortened", "start_date=2050-01-03&end_date=2050-01-05",
+			"/admin/reservations-all", ""},
+		{"unchanged", "start_date=2050-01-02&end_date=2050-01-06",
+			"/admin/reservations-all", ""},
+		{"extended", "start_date=2050-01-01&end_date=2050-01-06",
+			"/admin/reservations/all/1",
+			"a stay can only be shortened here, book the extra nights separately"},
+	}
+
+	for _, e := range tests {
+		req, err := http.NewRequest("POST", "/admin/reservations/all/1",
+			strings.NewReader("first_name=John&"+e.dates))
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		req = req.WithContext(ctx)
+		req.RequestURI = "/admin/reservations/all/1"
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostShowReservation)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("%s: expected %d but got %d", e.name, http.StatusSeeOther, rr.Code)
+		}
+		if rr.Header().Get("Location") != e.expectedURL {
+			t.Errorf("%s: expected redirect to %q but got %q", e.name,
+				e.expectedURL, rr.Header().Get("Location"))
+		}
+		if session.GetString(ctx, "error") != e.expectedError {
+			t.Errorf("%s: expected error %q but got %q", e.name, e.expectedError,
+				session.GetString(ctx, "error"))
+		}
+	}
+}
+
 func getctx(req *http.Request) context.Context {
 	ctx, err := session.Load(req.Context(), req.Header.Get("X-Session"))
 	if err != nil {
@@ -712,3 +1662,124 @@ func getctx(req *http.Request) context.Context {
 	}
 	return ctx
 }
+
+// recordingSessions records whose sessions are ended.
+type recordingSessions struct {
+	ended []int
+}
+
+func (s *recordingSessions) DeleteUserSessions(userID int) (int, error) {
+	s.ended = append(s.ended, userID)
+	return 2, nil
+}
+
+func TestRepositoryAdminSignOutUser(t *testing.T) {
+	sessions := &recordingSessions{}
+	var tests = []struct {
+		name               string
+		userID             int
+		sessions           UserSessions
+		expectedStatusCode int
+		expectedFlash      string
+		expectedError      string
+		expectedEnded      []int
+	}{
+		{"admin", 1, sessions, http.StatusSeeOther, "ended 2 sessions of ", "", []int{1}},
+		{"not an admin", 2, sessions, http.StatusForbidden, "", "", nil},
+		{"not logged in", 0, sessions, http.StatusForbidden, "", "", nil},
+		{"sessions in memory", 1, nil, http.StatusSeeOther, "",
+			"sessions are kept in memory and cannot be ended by user", nil},
+	}
+
+	saved := Repo.Sessions
+	defer func() { Repo.Sessions = saved }()
+
+	for _, e := range tests {
+		sessions.ended = nil
+		Repo.Sessions = e.sessions
+
+		req, err := http.NewRequest("POST", "/admin/users/1/sign-out", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		ctx := getctx(req)
+		if e.userID != 0 {
+			session.Put(ctx, "user_id", e.userID)
+		}
+		req = req.WithContext(ctx)
+		req.RequestURI = "/admin/users/1/sign-out"
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminSignOutUser)
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s: expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if session.GetString(ctx, "flash") != e.expectedFlash {
+			t.Errorf("%s: expected flash %q but got %q", e.name, e.expectedFlash,
+				session.GetString(ctx, "flash"))
+		}
+		if session.GetString(ctx, "error") != e.expectedError {
+			t.Errorf("%s: expected error %q but got %q", e.name, e.expectedError,
+				session.GetString(ctx, "error"))
+		}
+		if !reflect.DeepEqual(sessions.ended, e.expectedEnded) {
+			t.Errorf("%s: expected the sessions of %v ended but got %v", e.name,
+				e.expectedEnded, sessions.ended)
+		}
+	}
+}
+
+// countingRepo counts the availability searches reaching the repository.
+type countingRepo struct {
+	repository.DatabaseRepo
+	searches int
+}
+
+func (c *countingRepo) SearchAvailabilityForAllRooms(propertyID int,
+	start, end time.Time) ([]models.RoomType, error) {
+	c.searches++
+	return c.DatabaseRepo.SearchAvailabilityForAllRooms(propertyID, start, end)
+}
+
+// TestRepositoryPostAvailabilityFlexibleQueries checks the alternatives are
+// not searched for one candidate stay at a time.
+func TestRepositoryPostAvailabilityFlexibleQueries(t *testing.T) {
+	saved := Repo.DB
+	defer func() { Repo.DB = saved }()
+
+	var tests = []struct {
+		name     string
+		reqBody  string
+		expected int
+	}{
+		{"flexible dates", "start=2050-03-10&end=2050-03-13&flex=7", 1},
+		{"nights in month", "month=2050-03&nights=3", 0},
+	}
+
+	for _, e := range tests {
+		counting := &countingRepo{DatabaseRepo: saved}
+		Repo.DB = counting
+
+		req, err := http.NewRequest("POST", "/search-availability",
+			strings.NewReader(e.reqBody))
+		if err != nil {
+			t.Error(err)
+		}
+		req = req.WithContext(getctx(req))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Repo.PostAvailability).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected %d but got %d", e.name, http.StatusOK, rr.Code)
+		}
+		if counting.searches != e.expected {
+			t.Errorf("%s: expected %d searches but got %d", e.name, e.expected,
+				counting.searches)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -12,7 +13,9 @@ import (
 	"time"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/chenemiken/goland/bookings/helpers"
 	"github.com/chenemiken/goland/bookings/internal/config"
+	"github.com/chenemiken/goland/bookings/internal/i18n"
 	"github.com/chenemiken/goland/bookings/internal/models"
 	"github.com/chenemiken/goland/bookings/internal/render"
 	"github.com/go-chi/chi/v5"
@@ -24,18 +27,26 @@ var app config.AppConfig
 var session scs.SessionManager
 
 var pathToTemplates = "./../../templates"
-var functions = template.FuncMap{}
+var functions = template.FuncMap{
+	"humanDate":    render.HumanDate,
+	"formatDate":   render.FormatDate,
+	"iterate":      render.Iterate,
+	"add":          render.Add,
+	"t":            i18n.T,
+	"localDate":    i18n.FormatDate,
+	"localMonth":   i18n.FormatMonth,
+	"number":       i18n.FormatNumber,
+	"languages":    render.Languages,
+	"languageName": render.LanguageName,
+	"asset":        render.Asset,
+}
 
 func TestMain(m *testing.M) {
 	gob.Register(models.Reservation{})
 
 	app.InProduction = false
 
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	app.InfoLog = infoLog
-
-	errorLog := log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	app.ErrorLog = errorLog
+	app.Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
 
 	mailChan := make(chan models.MailData)
 	app.MailChan = mailChan
@@ -60,6 +71,7 @@ func TestMain(m *testing.M) {
 	app.Session = &session
 
 	render.NewRenderer(&app)
+	helpers.NewHelpers(&app)
 
 	repo := NewTestRepo(&app)
 	NewHandlers(repo)
@@ -82,10 +94,12 @@ func getRoutes() http.Handler {
 	mux.Get("/search-availability", Repo.Availability)
 	mux.Post("/search-availability", Repo.PostAvailability)
 	mux.Post("/search-availability-json", Repo.PostAvailabilityJson)
+	mux.Get("/room-calendar/{id}", Repo.RoomCalendarJson)
 
 	mux.Get("/make-reservation", Repo.Reservation)
 	mux.Post("/make-reservation", Repo.PostReservation)
 	mux.Get("/reservation-summary", Repo.ReservationSummary)
+	mux.Get("/waitlist", Repo.Waitlist)
 
 	mux.Get("/contact", Repo.Contact)
 
@@ -0,0 +1,85 @@
+package i18n
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var monthNames = map[string][12]string{
+	"fr": {"janvier", "février", "mars", "avril", "mai", "juin", "juillet",
+		"août", "septembre", "octobre", "novembre", "décembre"},
+	"es": {"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio",
+		"agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+}
+
+// separators holds the thousands and decimal separators of a locale.
+var separators = map[string][2]string{
+	"en": {",", "."},
+	"fr": {"\u202f", ","},
+	"es": {".", ","},
+}
+
+// FormatDate writes the calendar day of t the way locale spells out dates.
+func FormatDate(locale string, t time.Time) string {
+	names, ok := monthNames[locale]
+	if !ok {
+		return t.Format("January 2, 2006")
+	}
+
+	month := names[t.Month()-1]
+	if locale == "es" {
+		return fmt.Sprintf("%d de %s de %d", t.Day(), month, t.Year())
+	}
+	return fmt.Sprintf("%d %s %d", t.Day(), month, t.Year())
+}
+
+// FormatMonth writes the month and year of t in locale.
+func FormatMonth(locale string, t time.Time) string {
+	names, ok := monthNames[locale]
+	if !ok {
+		return t.Format("January 2006")
+	}
+
+	month := names[t.Month()-1]
+	if locale == "es" {
+		return fmt.Sprintf("%s de %d", month, t.Year())
+	}
+	return fmt.Sprintf("%s %d", month, t.Year())
+}
+
+// FormatNumber writes n with the given number of decimals, grouping
+// thousands with the separators of locale.
+func FormatNumber(locale string, n float64, decimals int) string {
+	sep, ok := separators[locale]
+	if !ok {
+		sep = separators[Default]
+	}
+
+	s := strconv.FormatFloat(n, 'f', decimals, 64)
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+
+	var b strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(sep[0])
+		}
+		b.WriteRune(c)
+	}
+	if frac != "" {
+		b.WriteString(sep[1])
+		b.WriteString(frac)
+	}
+
+	return sign + b.String()
+}
@@ -0,0 +1,97 @@
+// Package i18n holds the message catalogs of the site and picks the locale a
+// visitor is served in. Messages are keyed by their English text, so a
+// message missing from a catalog falls back to English.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the locale used when nothing better matches.
+const Default = "en"
+
+// Supported lists the locales the site is translated into, Default first.
+var Supported = []string{"en", "fr", "es"}
+
+// Names holds the name of each supported locale in its own language.
+var Names = map[string]string{
+	"en": "English",
+	"fr": "Français",
+	"es": "Español",
+}
+
+var catalogs = map[string]map[string]string{
+	"fr": fr,
+	"es": es,
+}
+
+// IsSupported reports whether locale is one of Supported.
+func IsSupported(locale string) bool {
+	for _, l := range Supported {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// T translates key into locale. When args are given the translation is used
+// as a fmt format string.
+func T(locale, key string, args ...interface{}) string {
+	msg, ok := catalogs[locale][key]
+	if !ok {
+		msg = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Match picks the supported locale that best fits an Accept-Language header,
+// or Default when none does.
+func Match(acceptLanguage string) string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+
+	var prefs []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err == nil {
+					q = v
+				}
+			}
+		}
+
+		// only the primary language is matched, so en-GB counts as en
+		primary := strings.SplitN(tag, "-", 2)[0]
+		prefs = append(prefs, weighted{primary, q})
+	}
+
+	sort.SliceStable(prefs, func(i, j int) bool {
+		return prefs[i].q > prefs[j].q
+	})
+
+	for _, p := range prefs {
+		if p.q > 0 && IsSupported(p.locale) {
+			return p.locale
+		}
+	}
+
+	return Default
+}
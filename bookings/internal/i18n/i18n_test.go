@@ -0,0 +1,123 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestT(t *testing.T) {
+	var tests = []struct {
+		name     string
+		locale   string
+		key      string
+		args     []interface{}
+		expected string
+	}{
+		{"english", "en", "Invalid date", nil, "Invalid date"},
+		{"french", "fr", "Invalid date", nil, "Date invalide"},
+		{"spanish with args", "es", "%d nights", []interface{}{3}, "3 noches"},
+		{"missing key", "fr", "not in the catalog", nil, "not in the catalog"},
+		{"missing key with args", "fr", "%d things", []interface{}{2}, "2 things"},
+		{"unknown locale", "de", "Invalid date", nil, "Invalid date"},
+		{"empty locale", "", "Invalid date", nil, "Invalid date"},
+	}
+
+	for _, e := range tests {
+		got := T(e.locale, e.key, e.args...)
+		if got != e.expected {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestCatalogs(t *testing.T) {
+	for locale, catalog := range catalogs {
+		for key, msg := range catalog {
+			if strings.Count(key, "%") != strings.Count(msg, "%") {
+				t.Errorf("%s: %q does not keep the verbs of %q", locale, msg, key)
+			}
+		}
+
+		// every catalog should cover the same messages
+		for other, otherCatalog := range catalogs {
+			for key := range otherCatalog {
+				if _, ok := catalog[key]; !ok {
+					t.Errorf("%s is missing %q, which %s has", locale, key, other)
+				}
+			}
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	var tests = []struct {
+		header   string
+		expected string
+	}{
+		{"", "en"},
+		{"fr", "fr"},
+		{"fr-CA,fr;q=0.9,en;q=0.8", "fr"},
+		{"de-DE,de;q=0.9,es;q=0.8,en;q=0.5", "es"},
+		{"en;q=0.4,es;q=0.7", "es"},
+		{"es;q=0,fr;q=0.1", "fr"},
+		{"de,it", "en"},
+		{" ES-es ", "es"},
+	}
+
+	for _, e := range tests {
+		got := Match(e.header)
+		if got != e.expected {
+			t.Errorf("Match(%q): expected %q but got %q", e.header, e.expected, got)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	day := time.Date(2050, 8, 2, 0, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		locale string
+		date   string
+		month  string
+	}{
+		{"en", "August 2, 2050", "August 2050"},
+		{"fr", "2 août 2050", "août 2050"},
+		{"es", "2 de agosto de 2050", "agosto de 2050"},
+		{"de", "August 2, 2050", "August 2050"},
+	}
+
+	for _, e := range tests {
+		if got := FormatDate(e.locale, day); got != e.date {
+			t.Errorf("FormatDate(%q): expected %q but got %q", e.locale, e.date, got)
+		}
+		if got := FormatMonth(e.locale, day); got != e.month {
+			t.Errorf("FormatMonth(%q): expected %q but got %q", e.locale, e.month, got)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	var tests = []struct {
+		locale   string
+		n        float64
+		decimals int
+		expected string
+	}{
+		{"en", 1234567.891, 2, "1,234,567.89"},
+		{"fr", 1234567.891, 2, "1 234 567,89"},
+		{"es", 1234567.891, 2, "1.234.567,89"},
+		{"en", 999, 0, "999"},
+		{"en", -1000, 0, "-1,000"},
+		{"es", 0.5, 1, "0,5"},
+		{"de", 1000, 0, "1,000"},
+	}
+
+	for _, e := range tests {
+		got := FormatNumber(e.locale, e.n, e.decimals)
+		if got != e.expected {
+			t.Errorf("FormatNumber(%q, %v): expected %q but got %q", e.locale, e.n,
+				e.expected, got)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package i18n
+
+var es = map[string]string{
+	// pages
+	"%d adults, %d children":         "%d adultos, %d niños",
+	"%d nights":                      "%d noches",
+	"%d nights in %s":                "%d noches en %s",
+	"%d of %d available":             "%d de %d disponibles",
+	"%s, by %s":                      "%s, antes de las %s",
+	"%s, from %s":                    "%s, desde las %s",
+	"About":                          "Acerca de",
+	"Admin":                          "Administración",
+	"Adults":                         "Adultos",
+	"Adults:":                        "Adultos:",
+	"Any room":                       "Cualquier habitación",
+	"Arrival":                        "Llegada",
+	"Arrival: %s, check-in from %s":  "Llegada: %s, entrada desde las %s",
+	"Arrival:":                       "Llegada:",
+	"Book Now":                       "Reservar",
+	"Check Availability":             "Ver disponibilidad",
+	"Children":                       "Niños",
+	"Children:":                      "Niños:",
+	"Choose a room":                  "Elija una habitación",
+	"Choose your dates":              "Elija sus fechas",
+	"Contact":                        "Contacto",
+	"Dashboard":                      "Panel",
+	"Departure":                      "Salida",
+	"Departure: %s, check-out by %s": "Salida: %s, dejar la habitación antes de las %s",
+	"Departure:":                     "Salida:",
+	"Email:":                         "Correo electrónico:",
+	"Exact":                          "Exactas",
+	"Find Dates":                     "Buscar fechas",
+	"First Name:":                    "Nombre:",
+	"Flexible by %d days":            "Flexibles en %d días",
+	"Flexible by 1 day":              "Flexibles en 1 día",
+	"Flexible by a week":             "Flexibles en una semana",
+	"General's Quarters":             "Aposentos del General",
+	"Guests:":                        "Huéspedes:",
+	"Home":                           "Inicio",
+	"Join Waitlist":                  "Apuntarme",
+	"Join the Waitlist":              "Lista de espera",
+	"Last Name:":                     "Apellidos:",
+	"Login":                          "Iniciar sesión",
+	"Logout":                         "Cerrar sesión",
+	"Major's Suite":                  "Suite del Mayor",
+	"Make Reservation Now":           "Reservar ahora",
+	"Make Reservation":               "Reservar",
+	"Month":                          "Mes",
+	"My Nice Page":                   "Mi bonita página",
+	"My dates are":                   "Mis fechas son",
+	"Name:":                          "Nombre:",
+	"Nights":                         "Noches",
+	"No availability":                "Sin disponibilidad",
+	"Nothing is free for your exact dates, but these dates are:": "No hay nada libre en sus fechas exactas, pero sí en estas:",
+	"Or stay sometime in a month":                                "O alójese algún momento del mes",
+	"Other dates":                                                "Otras fechas",
+	"Password:":                                                  "Contraseña:",
+	"Phone:":                                                     "Teléfono:",
+	"Reservation Summary":                                        "Resumen de la reserva",
+	"Room Name:":                                                 "Habitación:",
+	"Room is available!":                                         "¡La habitación está disponible!",
+	"Room:":                                                      "Habitación:",
+	"Rooms free for %s:":                                         "Habitaciones libres para %s:",
+	"Rooms":                                                      "Habitaciones",
+	"Search Availability":                                        "Buscar",
+	"Search for Availability":                                    "Buscar disponibilidad",
+	"Sleeps up to %d":                                            "Hasta %d personas",
+	"Tell us when you would like to stay. If a room frees up for those dates we will hold it for you and email you a link to book it.": "Díganos cuándo le gustaría alojarse. Si se libera una habitación para esas fechas se la guardaremos y le enviaremos por correo un enlace para reservarla.",
+	"This will be the about":                   "Esta será la página de información",
+	"This will be the contact page":            "Esta será la página de contacto",
+	"Welcome to Fort Smythe Bed and Breakfast": "Bienvenido al Bed and Breakfast de Fort Smythe",
+	"Your home away form home, set on the majestic waters of the Atlantic Ocean, this will be a vacation to remember.": "Su hogar lejos de casa, junto a las majestuosas aguas del océano Atlántico, serán unas vacaciones para recordar.",
+	"current": "actual",
+
+	// forms
+	"Invalid date":                                   "Fecha no válida",
+	"Invalid email address":                          "Correo electrónico no válido",
+	"Pick a room of this property":                   "Elija una habitación de este alojamiento",
+	"This field is required":                         "Este campo es obligatorio",
+	"This field must be a number from %d to %d":      "Este campo debe ser un número del %d al %d",
+	"This field must be at least %d characters long": "Este campo debe tener al menos %d caracteres",
+	"This room sleeps at most %d guests":             "Esta habitación admite como máximo %d personas",
+	"This field must be at most %d characters long":  "Este campo debe tener como máximo %d caracteres",
+	"This field must be at least %v":                 "Este campo debe ser como mínimo %v",
+	"This field must be at most %v":                  "Este campo debe ser como máximo %v",
+	"Pick at least %d options":                       "Elija al menos %d opciones",
+	"Pick at most %d options":                        "Elija como máximo %d opciones",
+	"This field must be a whole number":              "Este campo debe ser un número entero",
+	"This field must be a number":                    "Este campo debe ser un número",
+	"Invalid value":                                  "Valor no válido",
+	"Invalid phone number":                           "Número de teléfono no válido",
+	"This field is not in the expected format":       "Este campo no tiene el formato esperado",
+	"Pick one of the listed options":                 "Elija una de las opciones indicadas",
+	"This date must be after %s":                     "Esta fecha debe ser posterior al %s",
+
+	// notifications
+	"Arrivals are not possible between %s and %s":                                 "No es posible llegar entre el %s y el %s",
+	"Arrivals between %s and %s allow a maximum stay of %d nights":                "Las llegadas entre el %s y el %s permiten una estancia máxima de %d noches",
+	"Arrivals between %s and %s are only possible Monday to Friday":               "Las llegadas entre el %s y el %s solo son posibles de lunes a viernes",
+	"Arrivals between %s and %s require a minimum stay of %d nights":              "Las llegadas entre el %s y el %s requieren una estancia mínima de %d noches",
+	"Departures are not possible between %s and %s":                               "No es posible salir entre el %s y el %s",
+	"No availability for %d nights in %s":                                         "Sin disponibilidad para %d noches en %s",
+	"No availability, join the waitlist and we will email you if a room frees up": "Sin disponibilidad, apúntese a la lista de espera y le escribiremos si se libera una habitación",
+	"No room can sleep a party of %d":                                             "Ninguna habitación admite un grupo de %d personas",
+	"could not add you to the waitlist":                                           "no se le pudo apuntar a la lista de espera",
+	"could not get room by id":                                                    "no se encontró la habitación",
+	"could not get room type by id":                                               "no se encontró el tipo de habitación",
+	"could not get rooms from DB":                                                 "no se pudieron cargar las habitaciones",
+	"could not get session from session":                                          "su sesión ha caducado",
+	"could not insert reservation to DB":                                          "no se pudo guardar la reserva",
+	"could not insert restriction to DB":                                          "no se pudo bloquear la habitación",
+	"could not parse end date":                                                    "fecha de salida no válida",
+	"could not parse end_date":                                                    "fecha de salida no válida",
+	"could not parse form":                                                        "formulario no válido",
+	"could not parse month":                                                       "mes no válido",
+	"could not parse room_id":                                                     "habitación no válida",
+	"could not parse room_type_id":                                                "tipo de habitación no válido",
+	"could not parse start date":                                                  "fecha de llegada no válida",
+	"could not parse start_date":                                                  "fecha de llegada no válida",
+	"could not search rooms from DB":                                              "no se pudieron buscar habitaciones",
+	"departure must be after arrival":                                             "la salida debe ser posterior a la llegada",
+	"incorrect login credentials":                                                 "credenciales incorrectas",
+	"log in first":                                                                "inicie sesión primero",
+	"logged in successfully":                                                      "sesión iniciada",
+	"missing url parameter":                                                       "falta un parámetro en la dirección",
+	"no reservation found":                                                        "no se encontró ninguna reserva",
+	"pick from 1 to %d nights":                                                    "elija de 1 a %d noches",
+	"room does not belong to this property":                                       "la habitación no pertenece a este alojamiento",
+	"sorry, this hold has expired, please search again":                           "lo sentimos, esta reserva provisional ha caducado, busque de nuevo",
+	"sorry, this room has just been booked for your dates":                        "lo sentimos, esta habitación acaba de reservarse para sus fechas",
+	"sorry, this room type has just been fully booked for your dates":             "lo sentimos, este tipo de habitación acaba de agotarse para sus fechas",
+	"that hold link is not valid":                                                 "ese enlace de reserva provisional no es válido",
+	"that month is already over":                                                  "ese mes ya ha terminado",
+	"unable to get reservation details from session":                              "no se encontró su reserva",
+	"you are on the waitlist, we will email you if a room frees up":               "está en la lista de espera, le escribiremos si se libera una habitación",
+
+	// errors
+	"Access denied":         "Acceso denegado",
+	"Back to the home page": "Volver al inicio",
+	"If you contact us about this, please quote reference %s.": "Si nos contacta por este motivo, indique la referencia %s.",
+	"Page not found":                      "Página no encontrada",
+	"Something went wrong":                "Algo ha salido mal",
+	"We could not complete your request.": "No hemos podido completar su solicitud.",
+	"We could not complete your request. Please try again in a moment.": "No hemos podido completar su solicitud. Inténtelo de nuevo en un momento.",
+	"We could not find the page you were looking for.":                  "No hemos encontrado la página que buscaba.",
+	"You are not allowed to see this page.":                             "No tiene permiso para ver esta página.",
+
+	// emails
+	"%s now has a room for you from %s to %s. We are holding it for you until %s.": "%s ya tiene una habitación para usted del %s al %s. Se la guardamos hasta el %s.",
+	"<a href=\"%s\">Book it now</a> before the hold runs out.":                     "<a href=\"%s\">Resérvela ahora</a> antes de que caduque la reserva provisional.",
+	"A room is free for your dates":                                                "Hay una habitación libre para sus fechas",
+	"Check-in is from %s and check-out is by %s, %s time.":                         "La entrada es desde las %s y la salida antes de las %s, hora de %s.",
+	"Hi %s,":                   "Hola, %s:",
+	"Reservation Confirmation": "Confirmación de reserva",
+	"This is to confirm your reservation from %s to %s.": "Le confirmamos su reserva del %s al %s.",
+}
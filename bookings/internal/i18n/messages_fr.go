@@ -0,0 +1,156 @@
+package i18n
+
+var fr = map[string]string{
+	// pages
+	"%d adults, %d children":         "%d adultes, %d enfants",
+	"%d nights":                      "%d nuits",
+	"%d nights in %s":                "%d nuits en %s",
+	"%d of %d available":             "%d sur %d disponibles",
+	"%s, by %s":                      "%s, avant %s",
+	"%s, from %s":                    "%s, à partir de %s",
+	"About":                          "À propos",
+	"Admin":                          "Administration",
+	"Adults":                         "Adultes",
+	"Adults:":                        "Adultes :",
+	"Any room":                       "N'importe quelle chambre",
+	"Arrival":                        "Arrivée",
+	"Arrival: %s, check-in from %s":  "Arrivée : %s, enregistrement à partir de %s",
+	"Arrival:":                       "Arrivée :",
+	"Book Now":                       "Réserver",
+	"Check Availability":             "Vérifier la disponibilité",
+	"Children":                       "Enfants",
+	"Children:":                      "Enfants :",
+	"Choose a room":                  "Choisissez une chambre",
+	"Choose your dates":              "Choisissez vos dates",
+	"Contact":                        "Contact",
+	"Dashboard":                      "Tableau de bord",
+	"Departure":                      "Départ",
+	"Departure: %s, check-out by %s": "Départ : %s, libération de la chambre avant %s",
+	"Departure:":                     "Départ :",
+	"Email:":                         "E-mail :",
+	"Exact":                          "Exactes",
+	"Find Dates":                     "Trouver des dates",
+	"First Name:":                    "Prénom :",
+	"Flexible by %d days":            "Flexibles de %d jours",
+	"Flexible by 1 day":              "Flexibles d'un jour",
+	"Flexible by a week":             "Flexibles d'une semaine",
+	"General's Quarters":             "Quartiers du Général",
+	"Guests:":                        "Voyageurs :",
+	"Home":                           "Accueil",
+	"Join Waitlist":                  "M'inscrire",
+	"Join the Waitlist":              "Liste d'attente",
+	"Last Name:":                     "Nom :",
+	"Login":                          "Connexion",
+	"Logout":                         "Déconnexion",
+	"Major's Suite":                  "Suite du Major",
+	"Make Reservation Now":           "Réserver maintenant",
+	"Make Reservation":               "Réserver",
+	"Month":                          "Mois",
+	"My Nice Page":                   "Ma belle page",
+	"My dates are":                   "Mes dates sont",
+	"Name:":                          "Nom :",
+	"Nights":                         "Nuits",
+	"No availability":                "Aucune disponibilité",
+	"Nothing is free for your exact dates, but these dates are:": "Rien n'est libre à vos dates exactes, mais ces dates le sont :",
+	"Or stay sometime in a month":                                "Ou séjournez au cours d'un mois",
+	"Other dates":                                                "Autres dates",
+	"Password:":                                                  "Mot de passe :",
+	"Phone:":                                                     "Téléphone :",
+	"Reservation Summary":                                        "Récapitulatif de la réservation",
+	"Room Name:":                                                 "Chambre :",
+	"Room is available!":                                         "La chambre est disponible !",
+	"Room:":                                                      "Chambre :",
+	"Rooms free for %s:":                                         "Chambres libres pour %s :",
+	"Rooms":                                                      "Chambres",
+	"Search Availability":                                        "Rechercher",
+	"Search for Availability":                                    "Rechercher une disponibilité",
+	"Sleeps up to %d":                                            "Jusqu'à %d personnes",
+	"Tell us when you would like to stay. If a room frees up for those dates we will hold it for you and email you a link to book it.": "Dites-nous quand vous aimeriez séjourner. Si une chambre se libère à ces dates, nous vous la réserverons et vous enverrons par e-mail un lien pour la réserver.",
+	"This will be the about":                   "Ceci sera la page à propos",
+	"This will be the contact page":            "Ceci sera la page de contact",
+	"Welcome to Fort Smythe Bed and Breakfast": "Bienvenue au Bed and Breakfast de Fort Smythe",
+	"Your home away form home, set on the majestic waters of the Atlantic Ocean, this will be a vacation to remember.": "Votre maison loin de chez vous, au bord des eaux majestueuses de l'océan Atlantique, pour des vacances inoubliables.",
+	"current": "actuelle",
+
+	// forms
+	"Invalid date":                                   "Date invalide",
+	"Invalid email address":                          "Adresse e-mail invalide",
+	"Pick a room of this property":                   "Choisissez une chambre de cet établissement",
+	"This field is required":                         "Ce champ est obligatoire",
+	"This field must be a number from %d to %d":      "Ce champ doit être un nombre de %d à %d",
+	"This field must be at least %d characters long": "Ce champ doit comporter au moins %d caractères",
+	"This room sleeps at most %d guests":             "Cette chambre accueille au plus %d personnes",
+	"This field must be at most %d characters long":  "Ce champ doit comporter au plus %d caractères",
+	"This field must be at least %v":                 "Ce champ doit valoir au moins %v",
+	"This field must be at most %v":                  "Ce champ doit valoir au plus %v",
+	"Pick at least %d options":                       "Choisissez au moins %d options",
+	"Pick at most %d options":                        "Choisissez au plus %d options",
+	"This field must be a whole number":              "Ce champ doit être un nombre entier",
+	"This field must be a number":                    "Ce champ doit être un nombre",
+	"Invalid value":                                  "Valeur invalide",
+	"Invalid phone number":                           "Numéro de téléphone invalide",
+	"This field is not in the expected format":       "Ce champ n'est pas au format attendu",
+	"Pick one of the listed options":                 "Choisissez l'une des options proposées",
+	"This date must be after %s":                     "Cette date doit être postérieure au %s",
+
+	// notifications
+	"Arrivals are not possible between %s and %s":                                 "Aucune arrivée n'est possible entre le %s et le %s",
+	"Arrivals between %s and %s allow a maximum stay of %d nights":                "Les arrivées entre le %s et le %s permettent un séjour de %d nuits au plus",
+	"Arrivals between %s and %s are only possible Monday to Friday":               "Les arrivées entre le %s et le %s ne sont possibles que du lundi au vendredi",
+	"Arrivals between %s and %s require a minimum stay of %d nights":              "Les arrivées entre le %s et le %s exigent un séjour d'au moins %d nuits",
+	"Departures are not possible between %s and %s":                               "Aucun départ n'est possible entre le %s et le %s",
+	"No availability for %d nights in %s":                                         "Aucune disponibilité pour %d nuits en %s",
+	"No availability, join the waitlist and we will email you if a room frees up": "Aucune disponibilité, inscrivez-vous sur la liste d'attente et nous vous écrirons si une chambre se libère",
+	"No room can sleep a party of %d":                                             "Aucune chambre ne peut accueillir un groupe de %d personnes",
+	"could not add you to the waitlist":                                           "impossible de vous inscrire sur la liste d'attente",
+	"could not get room by id":                                                    "chambre introuvable",
+	"could not get room type by id":                                               "type de chambre introuvable",
+	"could not get rooms from DB":                                                 "impossible de charger les chambres",
+	"could not get session from session":                                          "votre session a expiré",
+	"could not insert reservation to DB":                                          "impossible d'enregistrer la réservation",
+	"could not insert restriction to DB":                                          "impossible de bloquer la chambre",
+	"could not parse end date":                                                    "date de départ invalide",
+	"could not parse end_date":                                                    "date de départ invalide",
+	"could not parse form":                                                        "formulaire invalide",
+	"could not parse month":                                                       "mois invalide",
+	"could not parse room_id":                                                     "chambre invalide",
+	"could not parse room_type_id":                                                "type de chambre invalide",
+	"could not parse start date":                                                  "date d'arrivée invalide",
+	"could not parse start_date":                                                  "date d'arrivée invalide",
+	"could not search rooms from DB":                                              "impossible de rechercher les chambres",
+	"departure must be after arrival":                                             "le départ doit suivre l'arrivée",
+	"incorrect login credentials":                                                 "identifiants incorrects",
+	"log in first":                                                                "connectez-vous d'abord",
+	"logged in successfully":                                                      "connexion réussie",
+	"missing url parameter":                                                       "paramètre manquant dans l'adresse",
+	"no reservation found":                                                        "aucune réservation trouvée",
+	"pick from 1 to %d nights":                                                    "choisissez de 1 à %d nuits",
+	"room does not belong to this property":                                       "cette chambre n'appartient pas à cet établissement",
+	"sorry, this hold has expired, please search again":                           "désolé, cette option a expiré, veuillez relancer la recherche",
+	"sorry, this room has just been booked for your dates":                        "désolé, cette chambre vient d'être réservée pour vos dates",
+	"sorry, this room type has just been fully booked for your dates":             "désolé, ce type de chambre vient d'être complet pour vos dates",
+	"that hold link is not valid":                                                 "ce lien d'option n'est pas valide",
+	"that month is already over":                                                  "ce mois est déjà passé",
+	"unable to get reservation details from session":                              "votre réservation est introuvable",
+	"you are on the waitlist, we will email you if a room frees up":               "vous êtes sur la liste d'attente, nous vous écrirons si une chambre se libère",
+
+	// errors
+	"Access denied":         "Accès refusé",
+	"Back to the home page": "Retour à l'accueil",
+	"If you contact us about this, please quote reference %s.": "Si vous nous contactez à ce sujet, merci de citer la référence %s.",
+	"Page not found":                      "Page introuvable",
+	"Something went wrong":                "Une erreur s'est produite",
+	"We could not complete your request.": "Nous n'avons pas pu traiter votre demande.",
+	"We could not complete your request. Please try again in a moment.": "Nous n'avons pas pu traiter votre demande. Veuillez réessayer dans un instant.",
+	"We could not find the page you were looking for.":                  "Nous n'avons pas trouvé la page que vous cherchiez.",
+	"You are not allowed to see this page.":                             "Vous n'êtes pas autorisé à voir cette page.",
+
+	// emails
+	"%s now has a room for you from %s to %s. We are holding it for you until %s.": "%s a maintenant une chambre pour vous du %s au %s. Nous vous la gardons jusqu'au %s.",
+	"<a href=\"%s\">Book it now</a> before the hold runs out.":                     "<a href=\"%s\">Réservez-la maintenant</a> avant l'expiration de l'option.",
+	"A room is free for your dates":                                                "Une chambre est libre pour vos dates",
+	"Check-in is from %s and check-out is by %s, %s time.":                         "L'enregistrement se fait à partir de %s et la chambre doit être libérée avant %s, heure de %s.",
+	"Hi %s,":                   "Bonjour %s,",
+	"Reservation Confirmation": "Confirmation de réservation",
+	"This is to confirm your reservation from %s to %s.": "Nous confirmons votre réservation du %s au %s.",
+}
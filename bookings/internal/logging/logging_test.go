@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.With("component", "test").InfoContext(ctx, "hello", "rooms", 2)
+	logger.Debug("not shown")
+	logger.Info("no request")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records but got %d: %s", len(lines), buf.String())
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["msg"] != "hello" || record["request_id"] != "req-1" ||
+		record["component"] != "test" || record["rooms"] != float64(2) {
+		t.Errorf("unexpected record %v", record)
+	}
+	if strings.Contains(lines[1], "request_id") {
+		t.Errorf("a record without a request got an id: %s", lines[1])
+	}
+}
+
+func TestNewText(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "text", slog.LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.DebugContext(WithRequestID(context.Background(), "req-2"), "hello")
+	if !strings.Contains(buf.String(), "msg=hello request_id=req-2") {
+		t.Errorf("unexpected text record %q", buf.String())
+	}
+
+	if _, err := New(&buf, "xml", slog.LevelInfo); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
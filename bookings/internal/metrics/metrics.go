@@ -0,0 +1,269 @@
+// Package metrics counts what the app does and writes it in the Prometheus
+// text format.
+package metrics
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter is a value that only goes up.
+type Counter struct {
+	v uint64
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.v, 1)
+}
+
+// Value returns the current count.
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.v)
+}
+
+var (
+	// MailSent counts emails handed to the mail server.
+	MailSent Counter
+	// MailFailed counts emails that could not be sent.
+	MailFailed Counter
+	// ReservationsCreated counts reservations made by guests.
+	ReservationsCreated Counter
+	// CSPViolations counts the Content-Security-Policy violations browsers
+	// reported.
+	CSPViolations Counter
+)
+
+// buckets are the upper bounds, in seconds, of the request latency
+// histogram.
+var buckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	method string
+	route  string
+	status int
+}
+
+type routeKey struct {
+	method string
+	route  string
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+type cacheKey struct {
+	query string
+	hit   bool
+}
+
+var (
+	mu        sync.Mutex
+	requests  = map[requestKey]uint64{}
+	latencies = map[routeKey]*histogram{}
+	cache     = map[cacheKey]uint64{}
+)
+
+// ObserveRequest records a request to route, the pattern it matched such as
+// /admin/reservations/{src}/{id}/show, so ids do not make a series each.
+func ObserveRequest(method, route string, status int, d time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	requests[requestKey{method, route, status}]++
+
+	h, ok := latencies[routeKey{method, route}]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(buckets))}
+		latencies[routeKey{method, route}] = h
+	}
+	seconds := d.Seconds()
+	for i, le := range buckets {
+		if seconds <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// ObserveCache records a lookup of the query cache for query, the name of
+// the repository method, answered from the cache when hit is set.
+func ObserveCache(query string, hit bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	cache[cacheKey{query, hit}]++
+}
+
+// CacheStats returns the hits and misses of the query cache for query.
+func CacheStats(query string) (hits, misses uint64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return cache[cacheKey{query, true}], cache[cacheKey{query, false}]
+}
+
+// Reset forgets every request and cache lookup observed, for tests.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	requests = map[requestKey]uint64{}
+	latencies = map[routeKey]*histogram{}
+	cache = map[cacheKey]uint64{}
+}
+
+// Handler serves the metrics. db and mailQueue may be nil when there is no
+// database or mail queue to report on.
+func Handler(db *sql.DB, mailQueue func() int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w, db, mailQueue)
+	})
+}
+
+// Write writes every metric in the Prometheus text format.
+func Write(w io.Writer, db *sql.DB, mailQueue func() int) {
+	writeRequests(w)
+	writeCache(w)
+
+	if db != nil {
+		stats := db.Stats()
+		gauge(w, "bookings_db_max_open_connections", "Maximum number of open connections to the database.", float64(stats.MaxOpenConnections))
+		gauge(w, "bookings_db_open_connections", "Connections to the database, in use or idle.", float64(stats.OpenConnections))
+		gauge(w, "bookings_db_in_use_connections", "Connections to the database in use.", float64(stats.InUse))
+		gauge(w, "bookings_db_idle_connections", "Idle connections to the database.", float64(stats.Idle))
+		counter(w, "bookings_db_wait_count_total", "Times a query waited for a free connection.", float64(stats.WaitCount))
+		counter(w, "bookings_db_wait_duration_seconds_total", "Time spent waiting for a free connection.", stats.WaitDuration.Seconds())
+	}
+
+	counter(w, "bookings_mail_sent_total", "Emails handed to the mail server.", float64(MailSent.Value()))
+	counter(w, "bookings_mail_failed_total", "Emails that could not be sent.", float64(MailFailed.Value()))
+	if mailQueue != nil {
+		gauge(w, "bookings_mail_queue_length", "Emails waiting to be sent.", float64(mailQueue()))
+	}
+
+	counter(w, "bookings_reservations_created_total", "Reservations made by guests.", float64(ReservationsCreated.Value()))
+	counter(w, "bookings_csp_violations_total", "Content-Security-Policy violations reported by browsers.", float64(CSPViolations.Value()))
+}
+
+func writeRequests(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	keys := make([]requestKey, 0, len(requests))
+	for k := range requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	header(w, "bookings_http_requests_total", "HTTP requests by route, method and status.", "counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "bookings_http_requests_total{method=%s,route=%s,status=\"%d\"} %d\n",
+			label(k.method), label(k.route), k.status, requests[k])
+	}
+
+	routes := make([]routeKey, 0, len(latencies))
+	for k := range latencies {
+		routes = append(routes, k)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].route != routes[j].route {
+			return routes[i].route < routes[j].route
+		}
+		return routes[i].method < routes[j].method
+	})
+
+	name := "bookings_http_request_duration_seconds"
+	header(w, name, "HTTP request latencies by route and method.", "histogram")
+	for _, k := range routes {
+		h := latencies[k]
+		labels := fmt.Sprintf("method=%s,route=%s", label(k.method), label(k.route))
+
+		var cumulative uint64
+		for i, le := range buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, number(le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, number(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+func writeCache(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(cache) == 0 {
+		return
+	}
+
+	var queries []string
+	seen := map[string]bool{}
+	for k := range cache {
+		if !seen[k.query] {
+			seen[k.query] = true
+			queries = append(queries, k.query)
+		}
+	}
+	sort.Strings(queries)
+
+	for _, hit := range []bool{true, false} {
+		name, help := "bookings_query_cache_hits_total", "Queries answered from the query cache."
+		if !hit {
+			name, help = "bookings_query_cache_misses_total", "Queries the query cache sent to the database."
+		}
+		header(w, name, help, "counter")
+		for _, q := range queries {
+			fmt.Fprintf(w, "%s{query=%s} %d\n", name, label(q), cache[cacheKey{q, hit}])
+		}
+	}
+}
+
+func header(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func gauge(w io.Writer, name, help string, v float64) {
+	header(w, name, help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", name, number(v))
+}
+
+func counter(w io.Writer, name, help string, v float64) {
+	header(w, name, help, "counter")
+	fmt.Fprintf(w, "%s %s\n", name, number(v))
+}
+
+func number(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// label quotes a label value, escaping what the text format requires.
+func label(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `"`, `\"`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return `"` + v + `"`
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+)
+
+func TestWrite(t *testing.T) {
+	Reset()
+	ObserveRequest("GET", "/rooms/{id}", 200, 3*time.Millisecond)
+	ObserveRequest("GET", "/rooms/{id}", 200, 300*time.Millisecond)
+	ObserveRequest("GET", "/rooms/{id}", 404, time.Millisecond)
+	ObserveRequest("POST", `/say "hi"`, 500, 20*time.Second)
+	MailSent.Inc()
+	ReservationsCreated.Inc()
+	ObserveCache("AllRooms", true)
+	ObserveCache("AllRooms", true)
+	ObserveCache("AllRooms", false)
+
+	db, err := sql.Open("pgx", "host=localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	Write(&buf, db, func() int { return 7 })
+	out := buf.String()
+
+	for _, line := range []string{
+		"# TYPE bookings_http_requests_total counter",
+		`bookings_http_requests_total{method="GET",route="/rooms/{id}",status="200"} 2`,
+		`bookings_http_requests_total{method="GET",route="/rooms/{id}",status="404"} 1`,
+		`bookings_http_requests_total{method="POST",route="/say \"hi\"",status="500"} 1`,
+		"# TYPE bookings_http_request_duration_seconds histogram",
+		`bookings_http_request_duration_seconds_bucket{method="GET",route="/rooms/{id}",le="0.005"} 2`,
+		`bookings_http_request_duration_seconds_bucket{method="GET",route="/rooms/{id}",le="0.25"} 2`,
+		`bookings_http_request_duration_seconds_bucket{method="GET",route="/rooms/{id}",le="0.5"} 3`,
+		`bookings_http_request_duration_seconds_bucket{method="POST",route="/say \"hi\"",le="10"} 0`,
+		`bookings_http_request_duration_seconds_bucket{method="POST",route="/say \"hi\"",le="+Inf"} 1`,
+		`bookings_http_request_duration_seconds_count{method="GET",route="/rooms/{id}"} 3`,
+		"# TYPE bookings_query_cache_hits_total counter",
+		`bookings_query_cache_hits_total{query="AllRooms"} 2`,
+		`bookings_query_cache_misses_total{query="AllRooms"} 1`,
+		"bookings_db_open_connections 0",
+		"bookings_mail_sent_total 1",
+		"bookings_mail_failed_total 0",
+		"bookings_mail_queue_length 7",
+		"bookings_reservations_created_total 1",
+		"bookings_csp_violations_total 0",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected %q in\n%s", line, out)
+		}
+	}
+}
+
+func TestWriteWithoutSources(t *testing.T) {
+	Reset()
+
+	var buf bytes.Buffer
+	Write(&buf, nil, nil)
+
+	if strings.Contains(buf.String(), "bookings_db_") || strings.Contains(buf.String(), "queue_length") ||
+		strings.Contains(buf.String(), "query_cache") {
+		t.Errorf("reported a source that is not there:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,715 @@
+package migrate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The fizz files are read with a small parser that knows the calls the
+// migrations use: create_table, drop_table, add_column, change_column,
+// drop_column, add_foreign_key, drop_foreign_key, add_index, drop_index and
+// sql. Anything else is an error rather than being skipped.
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+// number is a numeric literal, kept as written.
+type number string
+
+// call is one fizz statement, or one t.Column line of a create_table block.
+type call struct {
+	name    string
+	args    []any
+	columns []call
+	line    int
+}
+
+// lex splits a fizz file into tokens. Identifiers may contain dots, so
+// t.Column is a single token.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '/' && strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.IndexByte("(){}[],:", c) >= 0:
+			tokens = append(tokens, token{tokenPunct, string(c), line})
+			i++
+		case c == '"' || c == '`':
+			end := i + 1
+			for end < len(src) && src[end] != c {
+				if c == '"' && src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			text, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad string: %w", line, err)
+			}
+			tokens = append(tokens, token{tokenString, text, line})
+			line += strings.Count(src[i:end+1], "\n")
+			i = end + 1
+		case c == '-' || c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(src) && (src[end] >= '0' && src[end] <= '9' || src[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{tokenNumber, src[i:end], line})
+			i = end
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := i + 1
+			for end < len(src) && (src[end] == '_' || src[end] == '.' ||
+				unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end]))) {
+				end++
+			}
+			tokens = append(tokens, token{tokenIdent, src[i:end], line})
+			i = end
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q", line, c)
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", line}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(punct string) error {
+	t := p.next()
+	if t.kind != tokenPunct || t.text != punct {
+		return fmt.Errorf("line %d: expected %q but found %s", t.line, punct, describe(t))
+	}
+	return nil
+}
+
+func describe(t token) string {
+	if t.kind == tokenEOF {
+		return "the end of the file"
+	}
+	return strconv.Quote(t.text)
+}
+
+// parseFizz reads the statements of a fizz file.
+func parseFizz(src string) ([]call, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+
+	var calls []call
+	for p.peek().kind != tokenEOF {
+		c, err := p.call()
+		if err != nil {
+			return nil, err
+		}
+
+		if t := p.peek(); t.kind == tokenPunct && t.text == "{" {
+			p.next()
+			for {
+				if t := p.peek(); t.kind == tokenPunct && t.text == "}" {
+					p.next()
+					break
+				}
+				column, err := p.call()
+				if err != nil {
+					return nil, err
+				}
+				c.columns = append(c.columns, column)
+			}
+		}
+
+		calls = append(calls, c)
+	}
+
+	return calls, nil
+}
+
+// call reads name(arg, ...).
+func (p *parser) call() (call, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		return call{}, fmt.Errorf("line %d: expected a statement but found %s", t.line, describe(t))
+	}
+	c := call{name: t.text, line: t.line}
+
+	if err := p.expect("("); err != nil {
+		return call{}, err
+	}
+	for {
+		if t := p.peek(); t.kind == tokenPunct && t.text == ")" {
+			p.next()
+			return c, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return call{}, err
+		}
+		c.args = append(c.args, v)
+		if t := p.peek(); t.kind == tokenPunct && t.text == "," {
+			p.next()
+		}
+	}
+}
+
+// value reads a string, number, boolean, list or map. Map keys may be
+// quoted or bare, and lists and maps may end with a comma.
+func (p *parser) value() (any, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenString:
+		return t.text, nil
+	case t.kind == tokenNumber:
+		return number(t.text), nil
+	case t.kind == tokenIdent && (t.text == "true" || t.text == "false"):
+		return t.text == "true", nil
+	case t.kind == tokenPunct && t.text == "[":
+		list := []any{}
+		for {
+			if t := p.peek(); t.kind == tokenPunct && t.text == "]" {
+				p.next()
+				return list, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			if t := p.peek(); t.kind == tokenPunct && t.text == "," {
+				p.next()
+			}
+		}
+	case t.kind == tokenPunct && t.text == "{":
+		m := map[string]any{}
+		for {
+			key := p.next()
+			if key.kind == tokenPunct && key.text == "}" {
+				return m, nil
+			}
+			if key.kind != tokenString && key.kind != tokenIdent {
+				return nil, fmt.Errorf("line %d: expected a key but found %s", key.line, describe(key))
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			m[key.text] = v
+			if t := p.peek(); t.kind == tokenPunct && t.text == "," {
+				p.next()
+			}
+		}
+	}
+	return nil, fmt.Errorf("line %d: expected a value but found %s", t.line, describe(t))
+}
+
+// translateFizz turns a fizz file into the statements it stands for in the
+// dialect, following the same conventions as soda: columns are not null unless
+// {null: true}, tables get created_at and updated_at columns, and indexes
+// and foreign keys are named after their table and columns.
+func (d Dialect) translateFizz(src string) ([]string, error) {
+	calls, err := parseFizz(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []string
+	for _, c := range calls {
+		stmt, err := d.translate(c)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", c.line, c.name, err)
+		}
+		statements = append(statements, stmt)
+	}
+	return statements, nil
+}
+
+func (d Dialect) translate(c call) (string, error) {
+	if len(c.columns) > 0 && c.name != "create_table" {
+		return "", fmt.Errorf("only create_table takes a block")
+	}
+
+	switch c.name {
+	case "create_table":
+		return d.createTable(c)
+
+	case "drop_table":
+		table, err := stringArg(c, 0)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("DROP TABLE %s;", d.quote(table)), nil
+
+	case "add_column":
+		table, column, def, err := d.columnArgs(c)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", d.quote(table), d.quote(column), def), nil
+
+	case "change_column":
+		return d.changeColumn(c)
+
+	case "drop_column":
+		table, err := stringArg(c, 0)
+		if err != nil {
+			return "", err
+		}
+		column, err := stringArg(c, 1)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", d.quote(table), d.quote(column)), nil
+
+	case "add_foreign_key":
+		return d.addForeignKey(c)
+
+	case "drop_foreign_key":
+		table, err := stringArg(c, 0)
+		if err != nil {
+			return "", err
+		}
+		name, err := stringArg(c, 1)
+		if err != nil {
+			return "", err
+		}
+		if d == MySQL {
+			return fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s;", d.quote(table), d.quote(name)), nil
+		}
+		return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", d.quote(table), d.quote(name)), nil
+
+	case "add_index":
+		return d.addIndex(c)
+
+	case "drop_index":
+		// Postgres index names are unique per schema, so the table is only
+		// needed by MySQL
+		table, err := stringArg(c, 0)
+		if err != nil {
+			return "", err
+		}
+		name, err := stringArg(c, 1)
+		if err != nil {
+			return "", err
+		}
+		if d == MySQL {
+			return fmt.Sprintf("DROP INDEX %s ON %s;", d.quote(name), d.quote(table)), nil
+		}
+		return fmt.Sprintf("DROP INDEX %s;", d.quote(name)), nil
+
+	case "sql":
+		return stringArg(c, 0)
+	}
+
+	return "", fmt.Errorf("not supported")
+}
+
+func (d Dialect) createTable(c call) (string, error) {
+	table, err := stringArg(c, 0)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	timestamps := true
+	hasColumn := map[string]bool{}
+	for _, col := range c.columns {
+		switch col.name {
+		case "t.Column":
+			name, def, err := d.columnDef(col, 0)
+			if err != nil {
+				return "", fmt.Errorf("line %d: %w", col.line, err)
+			}
+			hasColumn[name] = true
+			lines = append(lines, d.quote(name)+" "+def)
+		case "t.Timestamps":
+		case "t.DisableTimestamps":
+			timestamps = false
+		default:
+			return "", fmt.Errorf("line %d: %s is not supported", col.line, col.name)
+		}
+	}
+
+	if timestamps {
+		for _, name := range []string{"created_at", "updated_at"} {
+			if !hasColumn[name] {
+				colType, _ := d.columnType("timestamp", nil)
+				lines = append(lines, d.quote(name)+" "+colType+" NOT NULL")
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return "", fmt.Errorf("%s has no columns", table)
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (\n  %s\n);", d.quote(table), strings.Join(lines, ",\n  ")), nil
+}
+
+// columnArgs reads the (table, column, type, options) arguments of
+// add_column.
+func (d Dialect) columnArgs(c call) (table, column, def string, err error) {
+	table, err = stringArg(c, 0)
+	if err != nil {
+		return "", "", "", err
+	}
+	column, def, err = d.columnDef(c, 1)
+	return table, column, def, err
+}
+
+// columnDef reads the (name, type, options) arguments starting at the
+// argument first and returns the column name and definition.
+func (d Dialect) columnDef(c call, first int) (name, def string, err error) {
+	name, err = stringArg(c, first)
+	if err != nil {
+		return "", "", err
+	}
+	typ, err := stringArg(c, first+1)
+	if err != nil {
+		return "", "", err
+	}
+	opts, err := optionsArg(c, first+2)
+	if err != nil {
+		return "", "", err
+	}
+
+	if opts["primary"] == true {
+		if typ == "integer" || typ == "int" {
+			if d == MySQL {
+				return name, "integer AUTO_INCREMENT PRIMARY KEY", nil
+			}
+			return name, "SERIAL PRIMARY KEY", nil
+		}
+		colType, err := d.columnType(typ, opts)
+		if err != nil {
+			return "", "", err
+		}
+		return name, colType + " PRIMARY KEY", nil
+	}
+
+	colType, err := d.columnType(typ, opts)
+	if err != nil {
+		return "", "", err
+	}
+	def = colType
+	if opts["null"] != true {
+		def += " NOT NULL"
+	}
+	if d, ok := opts["default"]; ok {
+		value, err := literal(d)
+		if err != nil {
+			return "", "", fmt.Errorf("default of %s: %w", name, err)
+		}
+		def += " DEFAULT " + value
+	}
+	if raw, ok := opts["default_raw"].(string); ok {
+		def += " DEFAULT " + raw
+	}
+
+	return name, def, nil
+}
+
+func (d Dialect) changeColumn(c call) (string, error) {
+	table, err := stringArg(c, 0)
+	if err != nil {
+		return "", err
+	}
+	column, err := stringArg(c, 1)
+	if err != nil {
+		return "", err
+	}
+	typ, err := stringArg(c, 2)
+	if err != nil {
+		return "", err
+	}
+	opts, err := optionsArg(c, 3)
+	if err != nil {
+		return "", err
+	}
+	colType, err := d.columnType(typ, opts)
+	if err != nil {
+		return "", err
+	}
+
+	if d == MySQL {
+		// MySQL redefines the whole column
+		_, def, err := d.columnDef(c, 1)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s;", d.quote(table), d.quote(column), def), nil
+	}
+
+	col := "ALTER COLUMN " + d.quote(column)
+	changes := []string{col + " TYPE " + colType}
+	if opts["null"] == true {
+		changes = append(changes, col+" DROP NOT NULL")
+	} else {
+		changes = append(changes, col+" SET NOT NULL")
+	}
+	if d, ok := opts["default"]; ok {
+		value, err := literal(d)
+		if err != nil {
+			return "", fmt.Errorf("default of %s: %w", column, err)
+		}
+		changes = append(changes, col+" SET DEFAULT "+value)
+	} else {
+		changes = append(changes, col+" DROP DEFAULT")
+	}
+
+	return fmt.Sprintf("ALTER TABLE %s %s;", d.quote(table), strings.Join(changes, ", ")), nil
+}
+
+// addForeignKey reads add_foreign_key(table, column, {ref_table: [ref_column]},
+// {on_delete: ..., on_update: ..., name: ...}).
+func (d Dialect) addForeignKey(c call) (string, error) {
+	table, err := stringArg(c, 0)
+	if err != nil {
+		return "", err
+	}
+	column, err := stringArg(c, 1)
+	if err != nil {
+		return "", err
+	}
+	refs, err := optionsArg(c, 2)
+	if err != nil {
+		return "", err
+	}
+	if len(refs) != 1 {
+		return "", fmt.Errorf("argument 3 must name one table, like {\"rooms\": [\"id\"]}")
+	}
+	var refTable string
+	var refColumns []string
+	for t, cols := range refs {
+		refTable = t
+		refColumns, err = stringList(cols)
+		if err != nil || len(refColumns) == 0 {
+			return "", fmt.Errorf("argument 3 must list the columns of %s", t)
+		}
+	}
+	opts, err := optionsArg(c, 3)
+	if err != nil {
+		return "", err
+	}
+
+	name, _ := opts["name"].(string)
+	if name == "" {
+		name = fmt.Sprintf("%s_%s_%s_fk", table, refTable, refColumns[0])
+	}
+	quoted := make([]string, len(refColumns))
+	for i, col := range refColumns {
+		quoted[i] = d.quote(col)
+	}
+
+	stmt := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+		d.quote(table), d.quote(name), d.quote(column), d.quote(refTable), strings.Join(quoted, ", "))
+	for _, action := range []string{"on_delete", "on_update"} {
+		v, ok := opts[action]
+		if !ok {
+			continue
+		}
+		s, _ := v.(string)
+		s = strings.ToUpper(s)
+		switch s {
+		case "CASCADE", "SET NULL", "SET DEFAULT", "RESTRICT", "NO ACTION":
+		default:
+			return "", fmt.Errorf("%s must be cascade, set null, set default, restrict or no action", action)
+		}
+		stmt += " " + strings.ToUpper(strings.ReplaceAll(action, "_", " ")) + " " + s
+	}
+
+	return stmt + ";", nil
+}
+
+// addIndex reads add_index(table, column or [columns], {unique: ..., name: ...}).
+func (d Dialect) addIndex(c call) (string, error) {
+	table, err := stringArg(c, 0)
+	if err != nil {
+		return "", err
+	}
+	if len(c.args) < 2 {
+		return "", fmt.Errorf("argument 2 must name the columns")
+	}
+	columns, err := stringList(c.args[1])
+	if err != nil || len(columns) == 0 {
+		return "", fmt.Errorf("argument 2 must be a column or a list of columns")
+	}
+	opts, err := optionsArg(c, 2)
+	if err != nil {
+		return "", err
+	}
+
+	name, _ := opts["name"].(string)
+	if name == "" {
+		name = table + "_" + strings.Join(columns, "_") + "_idx"
+	}
+	unique := ""
+	if opts["unique"] == true {
+		unique = "UNIQUE "
+	}
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = d.quote(col)
+	}
+
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s);", unique, d.quote(name), d.quote(table),
+		strings.Join(quoted, ", ")), nil
+}
+
+// columnType maps a fizz type to its type in the dialect. Types fizz does
+// not name are passed through, so "numeric(10,2)" works as written.
+func (d Dialect) columnType(typ string, opts map[string]any) (string, error) {
+	switch strings.ToLower(typ) {
+	case "string":
+		size := "255"
+		if v, ok := opts["size"]; ok {
+			n, ok := v.(number)
+			if !ok {
+				return "", fmt.Errorf("size must be a number")
+			}
+			size = string(n)
+		}
+		return "VARCHAR(" + size + ")", nil
+	case "text":
+		return "TEXT", nil
+	case "integer", "int":
+		return "integer", nil
+	case "bigint":
+		return "bigint", nil
+	case "bool", "boolean":
+		return "boolean", nil
+	case "date":
+		return "date", nil
+	case "time", "timestamp", "datetime":
+		if d == MySQL {
+			// a MySQL timestamp ends in 2038 and updates itself
+			return "datetime", nil
+		}
+		return "timestamp", nil
+	case "uuid":
+		if d == MySQL {
+			return "CHAR(36)", nil
+		}
+		return "UUID", nil
+	case "json", "jsonb":
+		if d == MySQL {
+			return "json", nil
+		}
+		return "jsonb", nil
+	case "blob", "[]byte":
+		if d == MySQL {
+			return "blob", nil
+		}
+		return "bytea", nil
+	}
+	return typ, nil
+}
+
+// literal writes a default value as SQL.
+func literal(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	case number:
+		return string(v), nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	}
+	return "", fmt.Errorf("must be a string, number or boolean")
+}
+
+func (d Dialect) quote(name string) string {
+	if d == MySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func stringArg(c call, i int) (string, error) {
+	if i < len(c.args) {
+		if s, ok := c.args[i].(string); ok {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("argument %d must be a string", i+1)
+}
+
+// optionsArg returns the map argument i, or an empty map when it is left
+// out.
+func optionsArg(c call, i int) (map[string]any, error) {
+	if i >= len(c.args) {
+		return map[string]any{}, nil
+	}
+	m, ok := c.args[i].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("argument %d must be a map of options", i+1)
+	}
+	return m, nil
+}
+
+// stringList reads a string or a list of strings.
+func stringList(v any) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		list := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings")
+			}
+			list[i] = s
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("expected a string or a list of strings")
+}
@@ -0,0 +1,141 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranslateFizz(t *testing.T) {
+	var tests = []struct {
+		name     string
+		fizz     string
+		expected []string
+	}{
+		{"create table", `create_table("rooms") {
+  t.Column("id", "integer", {primary: true})
+  t.Column("room_name", "string", {default: ""})
+  t.Column("code", "string", {"size": 8, "null": true})
+}`, []string{`CREATE TABLE "rooms" (
+  "id" SERIAL PRIMARY KEY,
+  "room_name" VARCHAR(255) NOT NULL DEFAULT '',
+  "code" VARCHAR(8),
+  "created_at" timestamp NOT NULL,
+  "updated_at" timestamp NOT NULL
+);`}},
+		{"without timestamps", `create_table("tags") {
+  t.Column("name", "text", {})
+  t.DisableTimestamps()
+}`, []string{`CREATE TABLE "tags" (
+  "name" TEXT NOT NULL
+);`}},
+		{"add column", `add_column("properties", "check_in_time", "string", {"default": "15:00"})
+add_column("reservations", "deleted_at", "timestamp", {"null": true})`,
+			[]string{
+				`ALTER TABLE "properties" ADD COLUMN "check_in_time" VARCHAR(255) NOT NULL DEFAULT '15:00';`,
+				`ALTER TABLE "reservations" ADD COLUMN "deleted_at" timestamp;`,
+			}},
+		{"quoted default", `add_column("rooms", "note", "string", {"default": "general's"})`,
+			[]string{`ALTER TABLE "rooms" ADD COLUMN "note" VARCHAR(255) NOT NULL DEFAULT 'general''s';`}},
+		{"foreign key", `add_foreign_key("rooms", "room_type_id", {"room_types": ["id"]}, {
+    "on_delete": "set null", "on_update": "cascade",
+})`, []string{`ALTER TABLE "rooms" ADD CONSTRAINT "rooms_room_types_id_fk" FOREIGN KEY ("room_type_id") ` +
+			`REFERENCES "room_types" ("id") ON DELETE SET NULL ON UPDATE CASCADE;`}},
+		{"index", `add_index("room_rules", ["start_date", "end_date"], {})
+add_index("users", "email", {"unique": true, "name": "users_by_email"})`,
+			[]string{
+				`CREATE INDEX "room_rules_start_date_end_date_idx" ON "room_rules" ("start_date", "end_date");`,
+				`CREATE UNIQUE INDEX "users_by_email" ON "users" ("email");`,
+			}},
+		{"change column", `change_column("room_restrictions", "reservation_id", "integer", {null: true})`,
+			[]string{`ALTER TABLE "room_restrictions" ALTER COLUMN "reservation_id" TYPE integer, ` +
+				`ALTER COLUMN "reservation_id" DROP NOT NULL, ALTER COLUMN "reservation_id" DROP DEFAULT;`}},
+		{"drops", `// undo it all
+drop_index("users", "users_email_idx")
+drop_foreign_key("rooms", "rooms_properties_id_fk", {})
+drop_column("rooms", "property_id")
+drop_table("rooms")`, []string{
+			`DROP INDEX "users_email_idx";`,
+			`ALTER TABLE "rooms" DROP CONSTRAINT "rooms_properties_id_fk";`,
+			`ALTER TABLE "rooms" DROP COLUMN "property_id";`,
+			`DROP TABLE "rooms";`,
+		}},
+		{"sql", "sql(`update rooms set nightly_rate = 100`)", []string{"update rooms set nightly_rate = 100"}},
+		{"empty", "\n", nil},
+	}
+
+	for _, e := range tests {
+		stmts, err := Postgres.translateFizz(e.fizz)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+		if strings.Join(stmts, "\n") != strings.Join(e.expected, "\n") {
+			t.Errorf("%s: expected\n%s\nbut got\n%s", e.name, strings.Join(e.expected, "\n"), strings.Join(stmts, "\n"))
+		}
+	}
+}
+
+func TestTranslateFizzMySQL(t *testing.T) {
+	var tests = []struct {
+		name     string
+		fizz     string
+		expected []string
+	}{
+		{"create table", `create_table("rooms") {
+  t.Column("id", "integer", {primary: true})
+  t.Column("room_name", "string", {default: ""})
+  t.Column("deleted_at", "timestamp", {null: true})
+}`, []string{"CREATE TABLE `rooms` (\n" +
+			"  `id` integer AUTO_INCREMENT PRIMARY KEY,\n" +
+			"  `room_name` VARCHAR(255) NOT NULL DEFAULT '',\n" +
+			"  `deleted_at` datetime,\n" +
+			"  `created_at` datetime NOT NULL,\n" +
+			"  `updated_at` datetime NOT NULL\n" +
+			");"}},
+		{"change column", `change_column("room_restrictions", "reservation_id", "integer", {null: true})`,
+			[]string{"ALTER TABLE `room_restrictions` MODIFY COLUMN `reservation_id` integer;"}},
+		{"drops", `drop_index("users", "users_email_idx")
+drop_foreign_key("rooms", "rooms_properties_id_fk", {})`, []string{
+			"DROP INDEX `users_email_idx` ON `users`;",
+			"ALTER TABLE `rooms` DROP FOREIGN KEY `rooms_properties_id_fk`;",
+		}},
+	}
+
+	for _, e := range tests {
+		stmts, err := MySQL.translateFizz(e.fizz)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+		if strings.Join(stmts, "\n") != strings.Join(e.expected, "\n") {
+			t.Errorf("%s: expected\n%s\nbut got\n%s", e.name, strings.Join(e.expected, "\n"), strings.Join(stmts, "\n"))
+		}
+	}
+}
+
+func TestTranslateFizzErrors(t *testing.T) {
+	var tests = []struct {
+		name     string
+		fizz     string
+		expected string
+	}{
+		{"unsupported call", `rename_table("a", "b")`, `line 1: rename_table: not supported`},
+		{"unsupported column call", "create_table(\"a\") {\n  t.PrimaryKey(\"id\")\n}", "line 2: t.PrimaryKey is not supported"},
+		{"missing argument", `add_column("rooms")`, "argument 2 must be a string"},
+		{"bad action", `add_foreign_key("a", "b_id", {"b": ["id"]}, {"on_delete": "explode"})`, "on_delete must be"},
+		{"unterminated string", `drop_table("rooms)`, "unterminated string"},
+		{"missing paren", "drop_table(\"rooms\"\n", "line 2: expected a value but found the end of the file"},
+		{"junk", `drop_table("rooms"); drop_table("rooms")`, `unexpected ';'`},
+	}
+
+	for _, e := range tests {
+		_, err := Postgres.translateFizz(e.fizz)
+		if err == nil {
+			t.Errorf("%s: expected an error", e.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), e.expected) {
+			t.Errorf("%s: expected %q in %q", e.name, e.expected, err)
+		}
+	}
+}
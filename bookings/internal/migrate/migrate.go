@@ -0,0 +1,297 @@
+// Package migrate applies the fizz and sql migrations of the migrations
+// directory. Applied versions are kept in the schema_migration table, the
+// same one soda uses, so databases set up with soda carry on from where they
+// are.
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Dialect is the database the migrations run on. It picks the sql files
+// written for it, files that name no dialect applying to every database, and
+// what fizz files are translated to.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+)
+
+// ErrNothingApplied is returned when asked to revert a migration while none
+// have been applied.
+var ErrNothingApplied = errors.New("no migrations have been applied")
+
+// fileName matches names like 20231217091113_create_user_table.up.fizz and
+// 20231223164730_seed_rooms_table.postgres.up.sql.
+var fileName = regexp.MustCompile(`^(\d+)_([^.]+)(?:\.([a-z0-9]+))?\.(up|down)\.(fizz|sql)$`)
+
+// Migration is one version of the schema, with the files that move the
+// database to it and back.
+type Migration struct {
+	Version string
+	Name    string
+	up      string
+	down    string
+}
+
+// Status is a migration and whether it has been applied.
+type Status struct {
+	Migration
+	Applied bool
+}
+
+// Load finds the migrations of fsys for the dialect, in the order they are
+// applied.
+func Load(fsys fs.FS, dialect Dialect) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[string]*Migration{}
+	for _, entry := range entries {
+		parts := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || parts == nil {
+			continue
+		}
+		version, name, fileDialect, direction := parts[1], parts[2], parts[3], parts[4]
+		if fileDialect != "" && Dialect(fileDialect) != dialect {
+			continue
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		file := &m.up
+		if direction == "down" {
+			file = &m.down
+		}
+		if *file != "" {
+			return nil, fmt.Errorf("migration %s has two %s files: %s and %s",
+				version, direction, *file, entry.Name())
+		}
+		*file = entry.Name()
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %s_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// statements reads the statements of a migration file. An sql file is run
+// as it is; a fizz file is translated first.
+func (d Dialect) statements(fsys fs.FS, file string) ([]string, error) {
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if path.Ext(file) == ".sql" {
+		if strings.TrimSpace(string(data)) == "" {
+			return nil, nil
+		}
+		return []string{string(data)}, nil
+	}
+
+	stmts, err := d.translateFizz(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return stmts, nil
+}
+
+// Migrator applies and reverts the migrations of a directory on a
+// database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	fsys       fs.FS
+	migrations []Migration
+}
+
+// New returns a Migrator for the migrations of fsys, on a database of the
+// dialect.
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, fsys: fsys, migrations: migrations}, nil
+}
+
+// applied returns the versions recorded in schema_migration, creating the
+// table on a new database.
+func (m *Migrator) applied() (map[string]bool, error) {
+	create := `create table if not exists schema_migration (version varchar(14) not null);
+		create unique index if not exists schema_migration_version_idx on schema_migration (version)`
+	if m.dialect == MySQL {
+		// MySQL cannot create an index only if it does not exist
+		create = `create table if not exists schema_migration (version varchar(14) not null,
+			unique key schema_migration_version_idx (version))`
+	}
+	_, err := m.db.Exec(create)
+	if err != nil {
+		return nil, fmt.Errorf("creating schema_migration: %w", err)
+	}
+
+	rows, err := m.db.Query(`select version from schema_migration`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[string]bool{}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions[version] = true
+	}
+	return versions, rows.Err()
+}
+
+// Status lists every migration and whether it has been applied.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		statuses[i] = Status{Migration: mig, Applied: applied[mig.Version]}
+	}
+	return statuses, nil
+}
+
+// Pending lists the migrations not applied yet.
+func (m *Migrator) Pending() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if !applied[mig.Version] {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration in order, stopping at the first that
+// fails. It returns the migrations applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range pending {
+		if err := m.run(mig, true); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down reverts the latest migration applied.
+func (m *Migrator) Down() (Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return Migration{}, err
+	}
+
+	latest := ""
+	for version := range applied {
+		if version > latest {
+			latest = version
+		}
+	}
+	if latest == "" {
+		return Migration{}, ErrNothingApplied
+	}
+
+	for _, mig := range m.migrations {
+		if mig.Version == latest {
+			return mig, m.run(mig, false)
+		}
+	}
+	return Migration{}, fmt.Errorf("migration %s is applied but its files are missing", latest)
+}
+
+// Redo reverts the latest migration applied and applies it again.
+func (m *Migrator) Redo() (Migration, error) {
+	mig, err := m.Down()
+	if err != nil {
+		return mig, err
+	}
+	return mig, m.run(mig, true)
+}
+
+// run applies or reverts one migration in a transaction, so a migration
+// that fails halfway leaves the database as it was. MySQL commits schema
+// changes as it makes them though, so there a failed migration may need
+// fixing by hand.
+func (m *Migrator) run(mig Migration, up bool) error {
+	file := mig.up
+	if !up {
+		file = mig.down
+		if file == "" {
+			return fmt.Errorf("migration %s_%s has no down file", mig.Version, mig.Name)
+		}
+	}
+
+	stmts, err := m.dialect.statements(m.fsys, file)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	record := `insert into schema_migration (version) values ($1)`
+	if !up {
+		record = `delete from schema_migration where version = $1`
+	}
+	if m.dialect == MySQL {
+		record = strings.Replace(record, "$1", "?", 1)
+	}
+	if _, err := tx.Exec(record, mig.Version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
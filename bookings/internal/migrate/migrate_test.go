@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/chenemiken/goland/bookings"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20240102000000_seed_rooms.postgres.up.sql":   {Data: []byte("insert into rooms values (1)")},
+		"20240102000000_seed_rooms.postgres.down.sql": {Data: []byte("delete from rooms")},
+		"20240102000000_seed_rooms.mysql.up.sql":      {Data: []byte("insert into rooms values (1)")},
+		"20240101000000_create_rooms.up.fizz":         {Data: []byte(`create_table("rooms") {}`)},
+		"20240101000000_create_rooms.down.fizz":       {Data: []byte(`drop_table("rooms")`)},
+		"20240103000000_add_rate.up.fizz":             {Data: []byte(`add_column("rooms", "rate", "integer", {})`)},
+		"README.md":                                   {Data: []byte("not a migration")},
+	}
+
+	migrations, err := Load(fsys, Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Migration{
+		{"20240101000000", "create_rooms", "20240101000000_create_rooms.up.fizz", "20240101000000_create_rooms.down.fizz"},
+		{"20240102000000", "seed_rooms", "20240102000000_seed_rooms.postgres.up.sql", "20240102000000_seed_rooms.postgres.down.sql"},
+		{"20240103000000", "add_rate", "20240103000000_add_rate.up.fizz", ""},
+	}
+	if len(migrations) != len(expected) {
+		t.Fatalf("expected %d migrations but got %v", len(expected), migrations)
+	}
+	for i, e := range expected {
+		if migrations[i] != e {
+			t.Errorf("expected migration %d to be %v but got %v", i, e, migrations[i])
+		}
+	}
+
+	migrations, err = Load(fsys, MySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 3 || migrations[1].up != "20240102000000_seed_rooms.mysql.up.sql" || migrations[1].down != "" {
+		t.Errorf("expected the mysql seed but got %v", migrations)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	var tests = []struct {
+		name     string
+		fsys     fstest.MapFS
+		expected string
+	}{
+		{"down only", fstest.MapFS{"1_a.down.fizz": {}}, "migration 1_a has no up file"},
+		{"twice", fstest.MapFS{"1_a.up.fizz": {}, "1_a.up.sql": {}}, "migration 1 has two up files"},
+	}
+
+	for _, e := range tests {
+		_, err := Load(e.fsys, Postgres)
+		if err == nil || !strings.Contains(err.Error(), e.expected) {
+			t.Errorf("%s: expected %q but got %v", e.name, e.expected, err)
+		}
+	}
+}
+
+// TestShippedMigrations checks every migration the app ships with can be
+// read and translated, and that both dialects have the same migrations.
+func TestShippedMigrations(t *testing.T) {
+	var versions []string
+	for _, d := range []Dialect{Postgres, MySQL} {
+		migrations, err := Load(bookings.Migrations, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(migrations) == 0 {
+			t.Fatal("expected the embedded migrations")
+		}
+
+		var found []string
+		for _, m := range migrations {
+			found = append(found, m.Version)
+			for _, file := range []string{m.up, m.down} {
+				if file == "" {
+					t.Errorf("%s: migration %s_%s has no down file", d, m.Version, m.Name)
+					continue
+				}
+				if _, err := d.statements(bookings.Migrations, file); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+		if versions != nil && strings.Join(found, " ") != strings.Join(versions, " ") {
+			t.Errorf("%s: expected the migrations %v but got %v", d, versions, found)
+		}
+		versions = found
+	}
+}
@@ -1,6 +1,28 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/dates"
+)
+
+// Restriction IDs seeded in the restrictions table. Reservations, owner
+// blocks and waitlist holds make a room unavailable; the others are stay
+// rules stored in room_rules.
+const (
+	RestrictionReservation  = 1
+	RestrictionOwnerBlock   = 2
+	RuleMinStay             = 3
+	RuleMaxStay             = 4
+	RuleClosedToArrival     = 5
+	RuleClosedToDeparture   = 6
+	RuleWeekdayArrivalOnly  = 7
+	RestrictionWaitlistHold = 8
+)
+
+// AccessAdmin is the access level of administrators, who manage every
+// property. Other users manage the properties they are assigned to.
+const AccessAdmin = 3
 
 type User struct {
 	ID          int
@@ -9,15 +31,84 @@ type User struct {
 	Email       string
 	Password    string
 	AccessLevel int
+	DisabledAt  time.Time
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
 
+// Disabled reports whether the user has been disabled and can no longer
+// log in.
+func (u User) Disabled() bool {
+	return !u.DisabledAt.IsZero()
+}
+
+type Property struct {
+	ID           int
+	PropertyName string
+	Slug         string
+	HostName     string
+	Timezone     string
+	CheckInTime  string
+	CheckOutTime string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Location returns the time zone of the property, UTC when it has none or
+// an unknown one.
+func (p Property) Location() *time.Location {
+	if p.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// CheckIn returns the time of day guests can arrive from.
+func (p Property) CheckIn() string {
+	if p.CheckInTime == "" {
+		return "15:00"
+	}
+	return p.CheckInTime
+}
+
+// CheckOut returns the time of day guests have to leave by.
+func (p Property) CheckOut() string {
+	if p.CheckOutTime == "" {
+		return "11:00"
+	}
+	return p.CheckOutTime
+}
+
+// Today returns the current day at the property as a stay date.
+func (p Property) Today() time.Time {
+	return dates.Today(p.Location())
+}
+
+type RoomType struct {
+	ID             int
+	PropertyID     int
+	TypeName       string
+	Description    string
+	Units          int
+	AvailableRooms []Room
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
 type Room struct {
-	ID        int
-	RoomName  string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID               int
+	RoomName         string
+	PropertyID       int
+	RoomTypeID       int
+	MaxOccupancy     int
+	BedConfiguration string
+	NightlyRate      int
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 type Restriction struct {
@@ -28,18 +119,27 @@ type Restriction struct {
 }
 
 type Reservation struct {
-	ID        int
-	FirstName string
-	LastName  string
-	Email     string
-	Phone     string
-	StartDate time.Time
-	EndDate   time.Time
-	RoomID    int
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Room      Room
-	Processed int
+	ID         int
+	FirstName  string
+	LastName   string
+	Email      string
+	Phone      string
+	StartDate  time.Time
+	EndDate    time.Time
+	RoomID     int
+	RoomTypeID int
+	Adults     int
+	Children   int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Room       Room
+	Processed  int
+	DeletedAt  time.Time
+}
+
+// Guests returns the size of the party staying.
+func (r Reservation) Guests() int {
+	return r.Adults + r.Children
 }
 
 type RoomRestrictions struct {
@@ -54,6 +154,66 @@ type RoomRestrictions struct {
 	Room          Room
 	Restriction   Restriction
 	Reservation   Reservation
+	DeletedAt     time.Time
+}
+
+// RoomRule is a stay rule applying to a room between StartDate and EndDate,
+// both inclusive. Value holds the number of nights for min/max stay rules.
+type RoomRule struct {
+	ID            int
+	RoomID        int
+	RestrictionID int
+	StartDate     time.Time
+	EndDate       time.Time
+	Value         int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Restriction   Restriction
+}
+
+// WaitlistEntry is a guest waiting for a room between StartDate and
+// EndDate. A RoomID of 0 means any room of the property will do. Once nights
+// free up the entry gets a hold on HoldRoomID until HoldExpiresAt.
+type WaitlistEntry struct {
+	ID                int
+	PropertyID        int
+	RoomID            int
+	FirstName         string
+	LastName          string
+	Email             string
+	StartDate         time.Time
+	EndDate           time.Time
+	Adults            int
+	Children          int
+	Locale            string
+	HoldToken         string
+	HoldRoomID        int
+	HoldRestrictionID int
+	HoldExpiresAt     time.Time
+	BookedAt          time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// Guests returns the size of the waiting party.
+func (e WaitlistEntry) Guests() int {
+	return e.Adults + e.Children
+}
+
+// HoldActive reports whether the entry holds a room that it can still book.
+func (e WaitlistEntry) HoldActive(now time.Time) bool {
+	return e.HoldRestrictionID > 0 && e.BookedAt.IsZero() &&
+		now.Before(e.HoldExpiresAt)
+}
+
+// DayAvailability describes a single night of a room for date pickers.
+type DayAvailability struct {
+	Date              time.Time
+	Available         bool
+	Price             int
+	MinStay           int
+	ClosedToArrival   bool
+	ClosedToDeparture bool
 }
 
 type MailData struct {
@@ -61,4 +221,7 @@ type MailData struct {
 	From    string
 	Subject string
 	Content string
+	// RequestID is the request the mail was sent from, if any, so the
+	// message can be traced back to it.
+	RequestID string `json:",omitempty"`
 }
@@ -8,9 +8,14 @@ type TemplateData struct {
 	FloatMap        map[string]float32
 	Data            map[string]interface{}
 	CSRFtoken       string
+	CSPNonce        string
 	Flash           string
 	Warning         string
 	Error           string
 	Form            *forms.Form
 	IsAuthenticated int
+	Property        Property
+	BasePath        string
+	Locale          string
+	Path            string
 }
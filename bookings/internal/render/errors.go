@@ -0,0 +1,104 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+
+	"github.com/chenemiken/goland/bookings/helpers"
+	"github.com/chenemiken/goland/bookings/internal/models"
+)
+
+// templateLine finds the template and line in html/template errors, which
+// look like "template: home.page.html:12:3: executing ...".
+var templateLine = regexp.MustCompile(`template: ([^:\s]+):(\d+)`)
+
+// Error writes the branded page for status, quoting the request id so a
+// visitor can report it. In development a server error shows the error,
+// the template and line it came from and the stack instead. If the page
+// itself cannot be rendered a plain text response is written.
+func Error(w http.ResponseWriter, r *http.Request, status int, err error) {
+	requestID := helpers.RequestID(r)
+
+	if err != nil && !app.InProduction {
+		if devErr := devError(w, status, requestID, err); devErr == nil {
+			return
+		}
+	}
+
+	td := AddDefaultData(&models.TemplateData{
+		StringMap: map[string]string{
+			"title":      http.StatusText(status),
+			"request_id": requestID,
+		},
+		IntMap: map[string]int{"status": status},
+	}, r)
+
+	buf, renderErr := execute(r, "error.page.html", td)
+	if renderErr != nil {
+		app.Logger.ErrorContext(r.Context(), "could not render the error page", "err", renderErr)
+		http.Error(w, fmt.Sprintf("%s\nrequest %s", http.StatusText(status), requestID), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
+}
+
+// errorDetails is what the development error page shows.
+type errorDetails struct {
+	Status    int
+	Title     string
+	RequestID string
+	Error     string
+	Template  string
+	Line      string
+	Stack     string
+}
+
+func devError(w http.ResponseWriter, status int, requestID string, err error) error {
+	details := errorDetails{
+		Status:    status,
+		Title:     http.StatusText(status),
+		RequestID: requestID,
+		Error:     err.Error(),
+		Stack:     string(debug.Stack()),
+	}
+	if m := templateLine.FindStringSubmatch(err.Error()); m != nil {
+		details.Template, details.Line = m[1], m[2]
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	return devErrorPage.Execute(w, details)
+}
+
+// devErrorPage is kept out of the templates folder so it still works when
+// the templates themselves are broken.
+var devErrorPage = template.Must(template.New("dev-error").Parse(`<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <title>{{.Status}} {{.Title}}</title>
+    <style>
+        body { font-family: sans-serif; margin: 2em; }
+        pre { background: #f4f4f4; padding: 1em; overflow: auto; }
+        th { text-align: left; padding-right: 1em; }
+    </style>
+</head>
+<body>
+    <h1>{{.Status}} {{.Title}}</h1>
+    <pre>{{.Error}}</pre>
+    <table>
+        {{if .Template}}<tr><th>Template</th><td>{{.Template}}</td></tr>{{end}}
+        {{if .Line}}<tr><th>Line</th><td>{{.Line}}</td></tr>{{end}}
+        <tr><th>Request</th><td>{{.RequestID}}</td></tr>
+    </table>
+    <h2>Stack</h2>
+    <pre>{{.Stack}}</pre>
+</body>
+</html>
+`))
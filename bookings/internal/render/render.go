@@ -2,30 +2,50 @@ package render
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"html/template"
-	"log"
+	"io/fs"
 	"net/http"
-	"path/filepath"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/chenemiken/goland/bookings/helpers"
 	"github.com/chenemiken/goland/bookings/internal/config"
+	"github.com/chenemiken/goland/bookings/internal/i18n"
 	"github.com/chenemiken/goland/bookings/internal/models"
 	"github.com/justinas/nosurf"
 )
 
 var app *config.AppConfig
 var pathToTemplates = "./templates"
+
+// cacheMu guards app.TemplateCache and brokenPages, which WatchTemplates
+// updates while pages are being served.
+var cacheMu sync.RWMutex
+
+// brokenPages holds the parse errors of pages that failed to reload.
+var brokenPages = map[string]error{}
 var functions = template.FuncMap{
-	"humanDate":  HumanDate,
-	"formatDate": FormatDate,
-	"iterate":    Iterate,
-	"add":        Add,
+	"humanDate":    HumanDate,
+	"formatDate":   FormatDate,
+	"iterate":      Iterate,
+	"add":          Add,
+	"t":            i18n.T,
+	"localDate":    i18n.FormatDate,
+	"localMonth":   i18n.FormatMonth,
+	"number":       i18n.FormatNumber,
+	"languages":    Languages,
+	"languageName": LanguageName,
+	"asset":        Asset,
 }
 
+// NewRenderer sets the config for the package and registers Error as the
+// page helpers.ClientError and helpers.ServerError write.
 func NewRenderer(a *config.AppConfig) {
 	app = a
+	helpers.SetErrorPage(Error)
 }
 
 func HumanDate(t time.Time) string {
@@ -36,6 +56,24 @@ func FormatDate(t time.Time, f string) string {
 	return t.Format(f)
 }
 
+// Languages lists the locales visitors can switch between.
+func Languages() []string {
+	return i18n.Supported
+}
+
+// LanguageName returns the name of a locale in its own language.
+func LanguageName(locale string) string {
+	return i18n.Names[locale]
+}
+
+// Asset returns the fingerprinted URL of a file under static/.
+func Asset(name string) string {
+	if app.Assets == nil {
+		return "/static/" + strings.TrimPrefix(name, "/")
+	}
+	return app.Assets.Path(name)
+}
+
 func Iterate(count int) []int {
 	var items []int
 	for i := 0; i <= count; i++ {
@@ -53,80 +91,107 @@ func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateDa
 	td.Error = app.Session.PopString(r.Context(), "error")
 	td.Warning = app.Session.PopString(r.Context(), "warning")
 	td.CSRFtoken = nosurf.Token(r)
+	td.CSPNonce = helpers.CSPNonce(r)
 	if app.Session.Exists(r.Context(), "user_id") {
 		td.IsAuthenticated = 1
 	}
+	td.Property = helpers.CurrentProperty(r)
+	td.BasePath = helpers.BasePath(r)
+	td.Locale = helpers.CurrentLocale(r)
+	td.Path = r.URL.Path
 	return td
 }
 
+// Template renders tmpl to w. Nothing is written when the template is
+// missing or fails to execute; the error is returned for the caller to show
+// the error page.
 func Template(w http.ResponseWriter, r *http.Request, tmpl string,
 	td *models.TemplateData) error {
 
-	var tc map[string]*template.Template
-
-	if app.UseCache {
-		tc = app.TemplateCache
-	} else {
-		var err error
-		tc, err = CreateTemplateCache()
-		if err != nil {
-			log.Fatal(err)
-			return err
-		}
-	}
-
-	t, ok := tc[tmpl]
-	if !ok {
-		log.Println("could not get template from the template cache")
-		return errors.New("could not get template from cache")
-	}
-
-	buf := new(bytes.Buffer)
-
-	td = AddDefaultData(td, r)
-	err := t.Execute(buf, td)
+	buf, err := execute(r, tmpl, AddDefaultData(td, r))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	_, err = buf.WriteTo(w)
 	if err != nil {
-		fmt.Println("Error writing template to browser", err)
-		return err
+		return fmt.Errorf("writing %s to browser: %w", tmpl, err)
 	}
 
 	return nil
 }
 
+// execute runs tmpl from the template cache.
+func execute(r *http.Request, tmpl string, td *models.TemplateData) (*bytes.Buffer, error) {
+	cacheMu.RLock()
+	t, ok := app.TemplateCache[tmpl]
+	parseErr := brokenPages[tmpl]
+	cacheMu.RUnlock()
+
+	if parseErr != nil {
+		return nil, fmt.Errorf("parsing %s: %w", tmpl, parseErr)
+	}
+	if !ok {
+		return nil, fmt.Errorf("could not get template %s from cache", tmpl)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, td); err != nil {
+		return nil, fmt.Errorf("rendering %s: %w", tmpl, err)
+	}
+	return buf, nil
+}
+
+// CreateTemplateCache parses every page of app.Templates together with the
+// layouts.
 func CreateTemplateCache() (map[string]*template.Template, error) {
 	myCache := map[string]*template.Template{}
 
-	pages, err := filepath.Glob(fmt.Sprintf("%s/*.page.html", pathToTemplates))
+	pages, err := fs.Glob(templateFS(), "*.page.html")
 	if err != nil {
 		return myCache, err
 	}
 
 	for _, page := range pages {
-		name := filepath.Base(page)
-		ts, err := template.New(name).Funcs(functions).ParseFiles(page)
+		ts, err := parsePage(page)
 		if err != nil {
 			return myCache, err
 		}
 
-		matches, err := filepath.Glob(fmt.Sprintf("%s/*.layout.html", pathToTemplates))
+		myCache[page] = ts
+	}
+
+	return myCache, nil
+}
+
+func parsePage(page string) (*template.Template, error) {
+	fsys := templateFS()
+
+	ts, err := template.New(page).Funcs(functions).ParseFS(fsys, page)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := fs.Glob(fsys, "*.layout.html")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(matches) > 0 {
+		ts, err = ts.ParseFS(fsys, "*.layout.html")
 		if err != nil {
-			return myCache, err
+			return nil, err
 		}
+	}
 
-		if len(matches) > 0 {
-			ts, err = ts.ParseGlob(fmt.Sprintf("%s/*.layout.html", pathToTemplates))
-			if err != nil {
-				return myCache, err
-			}
-		}
+	return ts, nil
+}
 
-		myCache[name] = ts
+// templateFS returns the templates the app was configured with, or the
+// templates folder when there are none.
+func templateFS() fs.FS {
+	if app.Templates != nil {
+		return app.Templates
 	}
-
-	return myCache, nil
+	return os.DirFS(pathToTemplates)
 }
@@ -1,9 +1,15 @@
 package render
 
 import (
+	"errors"
+	"html/template"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/chenemiken/goland/bookings/helpers"
+	"github.com/chenemiken/goland/bookings/internal/logging"
 	"github.com/chenemiken/goland/bookings/internal/models"
 )
 
@@ -15,12 +21,16 @@ func TestAddDefaultData(t *testing.T) {
 		t.Error(err)
 	}
 	session.Put(r.Context(), "flash", "123")
+	r = helpers.WithCSPNonce(r, "n0nce")
 
 	result := AddDefaultData(&td, r)
 
 	if result.Flash != "123" {
 		t.Errorf("flash value not 123 but %s", result.Flash)
 	}
+	if result.CSPNonce != "n0nce" {
+		t.Errorf("expected the nonce of the request but got %q", result.CSPNonce)
+	}
 }
 
 func TestRenderTemplate(t *testing.T) {
@@ -73,3 +83,95 @@ func getSession() (*http.Request, error) {
 	r = r.WithContext(ctx)
 	return r, nil
 }
+
+func TestTemplateExecutionError(t *testing.T) {
+	pathToTemplates = "./../../templates"
+	tc, err := CreateTemplateCache()
+	if err != nil {
+		t.Fatal("failed to create template cache")
+	}
+	tc["broken.page.html"] = template.Must(template.New("broken.page.html").
+		Parse("line one\n{{.Nope}}"))
+	app.TemplateCache = tc
+	app.UseCache = true
+	defer func() { app.UseCache = false }()
+
+	r, err := getSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	err = Template(rr, r, "broken.page.html", &models.TemplateData{})
+	if err == nil {
+		t.Fatal("expected an error from a failing template")
+	}
+	if rr.Body.Len() != 0 {
+		t.Error("a failing template wrote a partial page")
+	}
+}
+
+func TestError(t *testing.T) {
+	pathToTemplates = "./../../templates"
+	tc, err := CreateTemplateCache()
+	if err != nil {
+		t.Fatal("failed to create template cache")
+	}
+	app.TemplateCache = tc
+	app.UseCache = true
+	defer func() { app.UseCache = false }()
+
+	var tests = []struct {
+		name         string
+		production   bool
+		status       int
+		err          error
+		expectedText []string
+	}{
+		{"not found", true, http.StatusNotFound, nil,
+			[]string{"Page not found", "req-1"}},
+		{"forbidden", false, http.StatusForbidden, nil,
+			[]string{"Access denied", "req-1"}},
+		{"server error", true, http.StatusInternalServerError,
+			errors.New("database is down"), []string{"Something went wrong", "req-1"}},
+		{"dev server error", false, http.StatusInternalServerError,
+			errors.New(`rendering home.page.html: template: home.page.html:12:3: executing "content"`),
+			[]string{"home.page.html", "<td>12</td>", "req-1", "runtime/debug.Stack"}},
+	}
+
+	for _, e := range tests {
+		app.InProduction = e.production
+
+		r, err := getSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = r.WithContext(logging.WithRequestID(r.Context(), "req-1"))
+
+		rr := httptest.NewRecorder()
+		Error(rr, r, e.status, e.err)
+
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.status, rr.Code)
+		}
+		for _, text := range e.expectedText {
+			if !strings.Contains(rr.Body.String(), text) {
+				t.Errorf("%s: expected %q on the page", e.name, text)
+			}
+		}
+		if e.production && strings.Contains(rr.Body.String(), "database is down") {
+			t.Errorf("%s: the error was shown in production", e.name)
+		}
+	}
+	app.InProduction = false
+
+	// without the error page a plain response still carries the request id
+	delete(tc, "error.page.html")
+	r, _ := getSession()
+	r = r.WithContext(logging.WithRequestID(r.Context(), "req-2"))
+	rr := httptest.NewRecorder()
+	Error(rr, r, http.StatusNotFound, nil)
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "req-2") {
+		t.Errorf("expected a plain 404 quoting the request but got %d %q", rr.Code, rr.Body)
+	}
+}
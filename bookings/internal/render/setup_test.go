@@ -1,7 +1,7 @@
 package render
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"testing"
@@ -18,11 +18,7 @@ func TestMain(m *testing.M) {
 
 	testApp.InProduction = false
 
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	testApp.InfoLog = infoLog
-
-	errorLog := log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	testApp.ErrorLog = errorLog
+	testApp.Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
 
 	session = scs.New()
 	session.Lifetime = 24 * time.Hour
@@ -0,0 +1,114 @@
+package render
+
+import (
+	"html/template"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// WatchTemplates checks app.Templates for changes every interval and
+// reparses only what changed: the edited pages, or every page when a layout
+// was edited. It is meant for development, where app.Templates is read from
+// disk and edits should show up without restarting or reparsing each
+// request. A page that no longer parses serves its error until it is fixed.
+func WatchTemplates(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		seen := modTimes()
+		for range ticker.C {
+			current := modTimes()
+			if changed := changedFiles(seen, current); len(changed) > 0 {
+				reloadTemplates(changed)
+			}
+			seen = current
+		}
+	}()
+}
+
+// modTimes returns the modification time of every template file.
+func modTimes() map[string]time.Time {
+	fsys := templateFS()
+	times := map[string]time.Time{}
+
+	names, err := fs.Glob(fsys, "*.html")
+	if err != nil {
+		app.Logger.Error("could not list templates", "err", err)
+		return times
+	}
+	for _, name := range names {
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			continue
+		}
+		times[name] = info.ModTime()
+	}
+	return times
+}
+
+// changedFiles lists the files added, edited or removed between two calls to
+// modTimes.
+func changedFiles(before, after map[string]time.Time) []string {
+	var changed []string
+	for name, t := range after {
+		if old, ok := before[name]; !ok || !old.Equal(t) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// reloadTemplates reparses the pages affected by the changed files.
+func reloadTemplates(changed []string) {
+	current := modTimes()
+
+	pages := map[string]bool{}
+	for _, name := range changed {
+		if strings.HasSuffix(name, ".layout.html") {
+			// every page includes the layouts
+			for name := range current {
+				if strings.HasSuffix(name, ".page.html") {
+					pages[name] = true
+				}
+			}
+			continue
+		}
+		if strings.HasSuffix(name, ".page.html") {
+			pages[name] = true
+		}
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	tc := make(map[string]*template.Template, len(app.TemplateCache))
+	for name, t := range app.TemplateCache {
+		tc[name] = t
+	}
+
+	for page := range pages {
+		delete(brokenPages, page)
+		if _, ok := current[page]; !ok {
+			delete(tc, page)
+			continue
+		}
+
+		ts, err := parsePage(page)
+		if err != nil {
+			app.Logger.Error("could not reload template", "page", page, "err", err)
+			brokenPages[page] = err
+			continue
+		}
+		tc[page] = ts
+		app.Logger.Info("reloaded template", "page", page)
+	}
+
+	app.TemplateCache = tc
+}
@@ -0,0 +1,93 @@
+package render
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/models"
+)
+
+func TestReloadTemplates(t *testing.T) {
+	start := time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"base.layout.html": {Data: []byte(`{{define "base"}}<main>{{block "content" .}}{{end}}</main>{{end}}`), ModTime: start},
+		"home.page.html":   {Data: []byte(`{{template "base" .}}{{define "content"}}home{{end}}`), ModTime: start},
+		"about.page.html":  {Data: []byte(`{{template "base" .}}{{define "content"}}about{{end}}`), ModTime: start},
+	}
+	app.Templates = fsys
+	defer func() { app.Templates = nil }()
+
+	tc, err := CreateTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.TemplateCache = tc
+	about := tc["about.page.html"]
+
+	var tests = []struct {
+		name     string
+		edit     func()
+		page     string
+		expected string
+		broken   bool
+	}{
+		{"page edited", func() {
+			fsys["home.page.html"] = &fstest.MapFile{
+				Data:    []byte(`{{template "base" .}}{{define "content"}}welcome{{end}}`),
+				ModTime: start.Add(time.Second)}
+		}, "home.page.html", "<main>welcome</main>", false},
+		{"layout edited", func() {
+			fsys["base.layout.html"] = &fstest.MapFile{
+				Data:    []byte(`{{define "base"}}<div>{{block "content" .}}{{end}}</div>{{end}}`),
+				ModTime: start.Add(2 * time.Second)}
+		}, "about.page.html", "<div>about</div>", false},
+		{"page broken", func() {
+			fsys["home.page.html"] = &fstest.MapFile{
+				Data:    []byte(`{{template "base" .}}{{define "content"}}{{if}}{{end}}`),
+				ModTime: start.Add(3 * time.Second)}
+		}, "home.page.html", "", true},
+		{"page fixed", func() {
+			fsys["home.page.html"] = &fstest.MapFile{
+				Data:    []byte(`{{template "base" .}}{{define "content"}}fixed{{end}}`),
+				ModTime: start.Add(4 * time.Second)}
+		}, "home.page.html", "<div>fixed</div>", false},
+	}
+
+	for _, e := range tests {
+		before := modTimes()
+		e.edit()
+		reloadTemplates(changedFiles(before, modTimes()))
+
+		r, err := getSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := execute(r, e.page, &models.TemplateData{})
+		if e.broken {
+			if err == nil || !strings.Contains(err.Error(), e.page) {
+				t.Errorf("%s: expected the parse error of %s but got %v", e.name, e.page, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+		if got := buf.String(); got != e.expected {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, got)
+		}
+		if e.name == "page edited" && app.TemplateCache["about.page.html"] != about {
+			t.Errorf("%s: an unchanged page was reparsed", e.name)
+		}
+	}
+
+	// removing a page drops it from the cache
+	before := modTimes()
+	delete(fsys, "about.page.html")
+	reloadTemplates(changedFiles(before, modTimes()))
+	if _, ok := app.TemplateCache["about.page.html"]; ok {
+		t.Error("a removed page is still in the cache")
+	}
+}
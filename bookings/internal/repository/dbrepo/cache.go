@@ -0,0 +1,334 @@
+package dbrepo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/metrics"
+	"github.com/chenemiken/goland/bookings/internal/models"
+	"github.com/chenemiken/goland/bookings/internal/repository"
+)
+
+// cachedDBRepo answers the room queries and the availability shown on the
+// calendar and the search page from memory, for up to ttl. Everything else
+// goes to the repository it wraps.
+//
+// Each entry is tagged with the rooms and properties it was read from, and a
+// write through the repository drops the entries of the rooms it touches.
+// Writes made elsewhere, by another instance or bookingsctl, are only seen
+// once the entries expire. That is why the availability checked before a
+// room is booked, SearchAvailabilityByDatesByRoomId and
+// AvailableRoomsForType, is never cached.
+type cachedDBRepo struct {
+	repository.DatabaseRepo
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// gen counts the invalidations, so a query that started before one
+	// does not store what it read.
+	gen uint64
+	// swept is when the expired entries were last dropped.
+	swept time.Time
+	// max bounds the entries kept, as the keys of the availability
+	// queries carry dates anyone can pick.
+	max int
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+	tags    []string
+}
+
+// maxCacheEntries is how many query results the cache keeps at most.
+const maxCacheEntries = 10000
+
+// NewCachedRepo wraps db with a cache keeping query results for ttl.
+func NewCachedRepo(db repository.DatabaseRepo, ttl time.Duration) repository.DatabaseRepo {
+	return &cachedDBRepo{
+		DatabaseRepo: db,
+		ttl:          ttl,
+		now:          time.Now,
+		entries:      make(map[string]cacheEntry),
+		max:          maxCacheEntries,
+	}
+}
+
+// The tags entries are dropped by.
+const allAvailability = "availability"
+
+func roomTag(id int) string     { return fmt.Sprintf("room:%d", id) }
+func propertyTag(id int) string { return fmt.Sprintf("property:%d", id) }
+
+// cached returns the entry for key, or calls load and keeps what it returns
+// under key with the tags. Errors are not kept. query names the method for
+// the hit and miss counts.
+func cached[T any](c *cachedDBRepo, query, key string, tags []string,
+	load func() (T, error)) (T, error) {
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && c.now().Before(e.expires) {
+		c.mu.Unlock()
+		metrics.ObserveCache(query, true)
+		return e.value.(T), nil
+	}
+	if ok {
+		delete(c.entries, key)
+	}
+	gen := c.gen
+	c.mu.Unlock()
+	metrics.ObserveCache(query, false)
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	if c.gen == gen {
+		c.store(key, cacheEntry{value: value, expires: c.now().Add(c.ttl), tags: tags})
+	}
+	c.mu.Unlock()
+	return value, nil
+}
+
+// store keeps e under key. Once every ttl, or when the cache is full, the
+// expired entries are dropped first, and when it is still full an entry is
+// dropped at random to make room. c.mu must be held.
+func (c *cachedDBRepo) store(key string, e cacheEntry) {
+	now := c.now()
+	full := len(c.entries) >= c.max
+	if full || now.Sub(c.swept) >= c.ttl {
+		for k, old := range c.entries {
+			if !now.Before(old.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.swept = now
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.max {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = e
+}
+
+// invalidate drops every entry carrying one of the tags.
+func (c *cachedDBRepo) invalidate(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for key, e := range c.entries {
+		for _, tag := range e.tags {
+			if contains(tags, tag) {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
+
+func contains(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidateRooms drops what was read about the availability of the rooms,
+// including the search results of their properties, or all availability
+// when one of the rooms cannot be read. The rooms themselves are kept, as
+// only InsertRoom changes them.
+func (c *cachedDBRepo) invalidateRooms(roomIDs ...int) {
+	var tags []string
+	for _, id := range roomIDs {
+		room, err := c.GetRoomById(id)
+		if err != nil {
+			c.invalidate(allAvailability)
+			return
+		}
+		tags = append(tags, roomTag(id), propertyTag(room.PropertyID))
+	}
+	c.invalidate(tags...)
+}
+
+// reservationRoom returns the room of reservation id, or 0, which no room
+// has, when the reservation cannot be read.
+func (c *cachedDBRepo) reservationRoom(id int) int {
+	res, err := c.DatabaseRepo.GetReservationByID(id)
+	if err != nil {
+		return 0
+	}
+	return res.RoomID
+}
+
+// copyRoomTypes copies room types with their rooms, so callers cannot change
+// what the cache holds.
+func copyRoomTypes(roomTypes []models.RoomType) []models.RoomType {
+	if roomTypes == nil {
+		return nil
+	}
+	copied := make([]models.RoomType, len(roomTypes))
+	for i, rt := range roomTypes {
+		rt.AvailableRooms = append([]models.Room(nil), rt.AvailableRooms...)
+		copied[i] = rt
+	}
+	return copied
+}
+
+func (c *cachedDBRepo) GetRoomById(id int) (models.Room, error) {
+	return cached(c, "GetRoomById", fmt.Sprintf("room:%d", id), nil,
+		func() (models.Room, error) { return c.DatabaseRepo.GetRoomById(id) })
+}
+
+func (c *cachedDBRepo) GetRoomTypeByID(id int) (models.RoomType, error) {
+	rt, err := cached(c, "GetRoomTypeByID", fmt.Sprintf("room_type:%d", id), nil,
+		func() (models.RoomType, error) { return c.DatabaseRepo.GetRoomTypeByID(id) })
+	rt.AvailableRooms = append([]models.Room(nil), rt.AvailableRooms...)
+	return rt, err
+}
+
+func (c *cachedDBRepo) AllRooms(propertyID int) ([]models.Room, error) {
+	rooms, err := cached(c, "AllRooms", fmt.Sprintf("rooms:%d", propertyID),
+		[]string{propertyTag(propertyID)},
+		func() ([]models.Room, error) { return c.DatabaseRepo.AllRooms(propertyID) })
+	if rooms == nil {
+		return rooms, err
+	}
+	return append([]models.Room(nil), rooms...), err
+}
+
+func (c *cachedDBRepo) RoomCalendar(roomID int,
+	start, end time.Time) ([]models.DayAvailability, error) {
+
+	key := fmt.Sprintf("calendar:%d:%s:%s", roomID,
+		start.Format("2006-01-02"), end.Format("2006-01-02"))
+	days, err := cached(c, "RoomCalendar", key,
+		[]string{roomTag(roomID), allAvailability},
+		func() ([]models.DayAvailability, error) {
+			return c.DatabaseRepo.RoomCalendar(roomID, start, end)
+		})
+	if days == nil {
+		return days, err
+	}
+	return append([]models.DayAvailability(nil), days...), err
+}
+
+func (c *cachedDBRepo) SearchAvailabilityForAllRooms(propertyID int,
+	start, end time.Time) ([]models.RoomType, error) {
+
+	key := fmt.Sprintf("search:%d:%s:%s", propertyID,
+		start.Format("2006-01-02"), end.Format("2006-01-02"))
+	roomTypes, err := cached(c, "SearchAvailabilityForAllRooms", key,
+		[]string{propertyTag(propertyID), allAvailability},
+		func() ([]models.RoomType, error) {
+			return c.DatabaseRepo.SearchAvailabilityForAllRooms(propertyID, start, end)
+		})
+	return copyRoomTypes(roomTypes), err
+}
+
+func (c *cachedDBRepo) InsertRoom(r models.Room) (int, error) {
+	id, err := c.DatabaseRepo.InsertRoom(r)
+	c.invalidate(propertyTag(r.PropertyID))
+	return id, err
+}
+
+func (c *cachedDBRepo) InsertReservation(res models.Reservation) (int, error) {
+	id, err := c.DatabaseRepo.InsertReservation(res)
+	c.invalidateRooms(res.RoomID)
+	return id, err
+}
+
+func (c *cachedDBRepo) ReserveRoom(res models.Reservation, heldID int) (int, error) {
+	id, err := c.DatabaseRepo.ReserveRoom(res, heldID)
+	c.invalidateRooms(res.RoomID)
+	return id, err
+}
+
+func (c *cachedDBRepo) InsertRoomRestriction(rr models.RoomRestrictions) error {
+	err := c.DatabaseRepo.InsertRoomRestriction(rr)
+	c.invalidateRooms(rr.RoomID)
+	return err
+}
+
+func (c *cachedDBRepo) DeleteReservation(id int) error {
+	roomID := c.reservationRoom(id)
+	err := c.DatabaseRepo.DeleteReservation(id)
+	c.invalidateRooms(roomID)
+	return err
+}
+
+func (c *cachedDBRepo) RestoreReservation(id int) error {
+	err := c.DatabaseRepo.RestoreReservation(id)
+	c.invalidateRooms(c.reservationRoom(id))
+	return err
+}
+
+func (c *cachedDBRepo) ReassignReservation(id, roomID int) error {
+	oldRoomID := c.reservationRoom(id)
+	err := c.DatabaseRepo.ReassignReservation(id, roomID)
+	c.invalidateRooms(oldRoomID, roomID)
+	return err
+}
+
+func (c *cachedDBRepo) ShortenReservation(id int, start, end time.Time) error {
+	err := c.DatabaseRepo.ShortenReservation(id, start, end)
+	c.invalidateRooms(c.reservationRoom(id))
+	return err
+}
+
+func (c *cachedDBRepo) InsertBlockForRoom(roomID int, startDate time.Time) error {
+	err := c.DatabaseRepo.InsertBlockForRoom(roomID, startDate)
+	c.invalidateRooms(roomID)
+	return err
+}
+
+func (c *cachedDBRepo) DeleteBlockByID(id, roomID int) error {
+	err := c.DatabaseRepo.DeleteBlockByID(id, roomID)
+	c.invalidateRooms(roomID)
+	return err
+}
+
+func (c *cachedDBRepo) InsertRoomRule(rule models.RoomRule) (int, error) {
+	id, err := c.DatabaseRepo.InsertRoomRule(rule)
+	c.invalidateRooms(rule.RoomID)
+	return id, err
+}
+
+func (c *cachedDBRepo) DeleteRoomRule(id, roomID int) error {
+	err := c.DatabaseRepo.DeleteRoomRule(id, roomID)
+	c.invalidateRooms(roomID)
+	return err
+}
+
+func (c *cachedDBRepo) PlaceWaitlistHold(entryID, roomID int, token string,
+	expires time.Time) error {
+
+	err := c.DatabaseRepo.PlaceWaitlistHold(entryID, roomID, token, expires)
+	c.invalidateRooms(roomID)
+	return err
+}
+
+// A hold is released or completed by its waitlist entry, which cannot be
+// read by id, so the rooms it was for are not known.
+
+func (c *cachedDBRepo) ReleaseWaitlistHold(entryID int) error {
+	err := c.DatabaseRepo.ReleaseWaitlistHold(entryID)
+	c.invalidate(allAvailability)
+	return err
+}
+
+func (c *cachedDBRepo) CompleteWaitlistHold(entryID int) error {
+	err := c.DatabaseRepo.CompleteWaitlistHold(entryID)
+	c.invalidate(allAvailability)
+	return err
+}
@@ -0,0 +1,152 @@
+package dbrepo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/config"
+	"github.com/chenemiken/goland/bookings/internal/metrics"
+	"github.com/chenemiken/goland/bookings/internal/models"
+	"github.com/chenemiken/goland/bookings/internal/repository"
+)
+
+// TestCachedRepo checks the cache does not change what the repository it
+// wraps answers.
+func TestCachedRepo(t *testing.T) {
+	testContract(t, func(t *testing.T) repository.DatabaseRepo {
+		return NewCachedRepo(NewMemoryRepo(&config.AppConfig{}), time.Hour)
+	})
+}
+
+// countingRepo counts the calendar queries reaching the repository.
+type countingRepo struct {
+	repository.DatabaseRepo
+	calendars map[int]int
+	allRooms  int
+}
+
+func (c *countingRepo) RoomCalendar(roomID int,
+	start, end time.Time) ([]models.DayAvailability, error) {
+	c.calendars[roomID]++
+	return c.DatabaseRepo.RoomCalendar(roomID, start, end)
+}
+
+func (c *countingRepo) AllRooms(propertyID int) ([]models.Room, error) {
+	c.allRooms++
+	return c.DatabaseRepo.AllRooms(propertyID)
+}
+
+func TestCache(t *testing.T) {
+	metrics.Reset()
+	counting := &countingRepo{
+		DatabaseRepo: NewMemoryRepo(&config.AppConfig{}),
+		calendars:    map[int]int{},
+	}
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := NewCachedRepo(counting, time.Minute).(*cachedDBRepo)
+	db.now = func() time.Time { return now }
+
+	calendar := func(roomID int) []models.DayAvailability {
+		t.Helper()
+		days, err := db.RoomCalendar(roomID, night(0), night(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return days
+	}
+
+	calendar(1)
+	calendar(1)
+	calendar(2)
+	if counting.calendars[1] != 1 || counting.calendars[2] != 1 {
+		t.Errorf("expected one query a room but got %v", counting.calendars)
+	}
+	if hits, misses := metrics.CacheStats("RoomCalendar"); hits != 1 || misses != 2 {
+		t.Errorf("expected 1 hit and 2 misses but got %d and %d", hits, misses)
+	}
+
+	id := book(t, db, 1, 0, 1)
+	if days := calendar(1); days[0].Available {
+		t.Errorf("expected the booking to show on the calendar")
+	}
+	calendar(2)
+	if counting.calendars[1] != 2 || counting.calendars[2] != 1 {
+		t.Errorf("expected only the booked room to be queried again but got %v", counting.calendars)
+	}
+
+	if err := db.DeleteReservation(id); err != nil {
+		t.Fatal(err)
+	}
+	if days := calendar(1); !days[0].Available {
+		t.Errorf("expected the deleted booking to be gone from the calendar")
+	}
+
+	now = now.Add(time.Minute)
+	calendar(2)
+	if counting.calendars[2] != 2 {
+		t.Errorf("expected an expired entry to be queried again but got %v", counting.calendars)
+	}
+
+	rooms, err := db.AllRooms(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rooms[0].RoomName = "changed"
+	rooms, err = db.AllRooms(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rooms[0].RoomName == "changed" || counting.allRooms != 1 {
+		t.Errorf("expected the cached rooms, unchanged, but got %+v after %d queries", rooms, counting.allRooms)
+	}
+
+	if _, err := db.InsertRoom(models.Room{RoomName: "attic", PropertyID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	rooms, err = db.AllRooms(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 3 {
+		t.Errorf("expected the new room to be listed but got %+v", rooms)
+	}
+}
+
+// TestCacheBounded checks searches over ever new dates do not grow the
+// cache without end.
+func TestCacheBounded(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := NewCachedRepo(NewMemoryRepo(&config.AppConfig{}), time.Minute).(*cachedDBRepo)
+	db.now = func() time.Time { return now }
+	db.max = 5
+
+	search := func(from int) {
+		t.Helper()
+		if _, err := db.SearchAvailabilityForAllRooms(1, night(from), night(from+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		search(i)
+	}
+	if len(db.entries) != db.max {
+		t.Errorf("expected the cache held at %d entries but got %d", db.max, len(db.entries))
+	}
+
+	db.max = 100
+	now = now.Add(time.Minute)
+	search(100)
+	if len(db.entries) != 1 {
+		t.Errorf("expected the expired entries to be swept but got %d entries", len(db.entries))
+	}
+
+	for i := 0; i < 3; i++ {
+		search(i)
+	}
+	now = now.Add(30 * time.Second)
+	search(200)
+	if len(db.entries) != 5 {
+		t.Errorf("expected no sweep before the ttl has passed but got %d entries", len(db.entries))
+	}
+}
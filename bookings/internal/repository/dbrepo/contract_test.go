@@ -0,0 +1,708 @@
+package dbrepo
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/config"
+	"github.com/chenemiken/goland/bookings/internal/drivers"
+	"github.com/chenemiken/goland/bookings/internal/migrate"
+	"github.com/chenemiken/goland/bookings/internal/models"
+	"github.com/chenemiken/goland/bookings/internal/repository"
+)
+
+// These name scratch databases, by their dsn, to run the contract tests
+// against. The tests drop everything in them. The MySQL dsn needs
+// parseTime=true&multiStatements=true, as the app sets.
+const (
+	testPostgresEnv = "BOOKINGS_TEST_DATABASE"
+	testMySQLEnv    = "BOOKINGS_TEST_MYSQL"
+)
+
+func TestMemoryRepo(t *testing.T) {
+	testContract(t, func(t *testing.T) repository.DatabaseRepo {
+		return NewMemoryRepo(&config.AppConfig{})
+	})
+}
+
+func TestPostgresRepo(t *testing.T) {
+	testSQLRepo(t, "postgres", testPostgresEnv, resetPostgres)
+}
+
+func TestMySQLRepo(t *testing.T) {
+	testSQLRepo(t, "mysql", testMySQLEnv, resetMySQL)
+}
+
+func resetPostgres(db *sql.DB) error {
+	_, err := db.Exec(`drop schema public cascade; create schema public`)
+	return err
+}
+
+func resetMySQL(db *sql.DB) error {
+	rows, err := db.Query(`select table_name from information_schema.tables
+		where table_schema = database()`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return err
+		}
+		tables = append(tables, "`"+table+"`")
+	}
+	if err := rows.Err(); err != nil || len(tables) == 0 {
+		return err
+	}
+
+	// one call, so the checks are off on the connection dropping
+	_, err = db.Exec(`set foreign_key_checks = 0; drop table ` + strings.Join(tables, ", ") +
+		`; set foreign_key_checks = 1`)
+	return err
+}
+
+// testSQLRepo runs the contract tests on the database named by the
+// environment variable env, emptied with reset and migrated before each.
+func testSQLRepo(t *testing.T, driver, env string, reset func(db *sql.DB) error) {
+	db := testDB(t, driver, env)
+	testContract(t, func(t *testing.T) repository.DatabaseRepo {
+		migrateTestDB(t, db, reset)
+		return NewSQLRepo(driver, db.SQL, &config.AppConfig{})
+	})
+}
+
+// testDB connects to the database named by the environment variable env,
+// skipping the test when it is not set.
+func testDB(t *testing.T, driver, env string) *drivers.DB {
+	dsn := os.Getenv(env)
+	if dsn == "" {
+		t.Skipf("set %s to the dsn of a scratch database to test against %s", env, driver)
+	}
+
+	db, err := drivers.ConnectSQL(driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.SQL.Close() })
+	return db
+}
+
+// migrateTestDB empties the database with reset and applies every migration.
+func migrateTestDB(t *testing.T, db *drivers.DB, reset func(db *sql.DB) error) {
+	if err := reset(db.SQL); err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrate.New(db.SQL, migrate.Dialect(db.Driver), os.DirFS("../../../migrations"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// night returns a day far enough ahead that nothing else is booked on it.
+func night(n int) time.Time {
+	return time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, n)
+}
+
+// book reserves a room from night start to night end.
+func book(t *testing.T, db repository.DatabaseRepo, roomID, start, end int) int {
+	t.Helper()
+
+	id, err := db.InsertReservation(models.Reservation{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Email:     "ada@example.com",
+		Phone:     "555-0100",
+		StartDate: night(start),
+		EndDate:   night(end),
+		RoomID:    roomID,
+		Adults:    1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.InsertRoomRestriction(models.RoomRestrictions{
+		StartDate:     night(start),
+		EndDate:       night(end),
+		RoomID:        roomID,
+		ReservationID: id,
+		RestrictionID: models.RestrictionReservation,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// free tells whether a room is free from night start to night end.
+func free(t *testing.T, db repository.DatabaseRepo, roomID, start, end int) bool {
+	t.Helper()
+
+	ok, err := db.SearchAvailabilityByDatesByRoomId(night(start), night(end), roomID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok
+}
+
+// testContract runs the checks every DatabaseRepo must pass, each on a
+// repository fresh from newRepo holding only the migration seeds.
+func testContract(t *testing.T, newRepo func(t *testing.T) repository.DatabaseRepo) {
+	var tests = []struct {
+		name string
+		run  func(t *testing.T, db repository.DatabaseRepo)
+	}{
+		{"seeds", testSeeds},
+		{"not found", testNotFound},
+		{"availability", testAvailability},
+		{"reserve room", testReserveRoom},
+		{"foreign keys", testForeignKeys},
+		{"delete and restore", testDeleteAndRestore},
+		{"search", testSearch},
+		{"blocks", testBlocks},
+		{"rules", testRules},
+		{"rooms", testRooms},
+		{"users", testUsers},
+		{"waitlist", testWaitlist},
+	}
+
+	for _, e := range tests {
+		t.Run(e.name, func(t *testing.T) {
+			e.run(t, newRepo(t))
+		})
+	}
+}
+
+func testSeeds(t *testing.T, db repository.DatabaseRepo) {
+	property, err := db.GetPropertyBySlug("fort-smythe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if property.ID != 1 || property.Timezone != "UTC" || property.CheckInTime != "15:00" {
+		t.Errorf("unexpected property %+v", property)
+	}
+
+	rooms, err := db.AllRooms(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 2 || rooms[0].RoomName != "general's quarters" || rooms[1].RoomName != "major's suite" {
+		t.Errorf("unexpected rooms %+v", rooms)
+	}
+
+	restrictions, err := db.AllRestrictions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restrictions) != 8 || restrictions[7].ID != models.RestrictionWaitlistHold {
+		t.Errorf("unexpected restrictions %+v", restrictions)
+	}
+
+	rt, err := db.GetRoomTypeByID(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.TypeName != "Major's Suite" || rt.Units != 1 {
+		t.Errorf("unexpected room type %+v", rt)
+	}
+}
+
+func testNotFound(t *testing.T, db repository.DatabaseRepo) {
+	var tests = []struct {
+		name string
+		get  func() error
+	}{
+		{"room", func() error { _, err := db.GetRoomById(999); return err }},
+		{"room type", func() error { _, err := db.GetRoomTypeByID(999); return err }},
+		{"reservation", func() error { _, err := db.GetReservationByID(999); return err }},
+		{"user", func() error { _, err := db.GetUserByID(999); return err }},
+		{"user by email", func() error { _, err := db.GetUserByEmail("nobody@example.com"); return err }},
+		{"property", func() error { _, err := db.GetPropertyByID(999); return err }},
+		{"property by slug", func() error { _, err := db.GetPropertyBySlug("nowhere"); return err }},
+		{"property by host", func() error { _, err := db.GetPropertyByHost("nowhere.example.com"); return err }},
+		{"waitlist hold", func() error { _, err := db.GetWaitlistEntryByToken("nothing"); return err }},
+	}
+
+	for _, e := range tests {
+		if err := e.get(); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s: expected sql.ErrNoRows but got %v", e.name, err)
+		}
+	}
+}
+
+func testAvailability(t *testing.T, db repository.DatabaseRepo) {
+	book(t, db, 1, 10, 13)
+
+	var tests = []struct {
+		name       string
+		start, end int
+		expected   bool
+	}{
+		{"before", 5, 10, true},
+		{"after", 13, 15, true},
+		{"same nights", 10, 13, false},
+		{"arriving during", 12, 14, false},
+		{"leaving during", 8, 11, false},
+		{"around", 9, 14, false},
+	}
+
+	for _, e := range tests {
+		if got := free(t, db, 1, e.start, e.end); got != e.expected {
+			t.Errorf("%s: expected free to be %v but got %v", e.name, e.expected, got)
+		}
+	}
+	if !free(t, db, 2, 10, 13) {
+		t.Errorf("expected the other room to be free")
+	}
+
+	roomTypes, err := db.SearchAvailabilityForAllRooms(1, night(11), night(12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roomTypes) != 1 || roomTypes[0].ID != 2 || len(roomTypes[0].AvailableRooms) != 1 ||
+		roomTypes[0].AvailableRooms[0].ID != 2 || roomTypes[0].Units != 1 {
+		t.Errorf("expected only the major's suite but got %+v", roomTypes)
+	}
+
+	rooms, err := db.AvailableRoomsForType(1, night(11), night(12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 0 {
+		t.Errorf("expected no general's quarters but got %+v", rooms)
+	}
+
+	days, err := db.RoomCalendar(1, night(9), night(13))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []bool{true, false, false, false, true}
+	if len(days) != len(expected) {
+		t.Fatalf("expected %d days but got %+v", len(expected), days)
+	}
+	for i, d := range days {
+		if !d.Date.Equal(night(9+i)) || d.Available != expected[i] {
+			t.Errorf("day %d: expected %s available %v but got %+v", i, night(9+i), expected[i], d)
+		}
+	}
+
+	days, err = db.RoomCalendar(999, night(9), night(13))
+	if err != nil || len(days) != 0 {
+		t.Errorf("expected no days for a missing room but got %+v, %v", days, err)
+	}
+}
+
+func testReserveRoom(t *testing.T, db repository.DatabaseRepo) {
+	res := models.Reservation{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com",
+		StartDate: night(1), EndDate: night(3), RoomID: 1, Adults: 1}
+
+	id, err := db.ReserveRoom(res, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if free(t, db, 1, 2, 3) {
+		t.Errorf("expected the reserved room to be taken")
+	}
+	got, err := db.GetReservationByID(id)
+	if err != nil || got.RoomID != 1 || !got.StartDate.Equal(night(1)) {
+		t.Errorf("expected reservation %d but got %+v, %v", id, got, err)
+	}
+
+	res.StartDate, res.EndDate = night(2), night(4)
+	if _, err := db.ReserveRoom(res, 0); !errors.Is(err, repository.ErrRoomTaken) {
+		t.Errorf("expected ErrRoomTaken for overlapping nights but got %v", err)
+	}
+	res.StartDate, res.EndDate = night(3), night(4)
+	if _, err := db.ReserveRoom(res, 0); err != nil {
+		t.Errorf("expected the next nights to be free but got %v", err)
+	}
+
+	// a guest booking through a waitlist hold is not kept out by the hold
+	entryID, err := db.InsertWaitlistEntry(models.WaitlistEntry{PropertyID: 1, Email: "wait@example.com",
+		StartDate: night(10), EndDate: night(12), Adults: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PlaceWaitlistHold(entryID, 2, "token", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := db.GetWaitlistEntryByToken("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.RoomID, res.StartDate, res.EndDate = 2, night(10), night(12)
+	if _, err := db.ReserveRoom(res, 0); !errors.Is(err, repository.ErrRoomTaken) {
+		t.Errorf("expected ErrRoomTaken for a held room but got %v", err)
+	}
+	if _, err := db.ReserveRoom(res, entry.HoldRestrictionID); err != nil {
+		t.Errorf("expected the guest holding the room to book it but got %v", err)
+	}
+
+	res.RoomID = 999
+	if _, err := db.ReserveRoom(res, 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing room but got %v", err)
+	}
+
+	// of guests booking the same nights at once, only one gets them
+	res.RoomID, res.StartDate, res.EndDate = 1, night(20), night(22)
+	errs := make(chan error)
+	for i := 0; i < 5; i++ {
+		go func() {
+			_, err := db.ReserveRoom(res, 0)
+			errs <- err
+		}()
+	}
+	booked := 0
+	for i := 0; i < 5; i++ {
+		err := <-errs
+		switch {
+		case err == nil:
+			booked++
+		case !errors.Is(err, repository.ErrRoomTaken):
+			t.Errorf("expected ErrRoomTaken but got %v", err)
+		}
+	}
+	if booked != 1 {
+		t.Errorf("expected one guest to get the nights but %d did", booked)
+	}
+}
+
+func testForeignKeys(t *testing.T, db repository.DatabaseRepo) {
+	_, err := db.InsertReservation(models.Reservation{FirstName: "A", LastName: "B",
+		Email: "a@example.com", StartDate: night(1), EndDate: night(2), RoomID: 999})
+	if err == nil {
+		t.Errorf("expected a reservation of a missing room to fail")
+	}
+
+	err = db.InsertRoomRestriction(models.RoomRestrictions{StartDate: night(1), EndDate: night(2),
+		RoomID: 1, ReservationID: 999, RestrictionID: models.RestrictionReservation})
+	if err == nil {
+		t.Errorf("expected a restriction for a missing reservation to fail")
+	}
+
+	if err := db.InsertBlockForRoom(999, night(1)); err == nil {
+		t.Errorf("expected a block of a missing room to fail")
+	}
+
+	if _, err := db.InsertRoom(models.Room{RoomName: "Nowhere", PropertyID: 999}); err == nil {
+		t.Errorf("expected a room of a missing property to fail")
+	}
+}
+
+func testDeleteAndRestore(t *testing.T, db repository.DatabaseRepo) {
+	id := book(t, db, 1, 10, 13)
+
+	if err := db.DeleteReservation(id); err != nil {
+		t.Fatal(err)
+	}
+	if !free(t, db, 1, 10, 13) {
+		t.Errorf("expected the room to be free once the reservation is deleted")
+	}
+	reservations, err := db.AllReservations(1)
+	if err != nil || len(reservations) != 0 {
+		t.Errorf("expected no reservations but got %+v, %v", reservations, err)
+	}
+	deleted, err := db.AllDeletedReservations(1)
+	if err != nil || len(deleted) != 1 || deleted[0].ID != id {
+		t.Errorf("expected reservation %d deleted but got %+v, %v", id, deleted, err)
+	}
+
+	if err := db.RestoreReservation(id); err != nil {
+		t.Fatal(err)
+	}
+	if free(t, db, 1, 10, 13) {
+		t.Errorf("expected the room to be taken once the reservation is restored")
+	}
+
+	if err := db.DeleteReservation(id); err != nil {
+		t.Fatal(err)
+	}
+	purged, err := db.PurgeDeletedReservations(time.Now().Add(-time.Hour))
+	if err != nil || purged != 0 {
+		t.Errorf("expected nothing purged yet but got %d, %v", purged, err)
+	}
+	purged, err = db.PurgeDeletedReservations(time.Now().Add(time.Hour))
+	if err != nil || purged != 1 {
+		t.Errorf("expected one reservation purged but got %d, %v", purged, err)
+	}
+	if _, err := db.GetReservationByID(id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the purged reservation to be gone but got %v", err)
+	}
+
+	id = book(t, db, 1, 10, 13)
+	if err := db.DeleteReservation(id); err != nil {
+		t.Fatal(err)
+	}
+	book(t, db, 1, 12, 14)
+	if err := db.RestoreReservation(id); !errors.Is(err, repository.ErrRoomTaken) {
+		t.Errorf("expected a restore over a newer booking to fail but got %v", err)
+	}
+	deleted, err = db.AllDeletedReservations(1)
+	if err != nil || len(deleted) != 1 || deleted[0].ID != id {
+		t.Errorf("expected reservation %d still deleted but got %+v, %v", id, deleted, err)
+	}
+}
+
+func testSearch(t *testing.T, db repository.DatabaseRepo) {
+	early := book(t, db, 2, 20, 22)
+	late := book(t, db, 1, 30, 31)
+
+	reservations, err := db.AllReservations(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reservations) != 2 || reservations[0].ID != early || reservations[1].ID != late {
+		t.Errorf("expected the reservations by arrival but got %+v", reservations)
+	}
+	if reservations[0].Room.RoomName != "major's suite" {
+		t.Errorf("expected the room with the reservation but got %+v", reservations[0].Room)
+	}
+
+	if err := db.UpdateReservationProcessed(early, 1); err != nil {
+		t.Fatal(err)
+	}
+	reservations, err = db.AllNewReservations(1)
+	if err != nil || len(reservations) != 1 || reservations[0].ID != late {
+		t.Errorf("expected only reservation %d new but got %+v, %v", late, reservations, err)
+	}
+
+	var tests = []struct {
+		text     string
+		expected int
+	}{
+		{"ada love", 2},
+		{"ADA@EXAMPLE", 2},
+		{"0100", 2},
+		{"nobody", 0},
+	}
+	for _, e := range tests {
+		found, err := db.SearchReservations(1, e.text)
+		if err != nil || len(found) != e.expected {
+			t.Errorf("%q: expected %d reservations but got %+v, %v", e.text, e.expected, found, err)
+		}
+	}
+
+	found, err := db.SearchReservations(1, strconv.Itoa(late))
+	if err != nil || len(found) != 1 || found[0].ID != late {
+		t.Errorf("expected reservation %d by id but got %+v, %v", late, found, err)
+	}
+}
+
+func testBlocks(t *testing.T, db repository.DatabaseRepo) {
+	if err := db.InsertBlockForRoom(1, night(5)); err != nil {
+		t.Fatal(err)
+	}
+	if free(t, db, 1, 5, 6) || !free(t, db, 1, 6, 7) {
+		t.Errorf("expected only night 5 to be blocked")
+	}
+
+	blocks, err := db.GetRestrictionForRoomByDate(1, night(4), night(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 1 || blocks[0].RestrictionID != models.RestrictionOwnerBlock ||
+		!blocks[0].StartDate.Equal(night(5)) || !blocks[0].EndDate.Equal(night(6)) {
+		t.Fatalf("expected the block of night 5 but got %+v", blocks)
+	}
+
+	booked, err := db.GetRestrictionsForPropertyByDate(1, night(4), night(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(booked) != 1 || booked[0].ID != blocks[0].ID {
+		t.Errorf("expected the block among what the property has booked but got %+v", booked)
+	}
+	booked, err = db.GetRestrictionsForPropertyByDate(1, night(6), night(8))
+	if err != nil || len(booked) != 0 {
+		t.Errorf("expected nothing booked after the block but got %+v, %v", booked, err)
+	}
+
+	if err := db.DeleteBlockByID(blocks[0].ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if free(t, db, 1, 5, 6) {
+		t.Errorf("expected the block to stay when removed from another room")
+	}
+	if err := db.DeleteBlockByID(blocks[0].ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !free(t, db, 1, 5, 6) {
+		t.Errorf("expected the block to be removed")
+	}
+}
+
+func testRules(t *testing.T, db repository.DatabaseRepo) {
+	id, err := db.InsertRoomRule(models.RoomRule{RoomID: 1, RestrictionID: models.RuleMinStay,
+		StartDate: night(10), EndDate: night(12), Value: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := db.GetRulesForRoomByDate(1, night(12), night(14))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].ID != id || rules[0].Value != 3 ||
+		rules[0].Restriction.RestrictionName != "min_stay" {
+		t.Errorf("expected the min stay rule but got %+v", rules)
+	}
+
+	rules, err = db.GetRulesForPropertyByDate(1, night(13), night(14))
+	if err != nil || len(rules) != 0 {
+		t.Errorf("expected no rules after it ends but got %+v, %v", rules, err)
+	}
+
+	days, err := db.RoomCalendar(1, night(12), night(13))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 || days[0].MinStay != 3 || days[1].MinStay != 0 {
+		t.Errorf("expected the min stay on the last night of the rule only but got %+v", days)
+	}
+
+	if err := db.DeleteRoomRule(id, 1); err != nil {
+		t.Fatal(err)
+	}
+	rules, err = db.GetRulesForRoomByDate(1, night(10), night(12))
+	if err != nil || len(rules) != 0 {
+		t.Errorf("expected the rule to be deleted but got %+v, %v", rules, err)
+	}
+}
+
+func testRooms(t *testing.T, db repository.DatabaseRepo) {
+	id, err := db.InsertRoom(models.Room{RoomName: "attic", PropertyID: 1, MaxOccupancy: 1, NightlyRate: 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rooms, err := db.AllRooms(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 3 || rooms[0].ID != id || rooms[0].NightlyRate != 80 {
+		t.Errorf("expected the attic first but got %+v", rooms)
+	}
+
+	roomTypes, err := db.SearchAvailabilityForAllRooms(1, night(1), night(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roomTypes) != 3 || roomTypes[2].TypeName != "attic" || roomTypes[2].Units != 1 ||
+		len(roomTypes[2].AvailableRooms) != 1 || roomTypes[2].AvailableRooms[0].ID != id {
+		t.Errorf("expected a room without a type to be offered as a type of its own but got %+v", roomTypes)
+	}
+}
+
+func testUsers(t *testing.T, db repository.DatabaseRepo) {
+	id, err := db.InsertUser(models.User{FirstName: "Grace", LastName: "Hopper",
+		Email: "grace@example.com", Password: "cobol1959", AccessLevel: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.InsertUser(models.User{Email: "grace@example.com", Password: "again1234"}); err == nil {
+		t.Errorf("expected a second user with the same email to fail")
+	}
+
+	u, err := db.GetUserByEmail("grace@example.com")
+	if err != nil || u.ID != id || u.Password == "cobol1959" {
+		t.Errorf("expected user %d with a hashed password but got %+v, %v", id, u, err)
+	}
+
+	if got, _, err := db.Authenticate("grace@example.com", "cobol1959"); err != nil || got != id {
+		t.Errorf("expected to log in as %d but got %d, %v", id, got, err)
+	}
+	if _, _, err := db.Authenticate("grace@example.com", "wrong"); err == nil {
+		t.Errorf("expected a wrong password to fail")
+	}
+
+	if err := db.UpdatePassword(id, "flowmatic"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := db.Authenticate("grace@example.com", "flowmatic"); err != nil {
+		t.Errorf("expected the new password to work but got %v", err)
+	}
+
+	if err := db.SetUserDisabled(id, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := db.Authenticate("grace@example.com", "flowmatic"); err == nil {
+		t.Errorf("expected a disabled user not to log in")
+	}
+	if u, _ := db.GetUserByID(id); !u.Disabled() {
+		t.Errorf("expected the user to be disabled")
+	}
+
+	if err := db.AssignUserToProperty(id, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AssignUserToProperty(id, 1); err != nil {
+		t.Errorf("expected assigning twice to be fine but got %v", err)
+	}
+	properties, err := db.GetPropertiesForUser(id)
+	if err != nil || len(properties) != 1 {
+		t.Errorf("expected one property but got %+v, %v", properties, err)
+	}
+	if err := db.RemoveUserFromProperty(id, 1); err != nil {
+		t.Fatal(err)
+	}
+	properties, err = db.GetPropertiesForUser(id)
+	if err != nil || len(properties) != 0 {
+		t.Errorf("expected no properties but got %+v, %v", properties, err)
+	}
+}
+
+func testWaitlist(t *testing.T, db repository.DatabaseRepo) {
+	id, err := db.InsertWaitlistEntry(models.WaitlistEntry{PropertyID: 1, Email: "wait@example.com",
+		StartDate: night(10), EndDate: night(12), Adults: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := db.WaitlistEntriesForNights(1, 2, night(9), night(13))
+	if err != nil || len(entries) != 1 || entries[0].ID != id {
+		t.Fatalf("expected entry %d but got %+v, %v", id, entries, err)
+	}
+
+	expires := time.Now().Add(time.Hour)
+	if err := db.PlaceWaitlistHold(id, 2, "token", expires); err != nil {
+		t.Fatal(err)
+	}
+	if free(t, db, 2, 10, 12) {
+		t.Errorf("expected the held room to be taken")
+	}
+	entry, err := db.GetWaitlistEntryByToken("token")
+	if err != nil || entry.ID != id || entry.HoldRoomID != 2 || entry.HoldRestrictionID == 0 {
+		t.Errorf("expected the held entry but got %+v, %v", entry, err)
+	}
+	entries, err = db.WaitlistEntriesForNights(1, 2, night(9), night(13))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected a held entry not to be offered again but got %+v, %v", entries, err)
+	}
+
+	expired, err := db.ExpiredWaitlistHolds(expires.Add(time.Minute))
+	if err != nil || len(expired) != 1 {
+		t.Errorf("expected the hold to expire but got %+v, %v", expired, err)
+	}
+
+	if err := db.ReleaseWaitlistHold(id); err != nil {
+		t.Fatal(err)
+	}
+	if !free(t, db, 2, 10, 12) {
+		t.Errorf("expected the released room to be free")
+	}
+
+	if err := db.PlaceWaitlistHold(999, 2, "other", expires); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing entry but got %v", err)
+	}
+}
@@ -2,14 +2,22 @@ package dbrepo
 
 import (
 	"database/sql"
+	"sync"
 
 	"github.com/chenemiken/goland/bookings/internal/config"
+	"github.com/chenemiken/goland/bookings/internal/models"
 	"github.com/chenemiken/goland/bookings/internal/repository"
 )
 
 type postgresDBRepo struct {
 	App *config.AppConfig
-	DB  *sql.DB
+	DB  conn
+}
+
+// mysqlDBRepo runs the queries of postgresDBRepo on MySQL, through the
+// mysql dialect, and has its own versions of the few MySQL cannot run.
+type mysqlDBRepo struct {
+	postgresDBRepo
 }
 
 type testDBRepo struct {
@@ -17,17 +25,53 @@ type testDBRepo struct {
 	DB  *sql.DB
 }
 
-func NewPostgresRepo(conn *sql.DB, a *config.AppConfig) repository.DatabaseRepo {
+// memoryDBRepo keeps the tables in maps, guarded by mu.
+type memoryDBRepo struct {
+	App *config.AppConfig
+
+	mu               sync.RWMutex
+	lastID           map[string]int
+	users            map[int]models.User
+	userProperties   map[[2]int]bool
+	properties       map[int]models.Property
+	roomTypes        map[int]models.RoomType
+	rooms            map[int]models.Room
+	restrictions     map[int]models.Restriction
+	reservations     map[int]models.Reservation
+	roomRestrictions map[int]models.RoomRestrictions
+	rules            map[int]models.RoomRule
+	waitlist         map[int]models.WaitlistEntry
+}
+
+func NewPostgresRepo(db *sql.DB, a *config.AppConfig) repository.DatabaseRepo {
 	return &postgresDBRepo{
 		App: a,
-		DB:  conn,
+		DB:  conn{DB: db, dialect: postgresDialect{}},
 	}
 }
 
-func NewTestPostgresRepo(conn *sql.DB, a *config.AppConfig) repository.DatabaseRepo {
+func NewTestPostgresRepo(db *sql.DB, a *config.AppConfig) repository.DatabaseRepo {
 	return &postgresDBRepo{
 		App: a,
-		DB:  conn,
+		DB:  conn{DB: db, dialect: postgresDialect{}},
+	}
+}
+
+// NewSQLRepo returns the repository for a database connected to with the
+// driver, postgres or mysql.
+func NewSQLRepo(driver string, db *sql.DB, a *config.AppConfig) repository.DatabaseRepo {
+	if driver == "mysql" {
+		return NewMySQLRepo(db, a)
+	}
+	return NewPostgresRepo(db, a)
+}
+
+func NewMySQLRepo(db *sql.DB, a *config.AppConfig) repository.DatabaseRepo {
+	return &mysqlDBRepo{
+		postgresDBRepo: postgresDBRepo{
+			App: a,
+			DB:  conn{DB: db, dialect: mysqlDialect{}},
+		},
 	}
 }
 
@@ -36,3 +80,25 @@ func NewTestingRepo(a *config.AppConfig) repository.DatabaseRepo {
 		App: a,
 	}
 }
+
+// NewMemoryRepo returns a repository that keeps everything in memory,
+// starting with the rows the migrations seed and an admin user. Nothing is
+// saved when the app stops.
+func NewMemoryRepo(a *config.AppConfig) repository.DatabaseRepo {
+	m := &memoryDBRepo{
+		App:              a,
+		lastID:           map[string]int{},
+		users:            map[int]models.User{},
+		userProperties:   map[[2]int]bool{},
+		properties:       map[int]models.Property{},
+		roomTypes:        map[int]models.RoomType{},
+		rooms:            map[int]models.Room{},
+		restrictions:     map[int]models.Restriction{},
+		reservations:     map[int]models.Reservation{},
+		roomRestrictions: map[int]models.RoomRestrictions{},
+		rules:            map[int]models.RoomRule{},
+		waitlist:         map[int]models.WaitlistEntry{},
+	}
+	m.seed()
+	return m
+}
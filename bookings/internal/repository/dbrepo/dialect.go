@@ -0,0 +1,145 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// The queries of the repository are written for Postgres. A conn runs them
+// on other databases by passing them through the dialect of the database,
+// which rewrites the $1 placeholders and gets the id of inserted rows the
+// way the database returns it.
+
+// dialect is how the SQL of a database differs from Postgres.
+type dialect interface {
+	// rebind rewrites the placeholders of query for the database, and
+	// returns the arguments in the order the new placeholders take them.
+	rebind(query string, args []interface{}) (string, []interface{})
+
+	// insert runs stmt, an insert written without a returning clause, and
+	// returns the id of the row added, or sql.ErrNoRows when none was.
+	insert(ctx context.Context, q queryer, stmt string, args []interface{}) (int, error)
+}
+
+// queryer is what a conn and a tx have in common.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) rebind(query string, args []interface{}) (string, []interface{}) {
+	return query, args
+}
+
+func (postgresDialect) insert(ctx context.Context, q queryer, stmt string, args []interface{}) (int, error) {
+	var id int
+	err := q.QueryRowContext(ctx, stmt+" returning id", args...).Scan(&id)
+	return id, err
+}
+
+type mysqlDialect struct{}
+
+// rebind turns each $n into a ?, repeating the argument when $n appears
+// more than once. Text in quotes is left alone. Queries written for MySQL,
+// with no $n, are returned as they are.
+func (mysqlDialect) rebind(query string, args []interface{}) (string, []interface{}) {
+	var b strings.Builder
+	var bound []interface{}
+	quoted := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c == '\'' {
+			quoted = !quoted
+		}
+		if c != '$' || quoted {
+			b.WriteByte(c)
+			continue
+		}
+
+		end := i + 1
+		for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+			end++
+		}
+		n, err := strconv.Atoi(query[i+1 : end])
+		if err != nil || n < 1 || n > len(args) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('?')
+		bound = append(bound, args[n-1])
+		i = end - 1
+	}
+
+	if bound == nil {
+		return query, args
+	}
+	return b.String(), bound
+}
+
+func (mysqlDialect) insert(ctx context.Context, q queryer, stmt string, args []interface{}) (int, error) {
+	result, err := q.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return 0, sql.ErrNoRows
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// conn is a database pool running queries through its dialect.
+type conn struct {
+	*sql.DB
+	dialect dialect
+}
+
+func (c conn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, args = c.dialect.rebind(query, args)
+	return c.DB.ExecContext(ctx, query, args...)
+}
+
+func (c conn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, args = c.dialect.rebind(query, args)
+	return c.DB.QueryContext(ctx, query, args...)
+}
+
+func (c conn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query, args = c.dialect.rebind(query, args)
+	return c.DB.QueryRowContext(ctx, query, args...)
+}
+
+// insert runs an insert statement and returns the id of the new row.
+func (c conn) insert(ctx context.Context, stmt string, args ...interface{}) (int, error) {
+	return c.dialect.insert(ctx, c, stmt, args)
+}
+
+func (c conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (tx, error) {
+	t, err := c.DB.BeginTx(ctx, opts)
+	return tx{Tx: t, dialect: c.dialect}, err
+}
+
+// tx is a transaction running queries through its dialect.
+type tx struct {
+	*sql.Tx
+	dialect dialect
+}
+
+func (t tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, args = t.dialect.rebind(query, args)
+	return t.Tx.ExecContext(ctx, query, args...)
+}
+
+func (t tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query, args = t.dialect.rebind(query, args)
+	return t.Tx.QueryRowContext(ctx, query, args...)
+}
+
+// insert runs an insert statement and returns the id of the new row.
+func (t tx) insert(ctx context.Context, stmt string, args ...interface{}) (int, error) {
+	return t.dialect.insert(ctx, t, stmt, args)
+}
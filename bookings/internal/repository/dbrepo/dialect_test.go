@@ -0,0 +1,34 @@
+package dbrepo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMySQLRebind(t *testing.T) {
+	var tests = []struct {
+		name         string
+		query        string
+		args         []interface{}
+		expected     string
+		expectedArgs []interface{}
+	}{
+		{"in order", "select 1 where a = $1 and b = $2", []interface{}{1, 2},
+			"select 1 where a = ? and b = ?", []interface{}{1, 2}},
+		{"repeated", "values ($2, $1, $2)", []interface{}{"a", "b"},
+			"values (?, ?, ?)", []interface{}{"b", "a", "b"}},
+		{"past nine", "$10, $1", []interface{}{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			"?, ?", []interface{}{10, 1}},
+		{"quoted", "select '$1', $1 where a <> ''", []interface{}{"x"},
+			"select '$1', ? where a <> ''", []interface{}{"x"}},
+		{"already mysql", "select 1 where a = ?", []interface{}{1},
+			"select 1 where a = ?", []interface{}{1}},
+	}
+
+	for _, e := range tests {
+		query, args := mysqlDialect{}.rebind(e.query, e.args)
+		if query != e.expected || !reflect.DeepEqual(args, e.expectedArgs) {
+			t.Errorf("%s: expected %q %v but got %q %v", e.name, e.expected, e.expectedArgs, query, args)
+		}
+	}
+}
@@ -0,0 +1,1120 @@
+package dbrepo
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/dates"
+	"github.com/chenemiken/goland/bookings/internal/models"
+	"github.com/chenemiken/goland/bookings/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The memory repository stands in for Postgres when running the app
+// locally. It keeps the same rules as the database: missing rows are
+// sql.ErrNoRows, rows must point at rows that exist, unique columns stay
+// unique, and dates are calendar days. The contract tests run the same
+// checks against both.
+
+var (
+	errForeignKey = errors.New("the row it refers to does not exist")
+	errDuplicate  = errors.New("a row with the same key already exists")
+)
+
+// seedPassword is the password of the admin user the memory repository
+// starts with.
+const seedPassword = "password"
+
+// seedHash is the hash of seedPassword, made once as bcrypt is slow on
+// purpose.
+var seedHash = sync.OnceValue(func() string {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(seedPassword), passwordCost)
+	return string(hash)
+})
+
+// seed fills a new memory repository with what the migrations seed, plus an
+// admin user to log in with.
+func (m *memoryDBRepo) seed() {
+	day := time.Date(2024, 1, 13, 0, 0, 0, 0, time.UTC)
+
+	m.properties[1] = models.Property{ID: 1, PropertyName: "Fort Smythe", Slug: "fort-smythe",
+		Timezone: "UTC", CheckInTime: "15:00", CheckOutTime: "11:00", CreatedAt: day, UpdatedAt: day}
+
+	m.roomTypes[1] = models.RoomType{ID: 1, PropertyID: 1, TypeName: "General's Quarters", CreatedAt: day, UpdatedAt: day}
+	m.roomTypes[2] = models.RoomType{ID: 2, PropertyID: 1, TypeName: "Major's Suite", CreatedAt: day, UpdatedAt: day}
+
+	// the rooms were seeded before the room types, with lowercase names
+	rooms := time.Date(2023, 12, 18, 0, 0, 0, 0, time.UTC)
+	m.rooms[1] = models.Room{ID: 1, RoomName: "general's quarters", PropertyID: 1, RoomTypeID: 1,
+		MaxOccupancy: 2, CreatedAt: rooms, UpdatedAt: rooms}
+	m.rooms[2] = models.Room{ID: 2, RoomName: "major's suite", PropertyID: 1, RoomTypeID: 2,
+		MaxOccupancy: 2, CreatedAt: rooms, UpdatedAt: rooms}
+
+	for id, name := range map[int]string{
+		models.RestrictionReservation:  "reservation",
+		models.RestrictionOwnerBlock:   "owner_block",
+		models.RuleMinStay:             "min_stay",
+		models.RuleMaxStay:             "max_stay",
+		models.RuleClosedToArrival:     "closed_to_arrival",
+		models.RuleClosedToDeparture:   "closed_to_departure",
+		models.RuleWeekdayArrivalOnly:  "weekday_arrival_only",
+		models.RestrictionWaitlistHold: "Waitlist hold",
+	} {
+		m.restrictions[id] = models.Restriction{ID: id, RestrictionName: name, CreatedAt: day, UpdatedAt: day}
+	}
+
+	m.lastID["properties"] = 1
+	m.lastID["room_types"] = 2
+	m.lastID["rooms"] = 2
+
+	m.users[1] = models.User{ID: 1, FirstName: "Admin", LastName: "User", Email: "admin@admin.com",
+		Password: seedHash(), AccessLevel: models.AccessAdmin, CreatedAt: day, UpdatedAt: day}
+	m.lastID["users"] = 1
+}
+
+// nextID returns the next id of a table, like a serial column.
+func (m *memoryDBRepo) nextID(table string) int {
+	m.lastID[table]++
+	return m.lastID[table]
+}
+
+// sortedIDs returns the keys of a table in order.
+func sortedIDs[T any](rows map[int]T) []int {
+	ids := make([]int, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// withRoom fills in the room a reservation is for.
+func (m *memoryDBRepo) withRoom(r models.Reservation) models.Reservation {
+	room := m.rooms[r.RoomID]
+	r.Room = models.Room{ID: room.ID, RoomName: room.RoomName,
+		PropertyID: room.PropertyID, RoomTypeID: room.RoomTypeID}
+	return r
+}
+
+// taken reports whether a room has a live restriction overlapping the nights
+// from start to end.
+func (m *memoryDBRepo) taken(roomID int, start, end time.Time) bool {
+	return m.takenExcept(roomID, start, end, 0)
+}
+
+// takenExcept is taken leaving out the restriction with id except.
+func (m *memoryDBRepo) takenExcept(roomID int, start, end time.Time, except int) bool {
+	start, end = dates.Day(start), dates.Day(end)
+	for _, rr := range m.roomRestrictions {
+		if rr.RoomID == roomID && rr.DeletedAt.IsZero() && rr.ID != except &&
+			start.Before(rr.EndDate) && end.After(rr.StartDate) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *memoryDBRepo) InsertReservation(res models.Reservation) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rooms[res.RoomID]; !ok {
+		return 0, errForeignKey
+	}
+
+	now := time.Now()
+	res.ID = m.nextID("reservations")
+	res.StartDate, res.EndDate = dates.Day(res.StartDate), dates.Day(res.EndDate)
+	res.Processed = 0
+	res.DeletedAt = time.Time{}
+	res.Room = models.Room{}
+	res.CreatedAt, res.UpdatedAt = now, now
+	m.reservations[res.ID] = res
+
+	return res.ID, nil
+}
+
+func (m *memoryDBRepo) InsertRoomRestriction(rr models.RoomRestrictions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rooms[rr.RoomID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := m.restrictions[rr.RestrictionID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := m.reservations[rr.ReservationID]; !ok {
+		return errForeignKey
+	}
+
+	m.insertRoomRestriction(rr)
+	return nil
+}
+
+func (m *memoryDBRepo) ReserveRoom(res models.Reservation, heldID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rooms[res.RoomID]; !ok {
+		return 0, sql.ErrNoRows
+	}
+	if m.takenExcept(res.RoomID, res.StartDate, res.EndDate, heldID) {
+		return 0, repository.ErrRoomTaken
+	}
+
+	now := time.Now()
+	res.ID = m.nextID("reservations")
+	res.StartDate, res.EndDate = dates.Day(res.StartDate), dates.Day(res.EndDate)
+	res.Processed = 0
+	res.DeletedAt = time.Time{}
+	res.Room = models.Room{}
+	res.CreatedAt, res.UpdatedAt = now, now
+	m.reservations[res.ID] = res
+
+	m.insertRoomRestriction(models.RoomRestrictions{
+		StartDate:     res.StartDate,
+		EndDate:       res.EndDate,
+		RoomID:        res.RoomID,
+		RestrictionID: models.RestrictionReservation,
+		ReservationID: res.ID,
+	})
+
+	return res.ID, nil
+}
+
+func (m *memoryDBRepo) insertRoomRestriction(rr models.RoomRestrictions) int {
+	now := time.Now()
+	rr.ID = m.nextID("room_restrictions")
+	rr.StartDate, rr.EndDate = dates.Day(rr.StartDate), dates.Day(rr.EndDate)
+	rr.DeletedAt = time.Time{}
+	rr.CreatedAt, rr.UpdatedAt = now, now
+	rr.Room, rr.Restriction, rr.Reservation = models.Room{}, models.Restriction{}, models.Reservation{}
+	m.roomRestrictions[rr.ID] = rr
+	return rr.ID
+}
+
+func (m *memoryDBRepo) SearchAvailabilityByDatesByRoomId(startDate,
+	endDate time.Time, roomId int) (bool, error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return !m.taken(roomId, startDate, endDate), nil
+}
+
+func (m *memoryDBRepo) SearchAvailabilityForAllRooms(propertyID int,
+	start, end time.Time) ([]models.RoomType, error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var free []models.Room
+	for _, room := range m.rooms {
+		if _, ok := m.roomTypes[room.RoomTypeID]; ok && room.PropertyID == propertyID &&
+			!m.taken(room.ID, start, end) {
+			free = append(free, room)
+		}
+	}
+	sort.Slice(free, func(i, j int) bool {
+		a, b := m.roomTypes[free[i].RoomTypeID], m.roomTypes[free[j].RoomTypeID]
+		if a.TypeName != b.TypeName {
+			return a.TypeName < b.TypeName
+		}
+		return free[i].RoomName < free[j].RoomName
+	})
+
+	var roomTypes []models.RoomType
+	for _, room := range free {
+		last := len(roomTypes) - 1
+		if last < 0 || roomTypes[last].ID != room.RoomTypeID {
+			roomTypes = append(roomTypes, m.roomType(room.RoomTypeID))
+			last++
+		}
+		roomTypes[last].AvailableRooms = append(roomTypes[last].AvailableRooms, room)
+	}
+
+	return roomTypes, nil
+}
+
+// roomType returns a room type with its number of units.
+func (m *memoryDBRepo) roomType(id int) models.RoomType {
+	rt := m.roomTypes[id]
+	rt.AvailableRooms = nil
+	rt.Units = 0
+	for _, room := range m.rooms {
+		if room.RoomTypeID == id {
+			rt.Units++
+		}
+	}
+	return rt
+}
+
+func (m *memoryDBRepo) AvailableRoomsForType(roomTypeID int,
+	start, end time.Time) ([]models.Room, error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rooms []models.Room
+	for _, room := range m.rooms {
+		if room.RoomTypeID == roomTypeID && roomTypeID > 0 && !m.taken(room.ID, start, end) {
+			rooms = append(rooms, room)
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].RoomName < rooms[j].RoomName })
+
+	return rooms, nil
+}
+
+func (m *memoryDBRepo) RoomCalendar(roomID int,
+	start, end time.Time) ([]models.DayAvailability, error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var days []models.DayAvailability
+
+	room, ok := m.rooms[roomID]
+	if !ok {
+		return days, nil
+	}
+
+	for d := dates.Day(start); !d.After(dates.Day(end)); d = d.AddDate(0, 0, 1) {
+		day := models.DayAvailability{
+			Date:      d,
+			Available: !m.taken(roomID, d, d.AddDate(0, 0, 1)),
+			Price:     room.NightlyRate,
+		}
+		for _, rule := range m.rules {
+			if rule.RoomID != roomID || d.Before(rule.StartDate) || d.After(rule.EndDate) {
+				continue
+			}
+			switch rule.RestrictionID {
+			case models.RuleMinStay:
+				if rule.Value > day.MinStay {
+					day.MinStay = rule.Value
+				}
+			case models.RuleClosedToArrival:
+				day.ClosedToArrival = true
+			case models.RuleClosedToDeparture:
+				day.ClosedToDeparture = true
+			}
+		}
+		days = append(days, day)
+	}
+
+	return days, nil
+}
+
+func (m *memoryDBRepo) GetRoomTypeByID(id int) (models.RoomType, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.roomTypes[id]; !ok {
+		return models.RoomType{}, sql.ErrNoRows
+	}
+	return m.roomType(id), nil
+}
+
+func (m *memoryDBRepo) GetRoomById(id int) (models.Room, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	room, ok := m.rooms[id]
+	if !ok {
+		return models.Room{}, sql.ErrNoRows
+	}
+	return room, nil
+}
+
+func (m *memoryDBRepo) AllRooms(propertyID int) ([]models.Room, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rooms []models.Room
+	for _, room := range m.rooms {
+		if room.PropertyID == propertyID {
+			rooms = append(rooms, room)
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].RoomName < rooms[j].RoomName })
+
+	return rooms, nil
+}
+
+func (m *memoryDBRepo) InsertRoom(r models.Room) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.properties[r.PropertyID]; !ok {
+		return 0, errForeignKey
+	}
+	if _, ok := m.roomTypes[r.RoomTypeID]; r.RoomTypeID > 0 && !ok {
+		return 0, errForeignKey
+	}
+
+	now := time.Now()
+	if r.RoomTypeID == 0 {
+		// a room of its own type, as in the database
+		r.RoomTypeID = m.nextID("room_types")
+		m.roomTypes[r.RoomTypeID] = models.RoomType{ID: r.RoomTypeID,
+			PropertyID: r.PropertyID, TypeName: r.RoomName, CreatedAt: now, UpdatedAt: now}
+	}
+	r.ID = m.nextID("rooms")
+	r.CreatedAt, r.UpdatedAt = now, now
+	m.rooms[r.ID] = r
+
+	return r.ID, nil
+}
+
+func (m *memoryDBRepo) AllRestrictions() ([]models.Restriction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var restrictions []models.Restriction
+	for _, id := range sortedIDs(m.restrictions) {
+		restrictions = append(restrictions, m.restrictions[id])
+	}
+	return restrictions, nil
+}
+
+func (m *memoryDBRepo) InsertRestriction(r models.Restriction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.restrictions[r.ID]; ok {
+		return errDuplicate
+	}
+
+	now := time.Now()
+	r.CreatedAt, r.UpdatedAt = now, now
+	m.restrictions[r.ID] = r
+	return nil
+}
+
+func (m *memoryDBRepo) AllUsers() ([]models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var users []models.User
+	for _, u := range m.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+
+	return users, nil
+}
+
+func (m *memoryDBRepo) GetUserByID(id int) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return models.User{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (m *memoryDBRepo) GetUserByEmail(email string) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.userByEmail(email)
+	if !ok {
+		return models.User{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (m *memoryDBRepo) userByEmail(email string) (models.User, bool) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, true
+		}
+	}
+	return models.User{}, false
+}
+
+func (m *memoryDBRepo) InsertUser(u models.User) (int, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), passwordCost)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.userByEmail(u.Email); ok {
+		return 0, errDuplicate
+	}
+
+	now := time.Now()
+	u.ID = m.nextID("users")
+	u.Password = string(hash)
+	u.DisabledAt = time.Time{}
+	u.CreatedAt, u.UpdatedAt = now, now
+	m.users[u.ID] = u
+
+	return u.ID, nil
+}
+
+func (m *memoryDBRepo) UpdateUser(u models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.users[u.ID]
+	if !ok {
+		return nil
+	}
+	if other, ok := m.userByEmail(u.Email); ok && other.ID != u.ID {
+		return errDuplicate
+	}
+
+	existing.FirstName = u.FirstName
+	existing.LastName = u.LastName
+	existing.Email = u.Email
+	existing.AccessLevel = u.AccessLevel
+	existing.UpdatedAt = time.Now()
+	m.users[u.ID] = existing
+	return nil
+}
+
+func (m *memoryDBRepo) UpdatePassword(id int, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if u, ok := m.users[id]; ok {
+		u.Password = string(hash)
+		u.UpdatedAt = time.Now()
+		m.users[id] = u
+	}
+	return nil
+}
+
+func (m *memoryDBRepo) SetUserDisabled(id int, disabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if u, ok := m.users[id]; ok {
+		u.DisabledAt = time.Time{}
+		if disabled {
+			u.DisabledAt = time.Now()
+		}
+		u.UpdatedAt = time.Now()
+		m.users[id] = u
+	}
+	return nil
+}
+
+func (m *memoryDBRepo) Authenticate(email, testPassword string) (int, string, error) {
+	m.mu.RLock()
+	u, ok := m.userByEmail(email)
+	m.mu.RUnlock()
+
+	if !ok {
+		return 0, "", sql.ErrNoRows
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(testPassword))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return 0, "", errors.New("incorrect password")
+	} else if err != nil {
+		return 0, "", err
+	}
+
+	if u.Disabled() {
+		return 0, "", errors.New("user is disabled")
+	}
+
+	return u.ID, u.Password, nil
+}
+
+// reservationsWhere returns the reservations of rooms of a property that
+// match keep, ordered by arrival.
+func (m *memoryDBRepo) reservationsWhere(propertyID int,
+	keep func(r models.Reservation) bool) []models.Reservation {
+
+	var reservations []models.Reservation
+	for _, id := range sortedIDs(m.reservations) {
+		r := m.withRoom(m.reservations[id])
+		if r.Room.ID != 0 && r.Room.PropertyID == propertyID && keep(r) {
+			reservations = append(reservations, r)
+		}
+	}
+	sort.SliceStable(reservations, func(i, j int) bool {
+		return reservations[i].StartDate.Before(reservations[j].StartDate)
+	})
+	return reservations
+}
+
+func (m *memoryDBRepo) AllReservations(propertyID int) ([]models.Reservation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.reservationsWhere(propertyID, func(r models.Reservation) bool {
+		return r.DeletedAt.IsZero()
+	}), nil
+}
+
+func (m *memoryDBRepo) AllNewReservations(propertyID int) ([]models.Reservation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.reservationsWhere(propertyID, func(r models.Reservation) bool {
+		return r.DeletedAt.IsZero() && r.Processed == 0
+	}), nil
+}
+
+func (m *memoryDBRepo) SearchReservations(propertyID int, text string) ([]models.Reservation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	needle := strings.ToLower(text)
+	return m.reservationsWhere(propertyID, func(r models.Reservation) bool {
+		if !r.DeletedAt.IsZero() {
+			return false
+		}
+		for _, field := range []string{r.FirstName + " " + r.LastName, r.Email, r.Phone} {
+			if strings.Contains(strings.ToLower(field), needle) {
+				return true
+			}
+		}
+		return strconv.Itoa(r.ID) == text
+	}), nil
+}
+
+func (m *memoryDBRepo) GetReservationByID(id int) (models.Reservation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	r, ok := m.reservations[id]
+	if !ok {
+		return models.Reservation{}, sql.ErrNoRows
+	}
+	return m.withRoom(r), nil
+}
+
+func (m *memoryDBRepo) UpdateReservation(u models.Reservation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.reservations[u.ID]; ok {
+		r.FirstName = u.FirstName
+		r.LastName = u.LastName
+		r.Email = u.Email
+		r.Phone = u.Phone
+		r.UpdatedAt = time.Now()
+		m.reservations[u.ID] = r
+	}
+	return nil
+}
+
+func (m *memoryDBRepo) DeleteReservation(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if r, ok := m.reservations[id]; ok && r.DeletedAt.IsZero() {
+		r.DeletedAt, r.UpdatedAt = now, now
+		m.reservations[id] = r
+	}
+	for rid, rr := range m.roomRestrictions {
+		if rr.ReservationID == id && rr.DeletedAt.IsZero() {
+			rr.DeletedAt, rr.UpdatedAt = now, now
+			m.roomRestrictions[rid] = rr
+		}
+	}
+	return nil
+}
+
+func (m *memoryDBRepo) UpdateReservationProcessed(id, processed int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.reservations[id]; ok {
+		r.Processed = processed
+		m.reservations[id] = r
+	}
+	return nil
+}
+
+func (m *memoryDBRepo) ReassignReservation(id, roomID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rooms[roomID]; !ok {
+		return errForeignKey
+	}
+
+	now := time.Now()
+	if r, ok := m.reservations[id]; ok {
+		r.RoomID, r.UpdatedAt = roomID, now
+		m.reservations[id] = r
+	}
+	for rid, rr := range m.roomRestrictions {
+		if rr.ReservationID == id {
+			rr.RoomID, rr.UpdatedAt = roomID, now
+			m.roomRestrictions[rid] = rr
+		}
+	}
+	return nil
+}
+
+func (m *memoryDBRepo) AllDeletedReservations(propertyID int) ([]models.Reservation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reservations := m.reservationsWhere(propertyID, func(r models.Reservation) bool {
+		return !r.DeletedAt.IsZero()
+	})
+	sort.SliceStable(reservations, func(i, j int) bool {
+		return reservations[i].DeletedAt.After(reservations[j].DeletedAt)
+	})
+	return reservations, nil
+}
+
+func (m *memoryDBRepo) RestoreReservation(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.reservations[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	for _, rr := range m.roomRestrictions {
+		if rr.RoomID == r.RoomID && rr.ReservationID != id && rr.DeletedAt.IsZero() &&
+			dates.Overlap(r.StartDate, r.EndDate, rr.StartDate, rr.EndDate) {
+			return repository.ErrRoomTaken
+		}
+	}
+
+	now := time.Now()
+	r.DeletedAt, r.UpdatedAt = time.Time{}, now
+	m.reservations[id] = r
+	for rid, rr := range m.roomRestrictions {
+		if rr.ReservationID == id {
+			rr.DeletedAt, rr.UpdatedAt = time.Time{}, now
+			m.roomRestrictions[rid] = rr
+		}
+	}
+	return nil
+}
+
+func (m *memoryDBRepo) PurgeDeletedReservations(before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, rr := range m.roomRestrictions {
+		if !rr.DeletedAt.IsZero() && rr.DeletedAt.Before(before) {
+			delete(m.roomRestrictions, id)
+		}
+	}
+
+	purged := 0
+	for id, r := range m.reservations {
+		if r.DeletedAt.IsZero() || !r.DeletedAt.Before(before) {
+			continue
+		}
+		delete(m.reservations, id)
+		purged++
+
+		// the foreign key cascades
+		for rid, rr := range m.roomRestrictions {
+			if rr.ReservationID == id {
+				delete(m.roomRestrictions, rid)
+			}
+		}
+	}
+
+	return purged, nil
+}
+
+func (m *memoryDBRepo) GetRestrictionForRoomByDate(roomId int,
+	start, end time.Time) ([]models.RoomRestrictions, error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start, end = dates.Day(start), dates.Day(end)
+
+	var roomRestrictions []models.RoomRestrictions
+	for _, id := range sortedIDs(m.roomRestrictions) {
+		rr := m.roomRestrictions[id]
+		if rr.RoomID == roomId && rr.DeletedAt.IsZero() &&
+			start.Before(rr.EndDate) && !end.Before(rr.StartDate) {
+			roomRestrictions = append(roomRestrictions, rr)
+		}
+	}
+	return roomRestrictions, nil
+}
+
+func (m *memoryDBRepo) GetRestrictionsForPropertyByDate(propertyID int,
+	start, end time.Time) ([]models.RoomRestrictions, error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start, end = dates.Day(start), dates.Day(end)
+
+	var roomRestrictions []models.RoomRestrictions
+	for _, id := range sortedIDs(m.roomRestrictions) {
+		rr := m.roomRestrictions[id]
+		if m.rooms[rr.RoomID].PropertyID == propertyID && rr.DeletedAt.IsZero() &&
+			start.Before(rr.EndDate) && end.After(rr.StartDate) {
+			roomRestrictions = append(roomRestrictions, rr)
+		}
+	}
+	sort.SliceStable(roomRestrictions, func(i, j int) bool {
+		a, b := roomRestrictions[i], roomRestrictions[j]
+		if a.RoomID != b.RoomID {
+			return a.RoomID < b.RoomID
+		}
+		return a.StartDate.Before(b.StartDate)
+	})
+	return roomRestrictions, nil
+}
+
+func (m *memoryDBRepo) InsertBlockForRoom(roomID int, startDate time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rooms[roomID]; !ok {
+		return errForeignKey
+	}
+
+	m.insertRoomRestriction(models.RoomRestrictions{
+		StartDate:     startDate,
+		EndDate:       startDate.AddDate(0, 0, 1),
+		RoomID:        roomID,
+		RestrictionID: models.RestrictionOwnerBlock,
+	})
+	return nil
+}
+
+func (m *memoryDBRepo) DeleteBlockByID(id, roomID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rr, ok := m.roomRestrictions[id]; ok && rr.RoomID == roomID &&
+		rr.RestrictionID == models.RestrictionOwnerBlock && rr.DeletedAt.IsZero() {
+		now := time.Now()
+		rr.DeletedAt, rr.UpdatedAt = now, now
+		m.roomRestrictions[id] = rr
+	}
+	return nil
+}
+
+func (m *memoryDBRepo) ShortenReservation(id int, start, end time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	start, end = dates.Day(start), dates.Day(end)
+	if r, ok := m.reservations[id]; ok {
+		r.StartDate, r.EndDate, r.UpdatedAt = start, end, now
+		m.reservations[id] = r
+	}
+	for rid, rr := range m.roomRestrictions {
+		if rr.ReservationID == id {
+			rr.StartDate, rr.EndDate, rr.UpdatedAt = start, end, now
+			m.roomRestrictions[rid] = rr
+		}
+	}
+	return nil
+}
+
+// rulesWhere returns the stay rules matching keep whose dates overlap start
+// to end, both inclusive, ordered by start date.
+func (m *memoryDBRepo) rulesWhere(start, end time.Time,
+	keep func(rule models.RoomRule) bool) []models.RoomRule {
+
+	start, end = dates.Day(start), dates.Day(end)
+
+	var rules []models.RoomRule
+	for _, id := range sortedIDs(m.rules) {
+		rule := m.rules[id]
+		if keep(rule) && !rule.StartDate.After(end) && !rule.EndDate.Before(start) {
+			rule.Restriction = models.Restriction{ID: rule.RestrictionID,
+				RestrictionName: m.restrictions[rule.RestrictionID].RestrictionName}
+			rules = append(rules, rule)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].StartDate.Before(rules[j].StartDate)
+	})
+	return rules
+}
+
+func (m *memoryDBRepo) GetRulesForRoomByDate(roomID int,
+	start, end time.Time) ([]models.RoomRule, error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.rulesWhere(start, end, func(rule models.RoomRule) bool {
+		return rule.RoomID == roomID
+	}), nil
+}
+
+func (m *memoryDBRepo) GetRulesForPropertyByDate(propertyID int,
+	start, end time.Time) ([]models.RoomRule, error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.rulesWhere(start, end, func(rule models.RoomRule) bool {
+		room, ok := m.rooms[rule.RoomID]
+		return ok && room.PropertyID == propertyID
+	}), nil
+}
+
+func (m *memoryDBRepo) InsertRoomRule(rule models.RoomRule) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rooms[rule.RoomID]; !ok {
+		return 0, errForeignKey
+	}
+	if _, ok := m.restrictions[rule.RestrictionID]; !ok {
+		return 0, errForeignKey
+	}
+
+	now := time.Now()
+	rule.ID = m.nextID("room_rules")
+	rule.StartDate, rule.EndDate = dates.Day(rule.StartDate), dates.Day(rule.EndDate)
+	rule.Restriction = models.Restriction{}
+	rule.CreatedAt, rule.UpdatedAt = now, now
+	m.rules[rule.ID] = rule
+
+	return rule.ID, nil
+}
+
+func (m *memoryDBRepo) DeleteRoomRule(id, roomID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rule, ok := m.rules[id]; ok && rule.RoomID == roomID {
+		delete(m.rules, id)
+	}
+	return nil
+}
+
+func (m *memoryDBRepo) AllProperties() ([]models.Property, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var properties []models.Property
+	for _, id := range sortedIDs(m.properties) {
+		properties = append(properties, m.properties[id])
+	}
+	return properties, nil
+}
+
+func (m *memoryDBRepo) GetPropertyByID(id int) (models.Property, error) {
+	return m.findProperty(func(p models.Property) bool { return p.ID == id })
+}
+
+func (m *memoryDBRepo) GetPropertyBySlug(slug string) (models.Property, error) {
+	return m.findProperty(func(p models.Property) bool { return p.Slug == slug })
+}
+
+func (m *memoryDBRepo) GetPropertyByHost(host string) (models.Property, error) {
+	return m.findProperty(func(p models.Property) bool {
+		return p.HostName != "" && strings.EqualFold(p.HostName, host)
+	})
+}
+
+func (m *memoryDBRepo) findProperty(match func(p models.Property) bool) (models.Property, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, id := range sortedIDs(m.properties) {
+		if p := m.properties[id]; match(p) {
+			return p, nil
+		}
+	}
+	return models.Property{}, sql.ErrNoRows
+}
+
+func (m *memoryDBRepo) UpdateProperty(p models.Property) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.properties[p.ID]; ok {
+		existing.Timezone = p.Timezone
+		existing.CheckInTime = p.CheckInTime
+		existing.CheckOutTime = p.CheckOutTime
+		existing.UpdatedAt = time.Now()
+		m.properties[p.ID] = existing
+	}
+	return nil
+}
+
+func (m *memoryDBRepo) GetPropertiesForUser(userID int) ([]models.Property, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var properties []models.Property
+	for _, id := range sortedIDs(m.properties) {
+		if m.userProperties[[2]int{userID, id}] {
+			properties = append(properties, m.properties[id])
+		}
+	}
+	return properties, nil
+}
+
+func (m *memoryDBRepo) AssignUserToProperty(userID, propertyID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := m.properties[propertyID]; !ok {
+		return errForeignKey
+	}
+
+	m.userProperties[[2]int{userID, propertyID}] = true
+	return nil
+}
+
+func (m *memoryDBRepo) RemoveUserFromProperty(userID, propertyID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.userProperties, [2]int{userID, propertyID})
+	return nil
+}
+
+func (m *memoryDBRepo) InsertWaitlistEntry(e models.WaitlistEntry) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.properties[e.PropertyID]; !ok {
+		return 0, errForeignKey
+	}
+
+	now := time.Now()
+	e.ID = m.nextID("waitlist_entries")
+	e.StartDate, e.EndDate = dates.Day(e.StartDate), dates.Day(e.EndDate)
+	e.HoldToken, e.HoldRoomID, e.HoldRestrictionID = "", 0, 0
+	e.HoldExpiresAt, e.BookedAt = time.Time{}, time.Time{}
+	e.CreatedAt, e.UpdatedAt = now, now
+	m.waitlist[e.ID] = e
+
+	return e.ID, nil
+}
+
+// waitlistWhere returns the waitlist entries matching keep, oldest first.
+func (m *memoryDBRepo) waitlistWhere(keep func(e models.WaitlistEntry) bool) []models.WaitlistEntry {
+	var entries []models.WaitlistEntry
+	for _, id := range sortedIDs(m.waitlist) {
+		if e := m.waitlist[id]; keep(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func (m *memoryDBRepo) WaitlistEntriesForNights(propertyID, roomID int,
+	start, end time.Time) ([]models.WaitlistEntry, error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start, end = dates.Day(start), dates.Day(end)
+	return m.waitlistWhere(func(e models.WaitlistEntry) bool {
+		return e.PropertyID == propertyID && (e.RoomID == roomID || e.RoomID == 0) &&
+			!e.StartDate.Before(start) && !e.EndDate.After(end) && e.HoldToken == ""
+	}), nil
+}
+
+func (m *memoryDBRepo) ExpiredWaitlistHolds(before time.Time) ([]models.WaitlistEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.waitlistWhere(func(e models.WaitlistEntry) bool {
+		return e.HoldRestrictionID > 0 && e.BookedAt.IsZero() && e.HoldExpiresAt.Before(before)
+	}), nil
+}
+
+func (m *memoryDBRepo) GetWaitlistEntryByToken(token string) (models.WaitlistEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := m.waitlistWhere(func(e models.WaitlistEntry) bool { return e.HoldToken == token })
+	if len(entries) == 0 {
+		return models.WaitlistEntry{}, sql.ErrNoRows
+	}
+	return entries[0], nil
+}
+
+func (m *memoryDBRepo) PlaceWaitlistHold(entryID, roomID int, token string,
+	expires time.Time) error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.waitlist[entryID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if _, ok := m.rooms[roomID]; !ok {
+		return errForeignKey
+	}
+
+	e.HoldRestrictionID = m.insertRoomRestriction(models.RoomRestrictions{
+		StartDate:     e.StartDate,
+		EndDate:       e.EndDate,
+		RoomID:        roomID,
+		RestrictionID: models.RestrictionWaitlistHold,
+	})
+	e.HoldToken = token
+	e.HoldRoomID = roomID
+	e.HoldExpiresAt = expires
+	e.UpdatedAt = time.Now()
+	m.waitlist[entryID] = e
+
+	return nil
+}
+
+func (m *memoryDBRepo) ReleaseWaitlistHold(entryID int) error {
+	return m.endWaitlistHold(entryID, false)
+}
+
+func (m *memoryDBRepo) CompleteWaitlistHold(entryID int) error {
+	return m.endWaitlistHold(entryID, true)
+}
+
+func (m *memoryDBRepo) endWaitlistHold(entryID int, booked bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.waitlist[entryID]
+	if !ok {
+		return nil
+	}
+	delete(m.roomRestrictions, e.HoldRestrictionID)
+
+	e.HoldRestrictionID = 0
+	e.BookedAt = time.Time{}
+	if booked {
+		e.BookedAt = time.Now()
+	}
+	e.UpdatedAt = time.Now()
+	m.waitlist[entryID] = e
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package dbrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/models"
+)
+
+// These are the queries of postgresDBRepo that MySQL cannot run as written.
+
+// RoomCalendar builds the days with a recursive query, as MySQL has no
+// generate_series. MySQL stops recursing after cte_max_recursion_depth
+// days, 1000 by default.
+func (m *mysqlDBRepo) RoomCalendar(roomID int,
+	start, end time.Time) ([]models.DayAvailability, error) {
+
+	query := `with recursive days (d) as (
+			select cast($2 as date) from dual where cast($2 as date) <= cast($3 as date)
+			union all
+			select d + interval 1 day from days where d < cast($3 as date)
+		)
+		select days.d,
+		not exists (select 1 from room_restrictions rr
+			where rr.room_id = rm.id and rr.deleted_at is null
+			and days.d >= rr.start_date and days.d < rr.end_date),
+		rm.nightly_rate,
+		coalesce((select max(ru.rule_value) from room_rules ru
+			where ru.room_id = rm.id and ru.restriction_id = $4
+			and days.d between ru.start_date and ru.end_date), 0),
+		exists (select 1 from room_rules ru
+			where ru.room_id = rm.id and ru.restriction_id = $5
+			and days.d between ru.start_date and ru.end_date),
+		exists (select 1 from room_rules ru
+			where ru.room_id = rm.id and ru.restriction_id = $6
+			and days.d between ru.start_date and ru.end_date)
+		from days
+		join rooms rm on rm.id = $1
+		order by days.d`
+
+	return m.roomCalendar(query, roomID, start, end)
+}
+
+// SearchReservations uses like, which ignores case with the default MySQL
+// collations, where Postgres needs ilike.
+func (m *mysqlDBRepo) SearchReservations(propertyID int, text string) ([]models.Reservation, error) {
+	query := `select r.id, r.first_name, r.last_name, r.email, r.phone,
+		r.start_date, r.end_date, r.room_id, r.created_at, r.updated_at,
+		r.processed, rm.id, rm.room_name, rm.property_id
+		from reservations r
+		left join rooms rm on (r.room_id = rm.id)
+		where r.deleted_at is null and rm.property_id = $1
+		and (concat(r.first_name, ' ', r.last_name) like $2 or r.email like $2
+			or r.phone like $2 or cast(r.id as char) = $3)
+		order by r.start_date asc`
+
+	return m.searchReservations(query, propertyID, text)
+}
+
+func (m *mysqlDBRepo) AssignUserToProperty(userID, propertyID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// the no-op update keeps an existing assignment without hiding other
+	// errors, as insert ignore would
+	stmt := `insert into user_properties (user_id, property_id, created_at,
+		updated_at) values ($1, $2, $3, $4)
+		on duplicate key update user_id = user_id`
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID, propertyID, time.Now(),
+		time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -2,31 +2,31 @@ package dbrepo
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/chenemiken/goland/bookings/internal/models"
+	"github.com/chenemiken/goland/bookings/internal/repository"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func (m *postgresDBRepo) AllUsers() bool {
-	return true
-}
+// passwordCost is the bcrypt cost of stored password hashes.
+const passwordCost = 12
 
 func (m *postgresDBRepo) InsertReservation(res models.Reservation) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	var newID int
-
 	stmt := `insert into reservations (first_name, last_name, email, phone,
-		start_date, end_date, room_id, created_at, updated_at)
-		values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id`
+		start_date, end_date, room_id, adults, children, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
-	err := m.DB.QueryRowContext(ctx, stmt, res.FirstName, res.LastName,
+	newID, err := m.DB.insert(ctx, stmt, res.FirstName, res.LastName,
 		res.Email, res.Phone, res.StartDate, res.EndDate, res.RoomID,
-		time.Now(), time.Now(),
-	).Scan(&newID)
+		res.Adults, res.Children, time.Now(), time.Now(),
+	)
 
 	if err != nil {
 		return 0, err
@@ -53,6 +53,67 @@ func (m *postgresDBRepo) InsertRoomRestriction(rr models.RoomRestrictions) error
 	return nil
 }
 
+// ReserveRoom books the room of res: the reservation and its room
+// restriction are inserted in one transaction, with the room row locked so
+// two guests cannot get the same nights. ErrRoomTaken is returned when the
+// stay overlaps a restriction of the room other than heldID, the waitlist
+// hold the guest books through.
+func (m *postgresDBRepo) ReserveRoom(res models.Reservation, heldID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var roomID int
+	err = tx.QueryRowContext(ctx, `select id from rooms where id = $1 for update`,
+		res.RoomID).Scan(&roomID)
+	if err != nil {
+		return 0, err
+	}
+
+	var numRows int
+	query := `select count(id) from room_restrictions
+		where room_id = $1 and deleted_at is null and id <> $2 and
+		$3 < end_date and $4 > start_date`
+
+	err = tx.QueryRowContext(ctx, query, res.RoomID, heldID, res.StartDate,
+		res.EndDate).Scan(&numRows)
+	if err != nil {
+		return 0, err
+	}
+	if numRows > 0 {
+		return 0, repository.ErrRoomTaken
+	}
+
+	stmt := `insert into reservations (first_name, last_name, email, phone,
+		start_date, end_date, room_id, adults, children, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	newID, err := tx.insert(ctx, stmt, res.FirstName, res.LastName,
+		res.Email, res.Phone, res.StartDate, res.EndDate, res.RoomID,
+		res.Adults, res.Children, time.Now(), time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt = `insert into room_restrictions (start_date, end_date, room_id,
+		restriction_id, reservation_id, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = tx.ExecContext(ctx, stmt, res.StartDate, res.EndDate, res.RoomID,
+		models.RestrictionReservation, newID, time.Now(), time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, tx.Commit()
+}
+
 func (m *postgresDBRepo) SearchAvailabilityByDatesByRoomId(startDate,
 	endDate time.Time, roomId int) (bool, error) {
 
@@ -62,7 +123,7 @@ func (m *postgresDBRepo) SearchAvailabilityByDatesByRoomId(startDate,
 	var numRows int
 
 	query := `select count(id) from room_restrictions
-		where room_id = $1 and 
+		where room_id = $1 and deleted_at is null and
 		$2 < end_date and $3 > start_date;`
 
 	row := m.DB.QueryRowContext(ctx, query, roomId, startDate, endDate)
@@ -77,47 +138,202 @@ func (m *postgresDBRepo) SearchAvailabilityByDatesByRoomId(startDate,
 	return false, nil
 }
 
-func (m *postgresDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) (
-	[]models.Room, error) {
+// RoomCalendar returns, for every night from start to end inclusive, whether
+// the room is free along with its rate and the stay rules starting that day.
+func (m *postgresDBRepo) RoomCalendar(roomID int,
+	start, end time.Time) ([]models.DayAvailability, error) {
+
+	query := `select d::date,
+		not exists (select 1 from room_restrictions rr
+			where rr.room_id = rm.id and rr.deleted_at is null
+			and d >= rr.start_date and d < rr.end_date),
+		rm.nightly_rate,
+		coalesce((select max(ru.rule_value) from room_rules ru
+			where ru.room_id = rm.id and ru.restriction_id = $4
+			and d between ru.start_date and ru.end_date), 0),
+		exists (select 1 from room_rules ru
+			where ru.room_id = rm.id and ru.restriction_id = $5
+			and d between ru.start_date and ru.end_date),
+		exists (select 1 from room_rules ru
+			where ru.room_id = rm.id and ru.restriction_id = $6
+			and d between ru.start_date and ru.end_date)
+		from generate_series($2::date, $3::date, interval '1 day') d
+		join rooms rm on rm.id = $1
+		order by d`
+
+	return m.roomCalendar(query, roomID, start, end)
+}
+
+// roomCalendar runs a RoomCalendar query, which takes the room, the first
+// and last days and the ids of the min stay, closed to arrival and closed to
+// departure rules.
+func (m *postgresDBRepo) roomCalendar(query string, roomID int,
+	start, end time.Time) ([]models.DayAvailability, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var days []models.DayAvailability
+
+	rows, err := m.DB.QueryContext(ctx, query, roomID, start, end,
+		models.RuleMinStay, models.RuleClosedToArrival, models.RuleClosedToDeparture)
+	if err != nil {
+		return days, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d models.DayAvailability
+		err := rows.Scan(&d.Date, &d.Available, &d.Price, &d.MinStay,
+			&d.ClosedToArrival, &d.ClosedToDeparture)
+		if err != nil {
+			return days, err
+		}
+
+		days = append(days, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return days, err
+	}
+
+	return days, nil
+}
+
+// SearchAvailabilityForAllRooms returns the room types of a property that
+// have at least one unit free for the whole stay, with the free units listed
+// in AvailableRooms.
+func (m *postgresDBRepo) SearchAvailabilityForAllRooms(propertyID int,
+	start, end time.Time) ([]models.RoomType, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var roomTypes []models.RoomType
+
+	query := `select rt.id, rt.property_id, rt.type_name, rt.description,
+		(select count(id) from rooms where room_type_id = rt.id),
+		r.id, r.room_name, r.property_id, r.room_type_id, r.max_occupancy,
+		r.bed_configuration
+		from rooms r
+		join room_types rt on (r.room_type_id = rt.id)
+		where r.property_id = $1 and r.id not in
+		(select rr.room_id from room_restrictions rr 
+			where rr.deleted_at is null and
+			$2 < rr.end_date and $3 > rr.start_date)
+		order by rt.type_name, r.room_name;`
+
+	rows, err := m.DB.QueryContext(ctx, query, propertyID, start, end)
+	if err != nil {
+		return roomTypes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rt models.RoomType
+		var room models.Room
+		err = rows.Scan(&rt.ID, &rt.PropertyID, &rt.TypeName, &rt.Description,
+			&rt.Units, &room.ID, &room.RoomName, &room.PropertyID,
+			&room.RoomTypeID, &room.MaxOccupancy, &room.BedConfiguration)
+		if err != nil {
+			return roomTypes, err
+		}
+
+		last := len(roomTypes) - 1
+		if last < 0 || roomTypes[last].ID != rt.ID {
+			roomTypes = append(roomTypes, rt)
+			last++
+		}
+		roomTypes[last].AvailableRooms = append(roomTypes[last].AvailableRooms, room)
+	}
+
+	if err = rows.Err(); err != nil {
+		return roomTypes, err
+	}
+
+	return roomTypes, nil
+}
+
+// AvailableRoomsForType returns the units of a room type that are free for
+// the whole stay.
+func (m *postgresDBRepo) AvailableRoomsForType(roomTypeID int,
+	start, end time.Time) ([]models.Room, error) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var rooms []models.Room
 
-	query := `select r.id, r.room_name from rooms r
-		where r.id not in
+	query := `select r.id, r.room_name, r.property_id, r.room_type_id,
+		r.max_occupancy, r.bed_configuration
+		from rooms r
+		where r.room_type_id = $1 and r.id not in
 		(select rr.room_id from room_restrictions rr 
-			where $1 < rr.end_date and $2 > rr.start_date);`
+			where rr.deleted_at is null and
+			$2 < rr.end_date and $3 > rr.start_date)
+		order by r.room_name;`
 
-	rows, err := m.DB.QueryContext(ctx, query, start, end)
+	rows, err := m.DB.QueryContext(ctx, query, roomTypeID, start, end)
 	if err != nil {
 		return rooms, err
 	}
+	defer rows.Close()
+
 	for rows.Next() {
 		var room models.Room
-		err = rows.Scan(&room.ID, &room.RoomName)
+		err = rows.Scan(&room.ID, &room.RoomName, &room.PropertyID,
+			&room.RoomTypeID, &room.MaxOccupancy, &room.BedConfiguration)
 		if err != nil {
 			return rooms, err
 		}
 		rooms = append(rooms, room)
 	}
 
+	if err = rows.Err(); err != nil {
+		return rooms, err
+	}
+
 	return rooms, nil
 }
 
+func (m *postgresDBRepo) GetRoomTypeByID(id int) (models.RoomType, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var rt models.RoomType
+
+	query := `select rt.id, rt.property_id, rt.type_name, rt.description,
+		(select count(id) from rooms where room_type_id = rt.id),
+		rt.created_at, rt.updated_at
+		from room_types rt where rt.id = $1`
+
+	row := m.DB.QueryRowContext(ctx, query, id)
+
+	err := row.Scan(&rt.ID, &rt.PropertyID, &rt.TypeName, &rt.Description,
+		&rt.Units, &rt.CreatedAt, &rt.UpdatedAt)
+	if err != nil {
+		return rt, err
+	}
+
+	return rt, nil
+}
+
 func (m *postgresDBRepo) GetRoomById(id int) (models.Room, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var room models.Room
 
-	query := `select r.id, r.room_name, r.created_at, r.updated_at from rooms r
-		where r.id = $1`
+	query := `select r.id, r.room_name, r.property_id,
+		coalesce(r.room_type_id, 0), r.max_occupancy, r.bed_configuration,
+		r.nightly_rate, r.created_at, r.updated_at
+		from rooms r where r.id = $1`
 
 	row := m.DB.QueryRowContext(ctx, query, id)
 
-	err := row.Scan(&room.ID, &room.RoomName, &room.CreatedAt, &room.UpdatedAt)
+	err := row.Scan(&room.ID, &room.RoomName, &room.PropertyID,
+		&room.RoomTypeID, &room.MaxOccupancy, &room.BedConfiguration,
+		&room.NightlyRate, &room.CreatedAt, &room.UpdatedAt)
 	if err != nil {
 		return room, err
 	}
@@ -125,25 +341,87 @@ func (m *postgresDBRepo) GetRoomById(id int) (models.Room, error) {
 	return room, err
 }
 
+const userColumns = `id, first_name, last_name, email, password, access_level,
+		disabled_at, created_at, updated_at`
+
+func scanUser(row rowScanner) (models.User, error) {
+	var u models.User
+	var disabledAt sql.NullTime
+	err := row.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Password,
+		&u.AccessLevel, &disabledAt, &u.CreatedAt, &u.UpdatedAt)
+	u.DisabledAt = disabledAt.Time
+	return u, err
+}
+
+// AllUsers returns every user, disabled ones included, ordered by email.
+func (m *postgresDBRepo) AllUsers() ([]models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var users []models.User
+
+	query := `select ` + userColumns + ` from users order by email`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return users, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return users, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return users, err
+	}
+
+	return users, nil
+}
+
 func (m *postgresDBRepo) GetUserByID(id int) (models.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	var u models.User
+	query := `select ` + userColumns + ` from users where id = $1`
 
-	query := `select first_name, last_name, email, password, access_level,
-		created_at, updated_at from users where id = $1;`
+	return scanUser(m.DB.QueryRowContext(ctx, query, id))
+}
 
-	row := m.DB.QueryRowContext(ctx, query, id)
+func (m *postgresDBRepo) GetUserByEmail(email string) (models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select ` + userColumns + ` from users where email = $1`
+
+	return scanUser(m.DB.QueryRowContext(ctx, query, email))
+}
+
+// InsertUser adds a user, storing a hash of u.Password, and returns its id.
+func (m *postgresDBRepo) InsertUser(u models.User) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
-	err := row.Scan(
-		&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Password, &u.AccessLevel,
-		&u.CreatedAt, &u.UpdatedAt)
+	hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), passwordCost)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `insert into users (first_name, last_name, email, password,
+		access_level, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7)`
 
+	newID, err := m.DB.insert(ctx, stmt, u.FirstName, u.LastName, u.Email,
+		string(hash), u.AccessLevel, time.Now(), time.Now())
 	if err != nil {
-		return u, err
+		return 0, err
 	}
-	return u, nil
+
+	return newID, nil
 }
 
 func (m *postgresDBRepo) UpdateUser(u models.User) error {
@@ -151,7 +429,7 @@ func (m *postgresDBRepo) UpdateUser(u models.User) error {
 	defer cancel()
 
 	query := `update users set first_name = $1, last_name = $2, email = $3,
-		access_level = $4, updated_at = $5) where id = $6`
+		access_level = $4, updated_at = $5 where id = $6`
 
 	_, err := m.DB.ExecContext(ctx, query,
 		u.FirstName, u.LastName, u.Email, u.AccessLevel, time.Now(), u.ID)
@@ -162,18 +440,58 @@ func (m *postgresDBRepo) UpdateUser(u models.User) error {
 	return nil
 }
 
+// UpdatePassword stores a hash of password for the user.
+func (m *postgresDBRepo) UpdatePassword(id int, password string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return err
+	}
+
+	query := `update users set password = $1, updated_at = $2 where id = $3`
+
+	_, err = m.DB.ExecContext(ctx, query, string(hash), time.Now(), id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetUserDisabled disables a user, who can then no longer log in, or
+// enables them again.
+func (m *postgresDBRepo) SetUserDisabled(id int, disabled bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var disabledAt sql.NullTime
+	if disabled {
+		disabledAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+
+	query := `update users set disabled_at = $1, updated_at = $2 where id = $3`
+
+	_, err := m.DB.ExecContext(ctx, query, disabledAt, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func (m *postgresDBRepo) Authenticate(email, testPassword string) (int, string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var id int
 	var hashedPassword string
+	var disabled bool
 
-	query := `select id, password from users where email = $1;`
+	query := `select id, password, disabled_at is not null from users where email = $1;`
 
 	row := m.DB.QueryRowContext(ctx, query, email)
 
-	err := row.Scan(&id, &hashedPassword)
+	err := row.Scan(&id, &hashedPassword, &disabled)
 	if err != nil {
 		return 0, "", err
 	}
@@ -185,10 +503,14 @@ func (m *postgresDBRepo) Authenticate(email, testPassword string) (int, string,
 		return 0, "", err
 	}
 
+	if disabled {
+		return 0, "", errors.New("user is disabled")
+	}
+
 	return id, hashedPassword, nil
 }
 
-func (m *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
+func (m *postgresDBRepo) AllReservations(propertyID int) ([]models.Reservation, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -196,12 +518,13 @@ func (m *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
 
 	query := `select r.id, r.first_name, r.last_name, r.email, r.phone,
 		r.start_date, r.end_date, r.room_id, r.created_at, r.updated_at,
-		r.processed, rm.id, rm.room_name
+		r.processed, rm.id, rm.room_name, rm.property_id
 		from reservations r
 		left join rooms rm on (r.room_id = rm.id)
+		where r.deleted_at is null and rm.property_id = $1
 		order by r.start_date asc;`
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, propertyID)
 	if err != nil {
 		return reservations, err
 	}
@@ -210,7 +533,7 @@ func (m *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
 		var r models.Reservation
 		err := rows.Scan(&r.ID, &r.FirstName, &r.LastName, &r.Email, &r.Phone,
 			&r.StartDate, &r.EndDate, &r.RoomID, &r.CreatedAt, &r.UpdatedAt,
-			&r.Processed, &r.Room.ID, &r.Room.RoomName)
+			&r.Processed, &r.Room.ID, &r.Room.RoomName, &r.Room.PropertyID)
 
 		if err != nil {
 			return reservations, err
@@ -226,7 +549,7 @@ func (m *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
 	return reservations, nil
 }
 
-func (m *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
+func (m *postgresDBRepo) AllNewReservations(propertyID int) ([]models.Reservation, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -234,22 +557,76 @@ func (m *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
 
 	query := `select r.id, r.first_name, r.last_name, r.email, r.phone,
 		r.start_date, r.end_date, r.room_id, r.created_at, r.updated_at,
-		r.processed, rm.id, rm.room_name
+		r.processed, rm.id, rm.room_name, rm.property_id
 		from reservations r
 		left join rooms rm on (r.room_id = rm.id)
-		where processed = 0
+		where processed = 0 and r.deleted_at is null and rm.property_id = $1
 		order by r.start_date asc;`
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, propertyID)
+	if err != nil {
+		return reservations, err
+	}
+
+	for rows.Next() {
+		var r models.Reservation
+		err := rows.Scan(&r.ID, &r.FirstName, &r.LastName, &r.Email, &r.Phone,
+			&r.StartDate, &r.EndDate, &r.RoomID, &r.CreatedAt, &r.UpdatedAt,
+			&r.Processed, &r.Room.ID, &r.Room.RoomName, &r.Room.PropertyID)
+
+		if err != nil {
+			return reservations, err
+		}
+
+		reservations = append(reservations, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// SearchReservations finds the reservations of a property whose guest name,
+// email or phone contains text, ignoring case, or whose id is text.
+func (m *postgresDBRepo) SearchReservations(propertyID int, text string) ([]models.Reservation, error) {
+	query := `select r.id, r.first_name, r.last_name, r.email, r.phone,
+		r.start_date, r.end_date, r.room_id, r.created_at, r.updated_at,
+		r.processed, rm.id, rm.room_name, rm.property_id
+		from reservations r
+		left join rooms rm on (r.room_id = rm.id)
+		where r.deleted_at is null and rm.property_id = $1
+		and (r.first_name || ' ' || r.last_name ilike $2 or r.email ilike $2
+			or r.phone ilike $2 or r.id::text = $3)
+		order by r.start_date asc;`
+
+	return m.searchReservations(query, propertyID, text)
+}
+
+// searchReservations runs a SearchReservations query, which takes the
+// property, a like pattern and the text searched for.
+func (m *postgresDBRepo) searchReservations(query string, propertyID int,
+	text string) ([]models.Reservation, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text) + "%"
+
+	rows, err := m.DB.QueryContext(ctx, query, propertyID, pattern, text)
 	if err != nil {
 		return reservations, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var r models.Reservation
 		err := rows.Scan(&r.ID, &r.FirstName, &r.LastName, &r.Email, &r.Phone,
 			&r.StartDate, &r.EndDate, &r.RoomID, &r.CreatedAt, &r.UpdatedAt,
-			&r.Processed, &r.Room.ID, &r.Room.RoomName)
+			&r.Processed, &r.Room.ID, &r.Room.RoomName, &r.Room.PropertyID)
 
 		if err != nil {
 			return reservations, err
@@ -271,7 +648,8 @@ func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
 
 	query := `select r.id, r.first_name, r.last_name, r.email, r.phone,
 	r.start_date, r.end_date, r.room_id, r.created_at, r.updated_at,
-	r.processed, rm.id, rm.room_name
+	r.processed, r.adults, r.children, rm.id, rm.room_name, rm.property_id,
+	coalesce(rm.room_type_id, 0), r.deleted_at
 	from reservations r
 	left join rooms rm on (r.room_id = rm.id)
 	where r.id = $1;`
@@ -279,13 +657,16 @@ func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
 	row := m.DB.QueryRowContext(ctx, query, id)
 
 	var r models.Reservation
+	var deletedAt sql.NullTime
 	err := row.Scan(&r.ID, &r.FirstName, &r.LastName, &r.Email, &r.Phone,
 		&r.StartDate, &r.EndDate, &r.RoomID, &r.CreatedAt, &r.UpdatedAt,
-		&r.Processed, &r.Room.ID, &r.Room.RoomName)
+		&r.Processed, &r.Adults, &r.Children, &r.Room.ID, &r.Room.RoomName,
+		&r.Room.PropertyID, &r.Room.RoomTypeID, &deletedAt)
 
 	if err != nil {
 		return r, err
 	}
+	r.DeletedAt = deletedAt.Time
 
 	return r, nil
 }
@@ -306,77 +687,356 @@ func (m *postgresDBRepo) UpdateReservation(u models.Reservation) error {
 	return nil
 }
 
+// DeleteReservation moves a reservation and its room restrictions to the
+// trash. Nothing is removed until PurgeDeletedReservations runs.
 func (m *postgresDBRepo) DeleteReservation(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `delete from reservations where id = $1`
-
-	_, err := m.DB.ExecContext(ctx, query, id)
-
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	return nil
-}
+	defer tx.Rollback()
 
-func (m *postgresDBRepo) UpdateReservationProcessed(id, processed int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	now := time.Now()
 
-	query := `update reservations set processed = $1 where id = $2`
+	query := `update reservations set deleted_at = $1, updated_at = $1
+		where id = $2 and deleted_at is null`
 
-	_, err := m.DB.ExecContext(ctx, query, processed, id)
+	_, err = tx.ExecContext(ctx, query, now, id)
+	if err != nil {
+		return err
+	}
+
+	query = `update room_restrictions set deleted_at = $1, updated_at = $1
+		where reservation_id = $2 and deleted_at is null`
 
+	_, err = tx.ExecContext(ctx, query, now, id)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return tx.Commit()
 }
 
-func (m *postgresDBRepo) AllRooms() ([]models.Room, error) {
+// ReassignReservation moves a reservation and its room restriction to
+// another room.
+func (m *postgresDBRepo) ReassignReservation(id, roomID int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `select id, room_name, created_at, updated_at from rooms
-		order by room_name`
-
-	var rooms []models.Room
-
-	rows, err := m.DB.QueryContext(ctx, query)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return rooms, err
+		return err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	for rows.Next() {
-		var r models.Room
-		err := rows.Scan(&r.ID, &r.RoomName, &r.CreatedAt, &r.UpdatedAt)
-		if err != nil {
-			return rooms, err
-		}
+	query := `update reservations set room_id = $1, updated_at = $2
+		where id = $3`
 
-		rooms = append(rooms, r)
+	_, err = tx.ExecContext(ctx, query, roomID, time.Now(), id)
+	if err != nil {
+		return err
 	}
 
-	if err = rows.Err(); err != nil {
-		return rooms, err
+	query = `update room_restrictions set room_id = $1, updated_at = $2
+		where reservation_id = $3`
+
+	_, err = tx.ExecContext(ctx, query, roomID, time.Now(), id)
+	if err != nil {
+		return err
 	}
 
-	return rooms, nil
+	return tx.Commit()
 }
 
-func (m *postgresDBRepo) GetRestrictionForRoomByDate(roomId int,
-	start, end time.Time) ([]models.RoomRestrictions, error) {
-
+// RestoreReservation takes a reservation and its room restrictions out of
+// the trash. It returns repository.ErrRoomTaken when the room has been
+// booked for the stay since; as in ReserveRoom, the room row is locked while
+// checked so no booking can land before the restore commits.
+func (m *postgresDBRepo) RestoreReservation(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	var roomRestrictions []models.RoomRestrictions
-
-	query := `select id, coalesce(reservation_id, 0), restriction_id, room_id, 
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var roomID int
+	var start, end time.Time
+	err = tx.QueryRowContext(ctx, `select room_id, start_date, end_date
+		from reservations where id = $1`, id).Scan(&roomID, &start, &end)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `select id from rooms where id = $1 for update`,
+		roomID).Scan(&roomID)
+	if err != nil {
+		return err
+	}
+
+	var numRows int
+	query := `select count(id) from room_restrictions
+		where room_id = $1 and deleted_at is null and
+		coalesce(reservation_id, 0) <> $2 and
+		$3 < end_date and $4 > start_date`
+
+	err = tx.QueryRowContext(ctx, query, roomID, id, start, end).Scan(&numRows)
+	if err != nil {
+		return err
+	}
+	if numRows > 0 {
+		return repository.ErrRoomTaken
+	}
+
+	query = `update reservations set deleted_at = null, updated_at = $1
+		where id = $2`
+
+	_, err = tx.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	query = `update room_restrictions set deleted_at = null, updated_at = $1
+		where reservation_id = $2`
+
+	_, err = tx.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *postgresDBRepo) AllDeletedReservations(propertyID int) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	query := `select r.id, r.first_name, r.last_name, r.email, r.phone,
+		r.start_date, r.end_date, r.room_id, r.created_at, r.updated_at,
+		r.processed, rm.id, rm.room_name, rm.property_id, r.deleted_at
+		from reservations r
+		left join rooms rm on (r.room_id = rm.id)
+		where r.deleted_at is not null and rm.property_id = $1
+		order by r.deleted_at desc;`
+
+	rows, err := m.DB.QueryContext(ctx, query, propertyID)
+	if err != nil {
+		return reservations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Reservation
+		err := rows.Scan(&r.ID, &r.FirstName, &r.LastName, &r.Email, &r.Phone,
+			&r.StartDate, &r.EndDate, &r.RoomID, &r.CreatedAt, &r.UpdatedAt,
+			&r.Processed, &r.Room.ID, &r.Room.RoomName, &r.Room.PropertyID,
+			&r.DeletedAt)
+
+		if err != nil {
+			return reservations, err
+		}
+
+		reservations = append(reservations, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// PurgeDeletedReservations permanently removes reservations and room
+// restrictions that were moved to the trash before the given time, and
+// returns the number of reservations removed.
+func (m *postgresDBRepo) PurgeDeletedReservations(before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `delete from room_restrictions
+		where deleted_at is not null and deleted_at < $1`
+
+	_, err = tx.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `delete from reservations
+		where deleted_at is not null and deleted_at < $1`
+
+	result, err := tx.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(purged), tx.Commit()
+}
+
+func (m *postgresDBRepo) UpdateReservationProcessed(id, processed int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update reservations set processed = $1 where id = $2`
+
+	_, err := m.DB.ExecContext(ctx, query, processed, id)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *postgresDBRepo) AllRooms(propertyID int) ([]models.Room, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select id, room_name, property_id, coalesce(room_type_id, 0),
+		max_occupancy, bed_configuration, nightly_rate, created_at, updated_at from rooms
+		where property_id = $1
+		order by room_name`
+
+	var rooms []models.Room
+
+	rows, err := m.DB.QueryContext(ctx, query, propertyID)
+	if err != nil {
+		return rooms, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Room
+		err := rows.Scan(&r.ID, &r.RoomName, &r.PropertyID, &r.RoomTypeID,
+			&r.MaxOccupancy, &r.BedConfiguration, &r.NightlyRate, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			return rooms, err
+		}
+
+		rooms = append(rooms, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return rooms, err
+	}
+
+	return rooms, nil
+}
+
+// InsertRoom adds a room to a property and returns its id. A room given no
+// type becomes the one unit of a type of its own, named after it, so every
+// room can be searched for.
+func (m *postgresDBRepo) InsertRoom(r models.Room) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if r.RoomTypeID == 0 {
+		stmt := `insert into room_types (property_id, type_name, description,
+			created_at, updated_at) values ($1, $2, '', $3, $4)`
+
+		r.RoomTypeID, err = tx.insert(ctx, stmt, r.PropertyID, r.RoomName,
+			time.Now(), time.Now())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	stmt := `insert into rooms (room_name, property_id, room_type_id,
+		max_occupancy, bed_configuration, nightly_rate, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	newID, err := tx.insert(ctx, stmt, r.RoomName, r.PropertyID, r.RoomTypeID,
+		r.MaxOccupancy, r.BedConfiguration, r.NightlyRate, time.Now(), time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, tx.Commit()
+}
+
+// AllRestrictions returns every kind of restriction and stay rule.
+func (m *postgresDBRepo) AllRestrictions() ([]models.Restriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var restrictions []models.Restriction
+
+	query := `select id, restriction_name, created_at, updated_at
+		from restrictions order by id`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return restrictions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Restriction
+		err := rows.Scan(&r.ID, &r.RestrictionName, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			return restrictions, err
+		}
+		restrictions = append(restrictions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return restrictions, err
+	}
+
+	return restrictions, nil
+}
+
+// InsertRestriction adds a kind of restriction under its own id, which the
+// app refers to by constant.
+func (m *postgresDBRepo) InsertRestriction(r models.Restriction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `insert into restrictions (id, restriction_name, created_at, updated_at)
+		values ($1, $2, $3, $4)`
+
+	_, err := m.DB.ExecContext(ctx, stmt, r.ID, r.RestrictionName, time.Now(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *postgresDBRepo) GetRestrictionForRoomByDate(roomId int,
+	start, end time.Time) ([]models.RoomRestrictions, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var roomRestrictions []models.RoomRestrictions
+
+	query := `select id, coalesce(reservation_id, 0), restriction_id, room_id, 
 		start_date, end_date from room_restrictions where $1 < end_date and 
-		$2 >= start_date and room_id = $3`
+		$2 >= start_date and room_id = $3 and deleted_at is null`
 
 	rows, err := m.DB.QueryContext(ctx, query, start, end, roomId)
 	if err != nil {
@@ -401,3 +1061,551 @@ func (m *postgresDBRepo) GetRestrictionForRoomByDate(roomId int,
 
 	return roomRestrictions, nil
 }
+
+// GetRestrictionsForPropertyByDate returns what is booked or blocked in
+// every room of a property for a night of the stay from start to end.
+func (m *postgresDBRepo) GetRestrictionsForPropertyByDate(propertyID int,
+	start, end time.Time) ([]models.RoomRestrictions, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var roomRestrictions []models.RoomRestrictions
+
+	query := `select rr.id, coalesce(rr.reservation_id, 0), rr.restriction_id,
+		rr.room_id, rr.start_date, rr.end_date
+		from room_restrictions rr
+		join rooms rm on (rr.room_id = rm.id)
+		where rm.property_id = $1 and rr.deleted_at is null and
+		$2 < rr.end_date and $3 > rr.start_date
+		order by rr.room_id, rr.start_date`
+
+	rows, err := m.DB.QueryContext(ctx, query, propertyID, start, end)
+	if err != nil {
+		return roomRestrictions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rr models.RoomRestrictions
+		err := rows.Scan(&rr.ID, &rr.ReservationID, &rr.RestrictionID,
+			&rr.RoomID, &rr.StartDate, &rr.EndDate)
+		if err != nil {
+			return roomRestrictions, err
+		}
+
+		roomRestrictions = append(roomRestrictions, rr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return roomRestrictions, err
+	}
+
+	return roomRestrictions, nil
+}
+
+func (m *postgresDBRepo) AllProperties() ([]models.Property, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select ` + propertyColumns + ` from properties order by id`
+
+	var properties []models.Property
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return properties, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanProperty(rows)
+		if err != nil {
+			return properties, err
+		}
+
+		properties = append(properties, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return properties, err
+	}
+
+	return properties, nil
+}
+
+func (m *postgresDBRepo) GetPropertyByID(id int) (models.Property, error) {
+	query := `select ` + propertyColumns + ` from properties where id = $1`
+
+	return m.getProperty(query, id)
+}
+
+func (m *postgresDBRepo) GetPropertyBySlug(slug string) (models.Property, error) {
+	query := `select ` + propertyColumns + ` from properties where slug = $1`
+
+	return m.getProperty(query, slug)
+}
+
+func (m *postgresDBRepo) GetPropertyByHost(host string) (models.Property, error) {
+	query := `select ` + propertyColumns + ` from properties
+		where host_name <> '' and lower(host_name) = lower($1)`
+
+	return m.getProperty(query, host)
+}
+
+func (m *postgresDBRepo) getProperty(query string, arg interface{}) (
+	models.Property, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanProperty(m.DB.QueryRowContext(ctx, query, arg))
+}
+
+const propertyColumns = `id, property_name, slug, host_name, timezone,
+	check_in_time, check_out_time, created_at, updated_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProperty reads a row selected with propertyColumns.
+func scanProperty(row rowScanner) (models.Property, error) {
+	var p models.Property
+	err := row.Scan(&p.ID, &p.PropertyName, &p.Slug, &p.HostName, &p.Timezone,
+		&p.CheckInTime, &p.CheckOutTime, &p.CreatedAt, &p.UpdatedAt)
+
+	return p, err
+}
+
+// UpdateProperty saves the time zone and check-in and check-out times of a
+// property.
+func (m *postgresDBRepo) UpdateProperty(p models.Property) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update properties set timezone = $1, check_in_time = $2,
+		check_out_time = $3, updated_at = $4 where id = $5`
+
+	_, err := m.DB.ExecContext(ctx, query, p.Timezone, p.CheckInTime,
+		p.CheckOutTime, time.Now(), p.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *postgresDBRepo) GetPropertiesForUser(userID int) ([]models.Property, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select p.id, p.property_name, p.slug, p.host_name, p.timezone,
+		p.check_in_time, p.check_out_time, p.created_at, p.updated_at
+		from properties p
+		join user_properties up on (up.property_id = p.id)
+		where up.user_id = $1
+		order by p.id`
+
+	var properties []models.Property
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return properties, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanProperty(rows)
+		if err != nil {
+			return properties, err
+		}
+
+		properties = append(properties, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return properties, err
+	}
+
+	return properties, nil
+}
+
+func (m *postgresDBRepo) AssignUserToProperty(userID, propertyID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `insert into user_properties (user_id, property_id, created_at,
+		updated_at) values ($1, $2, $3, $4)
+		on conflict (user_id, property_id) do nothing`
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID, propertyID, time.Now(),
+		time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *postgresDBRepo) RemoveUserFromProperty(userID, propertyID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `delete from user_properties where user_id = $1 and property_id = $2`
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID, propertyID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetRulesForRoomByDate returns the stay rules of a room whose date range
+// overlaps start to end, both inclusive.
+func (m *postgresDBRepo) GetRulesForRoomByDate(roomID int,
+	start, end time.Time) ([]models.RoomRule, error) {
+
+	query := `select rr.id, rr.room_id, rr.restriction_id, rr.start_date,
+		rr.end_date, rr.rule_value, r.restriction_name
+		from room_rules rr
+		left join restrictions r on (rr.restriction_id = r.id)
+		where rr.room_id = $1 and rr.start_date <= $3 and rr.end_date >= $2
+		order by rr.start_date`
+
+	return m.getRules(query, roomID, start, end)
+}
+
+// GetRulesForPropertyByDate returns the stay rules of every room of a
+// property whose date range overlaps start to end, both inclusive.
+func (m *postgresDBRepo) GetRulesForPropertyByDate(propertyID int,
+	start, end time.Time) ([]models.RoomRule, error) {
+
+	query := `select rr.id, rr.room_id, rr.restriction_id, rr.start_date,
+		rr.end_date, rr.rule_value, r.restriction_name
+		from room_rules rr
+		left join restrictions r on (rr.restriction_id = r.id)
+		join rooms rm on (rr.room_id = rm.id)
+		where rm.property_id = $1 and rr.start_date <= $3 and rr.end_date >= $2
+		order by rr.start_date`
+
+	return m.getRules(query, propertyID, start, end)
+}
+
+func (m *postgresDBRepo) getRules(query string, id int,
+	start, end time.Time) ([]models.RoomRule, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var rules []models.RoomRule
+
+	rows, err := m.DB.QueryContext(ctx, query, id, start, end)
+	if err != nil {
+		return rules, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rule models.RoomRule
+		err := rows.Scan(&rule.ID, &rule.RoomID, &rule.RestrictionID,
+			&rule.StartDate, &rule.EndDate, &rule.Value,
+			&rule.Restriction.RestrictionName)
+		if err != nil {
+			return rules, err
+		}
+		rule.Restriction.ID = rule.RestrictionID
+
+		rules = append(rules, rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return rules, err
+	}
+
+	return rules, nil
+}
+
+func (m *postgresDBRepo) InsertRoomRule(rule models.RoomRule) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `insert into room_rules (room_id, restriction_id, start_date,
+		end_date, rule_value, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7)`
+
+	newID, err := m.DB.insert(ctx, stmt, rule.RoomID, rule.RestrictionID,
+		rule.StartDate, rule.EndDate, rule.Value, time.Now(), time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+func (m *postgresDBRepo) DeleteRoomRule(id, roomID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `delete from room_rules where id = $1 and room_id = $2`
+
+	_, err := m.DB.ExecContext(ctx, query, id, roomID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InsertBlockForRoom blocks a room for the night starting on startDate.
+func (m *postgresDBRepo) InsertBlockForRoom(roomID int, startDate time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `insert into room_restrictions (start_date, end_date, room_id,
+		restriction_id, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6)`
+
+	_, err := m.DB.ExecContext(ctx, stmt, startDate, startDate.AddDate(0, 0, 1),
+		roomID, models.RestrictionOwnerBlock, time.Now(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteBlockByID removes an owner block from a room. Like reservations,
+// the block is only marked deleted, and is purged along with them.
+func (m *postgresDBRepo) DeleteBlockByID(id, roomID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update room_restrictions set deleted_at = $1, updated_at = $1
+		where id = $2 and room_id = $3 and restriction_id = $4
+		and deleted_at is null`
+
+	_, err := m.DB.ExecContext(ctx, query, time.Now(), id, roomID,
+		models.RestrictionOwnerBlock)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ShortenReservation moves the dates of a reservation and of its room
+// restriction. Callers are expected to only ever shrink the stay.
+func (m *postgresDBRepo) ShortenReservation(id int, start, end time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `update reservations set start_date = $1, end_date = $2,
+		updated_at = $3 where id = $4`
+
+	_, err = tx.ExecContext(ctx, query, start, end, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	query = `update room_restrictions set start_date = $1, end_date = $2,
+		updated_at = $3 where reservation_id = $4`
+
+	_, err = tx.ExecContext(ctx, query, start, end, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *postgresDBRepo) InsertWaitlistEntry(e models.WaitlistEntry) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `insert into waitlist_entries (property_id, room_id, first_name,
+		last_name, email, start_date, end_date, adults, children, locale,
+		created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	newID, err := m.DB.insert(ctx, stmt, e.PropertyID, e.RoomID, e.FirstName,
+		e.LastName, e.Email, e.StartDate, e.EndDate, e.Adults, e.Children,
+		e.Locale, time.Now(), time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+const waitlistColumns = `id, property_id, room_id, first_name, last_name,
+	email, start_date, end_date, adults, children, locale, hold_token, hold_room_id,
+	hold_restriction_id, hold_expires_at, booked_at, created_at, updated_at`
+
+// WaitlistEntriesForNights returns the entries that never got a hold and
+// whose stay fits between start and end in the given room, oldest first.
+func (m *postgresDBRepo) WaitlistEntriesForNights(propertyID, roomID int,
+	start, end time.Time) ([]models.WaitlistEntry, error) {
+
+	query := `select ` + waitlistColumns + ` from waitlist_entries
+		where property_id = $1 and (room_id = $2 or room_id = 0)
+		and start_date >= $3 and end_date <= $4 and hold_token = ''
+		order by created_at`
+
+	return m.getWaitlistEntries(query, propertyID, roomID, start, end)
+}
+
+// ExpiredWaitlistHolds returns the entries whose hold ran out before the
+// given time without being booked.
+func (m *postgresDBRepo) ExpiredWaitlistHolds(before time.Time) ([]models.WaitlistEntry, error) {
+	query := `select ` + waitlistColumns + ` from waitlist_entries
+		where hold_restriction_id > 0 and booked_at is null
+		and hold_expires_at < $1`
+
+	return m.getWaitlistEntries(query, before)
+}
+
+func (m *postgresDBRepo) GetWaitlistEntryByToken(token string) (models.WaitlistEntry, error) {
+	query := `select ` + waitlistColumns + ` from waitlist_entries
+		where hold_token = $1`
+
+	entries, err := m.getWaitlistEntries(query, token)
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	if len(entries) == 0 {
+		return models.WaitlistEntry{}, sql.ErrNoRows
+	}
+
+	return entries[0], nil
+}
+
+func (m *postgresDBRepo) getWaitlistEntries(query string,
+	args ...interface{}) ([]models.WaitlistEntry, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var entries []models.WaitlistEntry
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.WaitlistEntry
+		var holdExpiresAt, bookedAt sql.NullTime
+		err := rows.Scan(&e.ID, &e.PropertyID, &e.RoomID, &e.FirstName,
+			&e.LastName, &e.Email, &e.StartDate, &e.EndDate, &e.Adults,
+			&e.Children, &e.Locale, &e.HoldToken, &e.HoldRoomID, &e.HoldRestrictionID,
+			&holdExpiresAt, &bookedAt, &e.CreatedAt, &e.UpdatedAt)
+		if err != nil {
+			return entries, err
+		}
+		e.HoldExpiresAt = holdExpiresAt.Time
+		e.BookedAt = bookedAt.Time
+
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return entries, err
+	}
+
+	return entries, nil
+}
+
+// PlaceWaitlistHold blocks roomID for the nights of a waitlist entry and
+// records the hold token and its expiry on the entry.
+func (m *postgresDBRepo) PlaceWaitlistHold(entryID, roomID int, token string,
+	expires time.Time) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `insert into room_restrictions (start_date, end_date, room_id,
+		restriction_id, created_at, updated_at)
+		select start_date, end_date, $1, $2, $3, $3 from waitlist_entries
+		where id = $4`
+
+	restrictionID, err := tx.insert(ctx, stmt, roomID, models.RestrictionWaitlistHold,
+		time.Now(), entryID)
+	if err != nil {
+		return err
+	}
+
+	query := `update waitlist_entries set hold_token = $1, hold_room_id = $2,
+		hold_restriction_id = $3, hold_expires_at = $4, updated_at = $5
+		where id = $6`
+
+	_, err = tx.ExecContext(ctx, query, token, roomID, restrictionID, expires,
+		time.Now(), entryID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReleaseWaitlistHold gives the room held for a waitlist entry back.
+func (m *postgresDBRepo) ReleaseWaitlistHold(entryID int) error {
+	return m.endWaitlistHold(entryID, false)
+}
+
+// CompleteWaitlistHold gives the held room back once the guest's own
+// reservation covers it, and marks the entry as booked.
+func (m *postgresDBRepo) CompleteWaitlistHold(entryID int) error {
+	return m.endWaitlistHold(entryID, true)
+}
+
+func (m *postgresDBRepo) endWaitlistHold(entryID int, booked bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `delete from room_restrictions where id =
+		(select hold_restriction_id from waitlist_entries where id = $1)`
+
+	_, err = tx.ExecContext(ctx, query, entryID)
+	if err != nil {
+		return err
+	}
+
+	var bookedAt sql.NullTime
+	if booked {
+		bookedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+
+	query = `update waitlist_entries set hold_restriction_id = 0,
+		booked_at = $1, updated_at = $2 where id = $3`
+
+	_, err = tx.ExecContext(ctx, query, bookedAt, time.Now(), entryID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,134 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// SessionStore keeps the scs sessions in the sessions table, so they survive
+// a restart and are shared by every instance of the app. Each row also
+// holds the id of the user logged in with it, so their sessions can all be
+// ended at once.
+type SessionStore struct {
+	db conn
+	// upsert inserts a session or replaces the one with the same token.
+	upsert string
+}
+
+// NewSessionStore returns the session store of a database connected to with
+// the driver, postgres or mysql.
+func NewSessionStore(driver string, db *sql.DB) *SessionStore {
+	if driver == "mysql" {
+		return &SessionStore{
+			db: conn{DB: db, dialect: mysqlDialect{}},
+			upsert: `insert into sessions (token, data, expiry, user_id)
+				values ($1, $2, $3, $4)
+				on duplicate key update data = values(data),
+				expiry = values(expiry), user_id = values(user_id)`,
+		}
+	}
+	return &SessionStore{
+		db: conn{DB: db, dialect: postgresDialect{}},
+		upsert: `insert into sessions (token, data, expiry, user_id)
+			values ($1, $2, $3, $4)
+			on conflict (token) do update set data = excluded.data,
+			expiry = excluded.expiry, user_id = excluded.user_id`,
+	}
+}
+
+// Find returns the data of the session token, with found unset when there
+// is no such session or it has expired.
+func (s *SessionStore) Find(token string) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var data []byte
+	query := `select data from sessions where token = $1 and expiry > $2`
+	err := s.db.QueryRowContext(ctx, query, token, time.Now().UTC()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// Commit saves the data of the session token until expiry.
+func (s *SessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	userID, err := sessionUserID(b)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, s.upsert, token, b, expiry.UTC(), userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the session token.
+func (s *SessionStore) Delete(token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `delete from sessions where token = $1`, token)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpired removes the sessions that have expired and returns how many
+// there were.
+func (s *SessionStore) DeleteExpired() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `delete from sessions where expiry <= $1`,
+		time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// DeleteUserSessions logs the user out everywhere by removing every session
+// they are logged in with, and returns how many there were.
+func (s *SessionStore) DeleteUserSessions(userID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `delete from sessions where user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// sessionUserID returns the user_id the login handler puts in the session,
+// or 0 when nobody is logged in. The session is read with the gob codec,
+// the one scs uses unless told otherwise.
+func sessionUserID(b []byte) (int, error) {
+	_, values, err := scs.GobCodec{}.Decode(b)
+	if err != nil {
+		return 0, err
+	}
+	id, _ := values["user_id"].(int)
+	return id, nil
+}
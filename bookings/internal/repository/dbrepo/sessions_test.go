@@ -0,0 +1,101 @@
+package dbrepo
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+func TestSessionUserID(t *testing.T) {
+	var tests = []struct {
+		name     string
+		values   map[string]interface{}
+		expected int
+	}{
+		{"logged in", map[string]interface{}{"user_id": 7, "flash": "hi"}, 7},
+		{"guest", map[string]interface{}{"flash": "hi"}, 0},
+	}
+
+	for _, e := range tests {
+		b, err := scs.GobCodec{}.Encode(time.Now().Add(time.Hour), e.values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := sessionUserID(b)
+		if err != nil || id != e.expected {
+			t.Errorf("%s: expected user %d but got %d %v", e.name, e.expected, id, err)
+		}
+	}
+
+	if _, err := sessionUserID([]byte("not a session")); err == nil {
+		t.Errorf("expected an error for data that is not a session")
+	}
+}
+
+func TestPostgresSessionStore(t *testing.T) {
+	testSessionStore(t, "postgres", testPostgresEnv, resetPostgres)
+}
+
+func TestMySQLSessionStore(t *testing.T) {
+	testSessionStore(t, "mysql", testMySQLEnv, resetMySQL)
+}
+
+func testSessionStore(t *testing.T, driver, env string, reset func(db *sql.DB) error) {
+	db := testDB(t, driver, env)
+	migrateTestDB(t, db, reset)
+	store := NewSessionStore(driver, db.SQL)
+
+	commit := func(token string, userID int, expiry time.Time) []byte {
+		t.Helper()
+		b, err := scs.GobCodec{}.Encode(expiry, map[string]interface{}{"user_id": userID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Commit(token, b, expiry); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	found := func(token string) bool {
+		t.Helper()
+		_, ok, err := store.Find(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	later := time.Now().Add(time.Hour)
+	commit("a", 1, later)
+	b := commit("a", 2, later)
+	data, ok, err := store.Find("a")
+	if err != nil || !ok || string(data) != string(b) {
+		t.Errorf("expected the session committed last but got %v %v", ok, err)
+	}
+
+	commit("b", 2, later)
+	commit("c", 3, later)
+	commit("old", 3, time.Now().Add(-time.Hour))
+	if found("old") {
+		t.Errorf("expected an expired session not to be found")
+	}
+	if n, err := store.DeleteExpired(); err != nil || n != 1 {
+		t.Errorf("expected the expired session to be removed but got %d %v", n, err)
+	}
+
+	if n, err := store.DeleteUserSessions(2); err != nil || n != 2 {
+		t.Errorf("expected both sessions of user 2 to end but got %d %v", n, err)
+	}
+	if found("a") || found("b") || !found("c") {
+		t.Errorf("expected only the sessions of user 2 to end")
+	}
+
+	if err := store.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+	if found("c") {
+		t.Errorf("expected the deleted session to be gone")
+	}
+}
@@ -2,16 +2,12 @@ package dbrepo
 
 import (
 	"errors"
-	"log"
 	"time"
 
 	"github.com/chenemiken/goland/bookings/internal/models"
+	"github.com/chenemiken/goland/bookings/internal/repository"
 )
 
-func (m *testDBRepo) AllUsers() bool {
-	return true
-}
-
 func (m *testDBRepo) InsertReservation(res models.Reservation) (int, error) {
 	if res.RoomID == 2 {
 		return 0, errors.New("invalid room Id")
@@ -26,6 +22,18 @@ func (m *testDBRepo) InsertRoomRestriction(rr models.RoomRestrictions) error {
 	return nil
 }
 
+func (m *testDBRepo) ReserveRoom(res models.Reservation, heldID int) (int, error) {
+	if res.RoomID == 2 {
+		return 0, errors.New("invalid room Id")
+	}
+	// room 1 is taken from 2050-06-01, but for the guest holding it
+	if res.RoomID == 1 && heldID != 1 &&
+		res.StartDate.Equal(time.Date(2050, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		return 0, repository.ErrRoomTaken
+	}
+	return 1, nil
+}
+
 func (m *testDBRepo) SearchAvailabilityByDatesByRoomId(startDate,
 	endDate time.Time, roomId int) (bool, error) {
 
@@ -38,24 +46,29 @@ func (m *testDBRepo) SearchAvailabilityByDatesByRoomId(startDate,
 	return false, nil
 }
 
-func (m *testDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) (
-	[]models.Room, error) {
+func (m *testDBRepo) SearchAvailabilityForAllRooms(propertyID int,
+	start, end time.Time) ([]models.RoomType, error) {
 
-	var rooms []models.Room
+	var roomTypes []models.RoomType
 	sd, err := time.Parse("2006-01-02", "2025-01-01")
 	if err != nil {
 		return nil, err
 	}
 	ed, err := time.Parse("2006-01-02", "2025-12-12")
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
 	if start == sd && end == ed {
-		room := models.Room{
-			RoomName: "General's quarters",
+		roomType := models.RoomType{
+			ID:       1,
+			TypeName: "General's quarters",
+			Units:    1,
+			AvailableRooms: []models.Room{
+				{ID: 1, RoomName: "General's quarters", RoomTypeID: 1,
+					MaxOccupancy: 2},
+			},
 		}
-		rooms = append(rooms, room)
+		roomTypes = append(roomTypes, roomType)
 	}
 
 	sd, err = time.Parse("2006-01-02", "2026-01-01")
@@ -67,41 +80,124 @@ func (m *testDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) (
 		return nil, err
 	}
 	if start == sd && end == ed {
-		return rooms, errors.New("failed to search rooms")
+		return roomTypes, errors.New("failed to search rooms")
 	}
 
+	return roomTypes, nil
+}
+
+func (m *testDBRepo) AvailableRoomsForType(roomTypeID int,
+	start, end time.Time) ([]models.Room, error) {
+
+	var rooms []models.Room
+	if roomTypeID == 1 {
+		rooms = append(rooms, models.Room{ID: 1, RoomTypeID: 1, MaxOccupancy: 2})
+	}
+	if roomTypeID == 3 {
+		return rooms, errors.New("error searching room type")
+	}
 	return rooms, nil
 }
 
+func (m *testDBRepo) RoomCalendar(roomID int,
+	start, end time.Time) ([]models.DayAvailability, error) {
+
+	var days []models.DayAvailability
+	if roomID == 2 {
+		return days, errors.New("error building room calendar")
+	}
+
+	// the 5th of every month is booked
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		days = append(days, models.DayAvailability{
+			Date:      d,
+			Available: d.Day() != 5,
+			Price:     100,
+		})
+	}
+	return days, nil
+}
+
+func (m *testDBRepo) GetRoomTypeByID(id int) (models.RoomType, error) {
+	var roomType models.RoomType
+
+	if id > 2 {
+		return roomType, errors.New("error getting room type by id")
+	}
+	roomType.ID = id
+	if id == 1 {
+		roomType.TypeName = "General's quarters"
+		roomType.Units = 1
+	}
+	return roomType, nil
+}
+
 func (m *testDBRepo) GetRoomById(id int) (models.Room, error) {
 	var room models.Room
 
-	if id > 2 {
+	// room 4 is the one with stay rules
+	if id > 2 && id != 4 {
 		return room, errors.New("error getting room by id")
 	}
+	if id == 1 {
+		room.MaxOccupancy = 2
+	}
 	return room, nil
 }
 
+func (m *testDBRepo) AllUsers() ([]models.User, error) {
+	var users []models.User
+	return users, nil
+}
+
 func (m *testDBRepo) GetUserByID(id int) (models.User, error) {
+	var u models.User
+	// user 1 administers every property
+	if id == 1 {
+		u.ID = 1
+		u.AccessLevel = models.AccessAdmin
+	}
+	return u, nil
+}
+
+func (m *testDBRepo) GetUserByEmail(email string) (models.User, error) {
 	var u models.User
 	return u, nil
 }
 
+func (m *testDBRepo) InsertUser(u models.User) (int, error) {
+	return 1, nil
+}
+
 func (m *testDBRepo) UpdateUser(u models.User) error {
 	return nil
 }
 
+func (m *testDBRepo) UpdatePassword(id int, password string) error {
+	return nil
+}
+
+func (m *testDBRepo) SetUserDisabled(id int, disabled bool) error {
+	return nil
+}
+
 func (m *testDBRepo) Authenticate(email, testPassword string) (int, string, error) {
 	return 0, "", nil
 }
 
-func (m *testDBRepo) AllReservations() ([]models.Reservation, error) {
+func (m *testDBRepo) AllReservations(propertyID int) ([]models.Reservation, error) {
 	var reservations []models.Reservation
 
 	return reservations, nil
 }
 
-func (m *testDBRepo) AllNewReservations() ([]models.Reservation, error) {
+func (m *testDBRepo) AllNewReservations(propertyID int) ([]models.Reservation, error) {
+	var reservations []models.Reservation
+
+	return reservations, nil
+}
+
+func (m *testDBRepo) SearchReservations(propertyID int, text string) ([]models.Reservation, error) {
 	var reservations []models.Reservation
 
 	return reservations, nil
@@ -110,6 +206,13 @@ func (m *testDBRepo) AllNewReservations() ([]models.Reservation, error) {
 func (m *testDBRepo) GetReservationByID(id int) (models.Reservation, error) {
 
 	var r models.Reservation
+	if id > 1000 {
+		return r, errors.New("reservation not found")
+	}
+	r.ID = id
+	r.RoomID = id
+	r.StartDate = time.Date(2050, 1, 2, 0, 0, 0, 0, time.UTC)
+	r.EndDate = time.Date(2050, 1, 6, 0, 0, 0, 0, time.UTC)
 
 	return r, nil
 }
@@ -127,13 +230,50 @@ func (m *testDBRepo) UpdateReservationProcessed(id, processed int) error {
 
 	return nil
 }
-func (m *testDBRepo) AllRooms() ([]models.Room, error) {
 
-	var rooms []models.Room
+func (m *testDBRepo) ReassignReservation(id, roomID int) error {
+	return nil
+}
+
+func (m *testDBRepo) AllDeletedReservations(propertyID int) ([]models.Reservation, error) {
+	var reservations []models.Reservation
+
+	return reservations, nil
+}
+
+func (m *testDBRepo) RestoreReservation(id int) error {
+	// the room of reservation 1 has been booked since it was deleted
+	if id == 1 {
+		return repository.ErrRoomTaken
+	}
+	return nil
+}
+
+func (m *testDBRepo) PurgeDeletedReservations(before time.Time) (int, error) {
+	return 0, nil
+}
+func (m *testDBRepo) AllRooms(propertyID int) ([]models.Room, error) {
+
+	rooms := []models.Room{
+		{ID: 1, RoomName: "General's quarters", RoomTypeID: 1, MaxOccupancy: 2},
+	}
 
 	return rooms, nil
 }
 
+func (m *testDBRepo) InsertRoom(r models.Room) (int, error) {
+	return 1, nil
+}
+
+func (m *testDBRepo) AllRestrictions() ([]models.Restriction, error) {
+	var restrictions []models.Restriction
+	return restrictions, nil
+}
+
+func (m *testDBRepo) InsertRestriction(r models.Restriction) error {
+	return nil
+}
+
 func (m *testDBRepo) GetRestrictionForRoomByDate(roomId int,
 	start, end time.Time) ([]models.RoomRestrictions, error) {
 
@@ -141,3 +281,203 @@ func (m *testDBRepo) GetRestrictionForRoomByDate(roomId int,
 
 	return roomRestrictions, nil
 }
+
+func (m *testDBRepo) GetRestrictionsForPropertyByDate(propertyID int,
+	start, end time.Time) ([]models.RoomRestrictions, error) {
+
+	// the general's quarters are only free for stays arriving on
+	// 2050-03-11, for flexible searches around that day
+	roomRestrictions := []models.RoomRestrictions{
+		{ID: 1, RoomID: 1, RestrictionID: models.RestrictionReservation,
+			StartDate: time.Date(2050, 3, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2050, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{ID: 2, RoomID: 1, RestrictionID: models.RestrictionReservation,
+			StartDate: time.Date(2050, 3, 14, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2050, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	return roomRestrictions, nil
+}
+
+func (m *testDBRepo) AllProperties() ([]models.Property, error) {
+	properties := []models.Property{
+		{ID: 1, PropertyName: "Fort Smythe", Slug: "fort-smythe"},
+	}
+
+	return properties, nil
+}
+
+func (m *testDBRepo) GetPropertyByID(id int) (models.Property, error) {
+	var p models.Property
+	if id != 1 {
+		return p, errors.New("property not found")
+	}
+	p = models.Property{ID: 1, PropertyName: "Fort Smythe", Slug: "fort-smythe"}
+
+	return p, nil
+}
+
+func (m *testDBRepo) GetPropertyBySlug(slug string) (models.Property, error) {
+	var p models.Property
+	if slug != "fort-smythe" {
+		return p, errors.New("property not found")
+	}
+	p = models.Property{ID: 1, PropertyName: "Fort Smythe", Slug: "fort-smythe"}
+
+	return p, nil
+}
+
+func (m *testDBRepo) GetPropertyByHost(host string) (models.Property, error) {
+	var p models.Property
+
+	return p, errors.New("property not found")
+}
+
+func (m *testDBRepo) UpdateProperty(p models.Property) error {
+	if p.ID != 1 {
+		return errors.New("property not found")
+	}
+	return nil
+}
+
+func (m *testDBRepo) GetPropertiesForUser(userID int) ([]models.Property, error) {
+	var properties []models.Property
+	// user 2 is assigned to the first property
+	if userID == 2 {
+		properties = append(properties,
+			models.Property{ID: 1, PropertyName: "Fort Smythe", Slug: "fort-smythe"})
+	}
+
+	return properties, nil
+}
+
+func (m *testDBRepo) AssignUserToProperty(userID, propertyID int) error {
+	return nil
+}
+
+func (m *testDBRepo) RemoveUserFromProperty(userID, propertyID int) error {
+	return nil
+}
+
+func (m *testDBRepo) GetRulesForRoomByDate(roomID int,
+	start, end time.Time) ([]models.RoomRule, error) {
+
+	var rules []models.RoomRule
+	if roomID == 4 {
+		rules = append(rules, models.RoomRule{
+			RoomID:        4,
+			RestrictionID: models.RuleMinStay,
+			StartDate:     start,
+			EndDate:       end,
+			Value:         30,
+		})
+	}
+	return rules, nil
+}
+
+func (m *testDBRepo) GetRulesForPropertyByDate(propertyID int,
+	start, end time.Time) ([]models.RoomRule, error) {
+
+	var rules []models.RoomRule
+	return rules, nil
+}
+
+func (m *testDBRepo) InsertRoomRule(rule models.RoomRule) (int, error) {
+	if rule.RoomID == 1000 {
+		return 0, errors.New("invalid room Id")
+	}
+	return 1, nil
+}
+
+func (m *testDBRepo) DeleteRoomRule(id, roomID int) error {
+	return nil
+}
+
+func (m *testDBRepo) InsertBlockForRoom(roomID int, startDate time.Time) error {
+	if roomID == 1000 {
+		return errors.New("invalid room Id")
+	}
+	return nil
+}
+
+func (m *testDBRepo) DeleteBlockByID(id, roomID int) error {
+	return nil
+}
+
+func (m *testDBRepo) ShortenReservation(id int, start, end time.Time) error {
+	return nil
+}
+
+func (m *testDBRepo) InsertWaitlistEntry(e models.WaitlistEntry) (int, error) {
+	if e.RoomID == 1000 {
+		return 0, errors.New("invalid room Id")
+	}
+	return 1, nil
+}
+
+func (m *testDBRepo) WaitlistEntriesForNights(propertyID, roomID int,
+	start, end time.Time) ([]models.WaitlistEntry, error) {
+
+	var entries []models.WaitlistEntry
+	if roomID == 2 {
+		entries = append(entries, models.WaitlistEntry{
+			ID:         1,
+			PropertyID: propertyID,
+			FirstName:  "John",
+			Email:      "waiting@email.com",
+			StartDate:  start,
+			EndDate:    end,
+			Adults:     1,
+			Locale:     "fr",
+		})
+	}
+	if roomID == 3 {
+		return entries, errors.New("error searching waitlist")
+	}
+	return entries, nil
+}
+
+func (m *testDBRepo) ExpiredWaitlistHolds(before time.Time) ([]models.WaitlistEntry, error) {
+	var entries []models.WaitlistEntry
+
+	return entries, nil
+}
+
+func (m *testDBRepo) GetWaitlistEntryByToken(token string) (models.WaitlistEntry, error) {
+	entry := models.WaitlistEntry{
+		ID:                1,
+		PropertyID:        1,
+		FirstName:         "John",
+		Email:             "waiting@email.com",
+		StartDate:         time.Date(2050, 1, 2, 0, 0, 0, 0, time.UTC),
+		EndDate:           time.Date(2050, 1, 4, 0, 0, 0, 0, time.UTC),
+		Adults:            1,
+		HoldToken:         token,
+		HoldRoomID:        1,
+		HoldRestrictionID: 1,
+		HoldExpiresAt:     time.Now().Add(time.Hour),
+	}
+
+	switch token {
+	case "held":
+		return entry, nil
+	case "expired":
+		entry.HoldExpiresAt = time.Now().Add(-time.Hour)
+		return entry, nil
+	}
+	return models.WaitlistEntry{}, errors.New("waitlist entry not found")
+}
+
+func (m *testDBRepo) PlaceWaitlistHold(entryID, roomID int, token string,
+	expires time.Time) error {
+
+	return nil
+}
+
+func (m *testDBRepo) ReleaseWaitlistHold(entryID int) error {
+	return nil
+}
+
+func (m *testDBRepo) CompleteWaitlistHold(entryID int) error {
+	return nil
+}
@@ -1,32 +1,87 @@
 package repository
 
 import (
+	"errors"
 	"time"
 
 	"github.com/chenemiken/goland/bookings/internal/models"
 )
 
+// ErrRoomTaken is returned by ReserveRoom and RestoreReservation when the
+// room is not free for the stay.
+var ErrRoomTaken = errors.New("the room is not free for the stay")
+
 type DatabaseRepo interface {
 	InsertReservation(res models.Reservation) (int, error)
 	InsertRoomRestriction(rr models.RoomRestrictions) error
+	ReserveRoom(res models.Reservation, heldID int) (int, error)
 
 	SearchAvailabilityByDatesByRoomId(startDate, endDate time.Time,
 		roomId int) (bool, error)
-	SearchAvailabilityForAllRooms(start, end time.Time) ([]models.Room, error)
+	SearchAvailabilityForAllRooms(propertyID int,
+		start, end time.Time) ([]models.RoomType, error)
+	AvailableRoomsForType(roomTypeID int,
+		start, end time.Time) ([]models.Room, error)
+	RoomCalendar(roomID int,
+		start, end time.Time) ([]models.DayAvailability, error)
 	GetRoomById(id int) (models.Room, error)
-	AllRooms() ([]models.Room, error)
+	GetRoomTypeByID(id int) (models.RoomType, error)
+	AllRooms(propertyID int) ([]models.Room, error)
+	InsertRoom(r models.Room) (int, error)
+	AllRestrictions() ([]models.Restriction, error)
+	InsertRestriction(r models.Restriction) error
 
+	AllUsers() ([]models.User, error)
 	GetUserByID(id int) (models.User, error)
+	GetUserByEmail(email string) (models.User, error)
+	InsertUser(u models.User) (int, error)
 	UpdateUser(u models.User) error
+	UpdatePassword(id int, password string) error
+	SetUserDisabled(id int, disabled bool) error
 	Authenticate(email, testPassword string) (int, string, error)
 
-	AllReservations() ([]models.Reservation, error)
-	AllNewReservations() ([]models.Reservation, error)
+	AllReservations(propertyID int) ([]models.Reservation, error)
+	AllNewReservations(propertyID int) ([]models.Reservation, error)
+	SearchReservations(propertyID int, text string) ([]models.Reservation, error)
 	GetReservationByID(id int) (models.Reservation, error)
 	UpdateReservation(u models.Reservation) error
 	DeleteReservation(id int) error
 	UpdateReservationProcessed(id, processed int) error
+	ReassignReservation(id, roomID int) error
+	AllDeletedReservations(propertyID int) ([]models.Reservation, error)
+	RestoreReservation(id int) error
+	PurgeDeletedReservations(before time.Time) (int, error)
 
 	GetRestrictionForRoomByDate(roomId int,
 		start, end time.Time) ([]models.RoomRestrictions, error)
+	GetRestrictionsForPropertyByDate(propertyID int,
+		start, end time.Time) ([]models.RoomRestrictions, error)
+	InsertBlockForRoom(roomID int, startDate time.Time) error
+	DeleteBlockByID(id, roomID int) error
+	ShortenReservation(id int, start, end time.Time) error
+
+	GetRulesForRoomByDate(roomID int,
+		start, end time.Time) ([]models.RoomRule, error)
+	GetRulesForPropertyByDate(propertyID int,
+		start, end time.Time) ([]models.RoomRule, error)
+	InsertRoomRule(rule models.RoomRule) (int, error)
+	DeleteRoomRule(id, roomID int) error
+
+	AllProperties() ([]models.Property, error)
+	GetPropertyByID(id int) (models.Property, error)
+	GetPropertyBySlug(slug string) (models.Property, error)
+	GetPropertyByHost(host string) (models.Property, error)
+	UpdateProperty(p models.Property) error
+	GetPropertiesForUser(userID int) ([]models.Property, error)
+	AssignUserToProperty(userID, propertyID int) error
+	RemoveUserFromProperty(userID, propertyID int) error
+
+	InsertWaitlistEntry(e models.WaitlistEntry) (int, error)
+	WaitlistEntriesForNights(propertyID, roomID int,
+		start, end time.Time) ([]models.WaitlistEntry, error)
+	ExpiredWaitlistHolds(before time.Time) ([]models.WaitlistEntry, error)
+	GetWaitlistEntryByToken(token string) (models.WaitlistEntry, error)
+	PlaceWaitlistHold(entryID, roomID int, token string, expires time.Time) error
+	ReleaseWaitlistHold(entryID int) error
+	CompleteWaitlistHold(entryID int) error
 }
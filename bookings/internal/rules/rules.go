@@ -0,0 +1,91 @@
+package rules
+
+import (
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/i18n"
+	"github.com/chenemiken/goland/bookings/internal/models"
+)
+
+// Names lists the stay rules an admin can set, by restriction ID.
+var Names = map[int]string{
+	models.RuleMinStay:            "Minimum stay",
+	models.RuleMaxStay:            "Maximum stay",
+	models.RuleClosedToArrival:    "Closed to arrival",
+	models.RuleClosedToDeparture:  "Closed to departure",
+	models.RuleWeekdayArrivalOnly: "Arrival on weekdays only",
+}
+
+// IsRule reports whether a restriction ID is one of the stay rules.
+func IsRule(restrictionID int) bool {
+	_, ok := Names[restrictionID]
+	return ok
+}
+
+// Nights returns the number of nights between arrival and departure.
+func Nights(start, end time.Time) int {
+	return int(end.Sub(start).Hours()+12) / 24
+}
+
+// Check returns a message, translated into locale, for every rule that a
+// stay from start to end breaks. An empty result means the stay is allowed.
+func Check(rules []models.RoomRule, start, end time.Time, locale string) []string {
+	var messages []string
+
+	nights := Nights(start, end)
+
+	for _, rule := range rules {
+		arrivalInRange := within(start, rule)
+		first := i18n.FormatDate(locale, rule.StartDate)
+		last := i18n.FormatDate(locale, rule.EndDate)
+
+		switch rule.RestrictionID {
+		case models.RuleMinStay:
+			if arrivalInRange && nights < rule.Value {
+				messages = append(messages, i18n.T(locale,
+					"Arrivals between %s and %s require a minimum stay of %d nights",
+					first, last, rule.Value))
+			}
+		case models.RuleMaxStay:
+			if arrivalInRange && nights > rule.Value {
+				messages = append(messages, i18n.T(locale,
+					"Arrivals between %s and %s allow a maximum stay of %d nights",
+					first, last, rule.Value))
+			}
+		case models.RuleClosedToArrival:
+			if arrivalInRange {
+				messages = append(messages, i18n.T(locale,
+					"Arrivals are not possible between %s and %s", first, last))
+			}
+		case models.RuleClosedToDeparture:
+			if within(end, rule) {
+				messages = append(messages, i18n.T(locale,
+					"Departures are not possible between %s and %s", first, last))
+			}
+		case models.RuleWeekdayArrivalOnly:
+			weekday := start.Weekday()
+			if arrivalInRange && (weekday == time.Saturday || weekday == time.Sunday) {
+				messages = append(messages, i18n.T(locale,
+					"Arrivals between %s and %s are only possible Monday to Friday",
+					first, last))
+			}
+		}
+	}
+
+	return messages
+}
+
+// ForRoom returns the rules in the list that apply to the given room.
+func ForRoom(rules []models.RoomRule, roomID int) []models.RoomRule {
+	var roomRules []models.RoomRule
+	for _, rule := range rules {
+		if rule.RoomID == roomID {
+			roomRules = append(roomRules, rule)
+		}
+	}
+	return roomRules
+}
+
+func within(day time.Time, rule models.RoomRule) bool {
+	return !day.Before(rule.StartDate) && !day.After(rule.EndDate)
+}
@@ -0,0 +1,96 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/models"
+)
+
+func date(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+var checkTests = []struct {
+	name     string
+	rule     models.RoomRule
+	start    string
+	end      string
+	violated bool
+}{
+	{"min stay met", models.RoomRule{RestrictionID: models.RuleMinStay, Value: 3,
+		StartDate: date("2050-03-01"), EndDate: date("2050-03-31")},
+		"2050-03-10", "2050-03-13", false},
+	{"min stay broken", models.RoomRule{RestrictionID: models.RuleMinStay, Value: 3,
+		StartDate: date("2050-03-01"), EndDate: date("2050-03-31")},
+		"2050-03-10", "2050-03-12", true},
+	{"min stay outside range", models.RoomRule{RestrictionID: models.RuleMinStay, Value: 3,
+		StartDate: date("2050-03-01"), EndDate: date("2050-03-31")},
+		"2050-04-01", "2050-04-02", false},
+	{"max stay broken", models.RoomRule{RestrictionID: models.RuleMaxStay, Value: 7,
+		StartDate: date("2050-03-01"), EndDate: date("2050-03-31")},
+		"2050-03-01", "2050-03-10", true},
+	{"closed to arrival", models.RoomRule{RestrictionID: models.RuleClosedToArrival,
+		StartDate: date("2050-03-10"), EndDate: date("2050-03-10")},
+		"2050-03-10", "2050-03-12", true},
+	{"closed to arrival on another day", models.RoomRule{RestrictionID: models.RuleClosedToArrival,
+		StartDate: date("2050-03-10"), EndDate: date("2050-03-10")},
+		"2050-03-09", "2050-03-12", false},
+	{"closed to departure", models.RoomRule{RestrictionID: models.RuleClosedToDeparture,
+		StartDate: date("2050-03-12"), EndDate: date("2050-03-12")},
+		"2050-03-09", "2050-03-12", true},
+	{"weekday arrival on saturday", models.RoomRule{RestrictionID: models.RuleWeekdayArrivalOnly,
+		StartDate: date("2050-03-01"), EndDate: date("2050-03-31")},
+		"2050-03-05", "2050-03-07", true},
+	{"weekday arrival on monday", models.RoomRule{RestrictionID: models.RuleWeekdayArrivalOnly,
+		StartDate: date("2050-03-01"), EndDate: date("2050-03-31")},
+		"2050-03-07", "2050-03-09", false},
+}
+
+func TestCheck(t *testing.T) {
+	for _, e := range checkTests {
+		messages := Check([]models.RoomRule{e.rule}, date(e.start), date(e.end), "en")
+		if e.violated && len(messages) == 0 {
+			t.Errorf("%s: expected a violation but got none", e.name)
+		}
+		if !e.violated && len(messages) > 0 {
+			t.Errorf("%s: expected no violation but got %v", e.name, messages)
+		}
+	}
+}
+
+func TestCheckLocale(t *testing.T) {
+	rule := models.RoomRule{RestrictionID: models.RuleMinStay, Value: 3,
+		StartDate: date("2050-03-01"), EndDate: date("2050-03-31")}
+
+	var tests = []struct {
+		locale   string
+		expected string
+	}{
+		{"en", "Arrivals between March 1, 2050 and March 31, 2050 require a minimum stay of 3 nights"},
+		{"fr", "Les arrivées entre le 1 mars 2050 et le 31 mars 2050 exigent un séjour d'au moins 3 nuits"},
+	}
+
+	for _, e := range tests {
+		messages := Check([]models.RoomRule{rule}, date("2050-03-10"), date("2050-03-12"), e.locale)
+		if len(messages) != 1 || messages[0] != e.expected {
+			t.Errorf("%s: expected %q but got %v", e.locale, e.expected, messages)
+		}
+	}
+}
+
+func TestNights(t *testing.T) {
+	if n := Nights(date("2050-03-10"), date("2050-03-13")); n != 3 {
+		t.Errorf("expected 3 nights but got %d", n)
+	}
+}
+
+func TestForRoom(t *testing.T) {
+	list := []models.RoomRule{{ID: 1, RoomID: 1}, {ID: 2, RoomID: 2}, {ID: 3, RoomID: 1}}
+
+	roomRules := ForRoom(list, 1)
+	if len(roomRules) != 2 {
+		t.Errorf("expected 2 rules for room 1 but got %d", len(roomRules))
+	}
+}
@@ -0,0 +1,141 @@
+// Package waitlist offers nights freed by cancellations, shortened stays and
+// removed blocks to the guests waiting for them.
+package waitlist
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/config"
+	"github.com/chenemiken/goland/bookings/internal/i18n"
+	"github.com/chenemiken/goland/bookings/internal/models"
+	"github.com/chenemiken/goland/bookings/internal/repository"
+	"github.com/chenemiken/goland/bookings/internal/rules"
+)
+
+// Offer matches the waitlist of a property against the nights from start to
+// end that just became free in roomID. Every entry that fits, oldest first,
+// gets a hold on the room and an email with a link to book it before
+// app.HoldFor runs out. It returns the number of holds placed.
+func Offer(app *config.AppConfig, db repository.DatabaseRepo, propertyID,
+	roomID int, start, end time.Time) (int, error) {
+
+	if !end.After(start) {
+		return 0, nil
+	}
+
+	entries, err := db.WaitlistEntriesForNights(propertyID, roomID, start, end)
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	room, err := db.GetRoomById(roomID)
+	if err != nil {
+		return 0, err
+	}
+
+	property, err := db.GetPropertyByID(propertyID)
+	if err != nil {
+		return 0, err
+	}
+
+	held := 0
+	for _, e := range entries {
+		if room.MaxOccupancy > 0 && e.Guests() > room.MaxOccupancy {
+			continue
+		}
+
+		// earlier holds in this loop may already cover some of the nights
+		available, err := db.SearchAvailabilityByDatesByRoomId(e.StartDate,
+			e.EndDate, roomID)
+		if err != nil {
+			return held, err
+		}
+		if !available {
+			continue
+		}
+
+		roomRules, err := db.GetRulesForRoomByDate(roomID, e.StartDate, e.EndDate)
+		if err != nil {
+			return held, err
+		}
+		if len(rules.Check(roomRules, e.StartDate, e.EndDate, e.Locale)) > 0 {
+			continue
+		}
+
+		token, err := newToken()
+		if err != nil {
+			return held, err
+		}
+		expires := time.Now().Add(app.HoldFor)
+
+		err = db.PlaceWaitlistHold(e.ID, roomID, token, expires)
+		if err != nil {
+			return held, err
+		}
+		held++
+
+		link := fmt.Sprintf("%s/p/%s/waitlist/hold/%s", app.BaseURL,
+			property.Slug, token)
+
+		htmlMsg := fmt.Sprintf(`
+		<strong>%s</strong><br>
+
+		%s
+		%s
+		%s
+	`, i18n.T(e.Locale, "A room is free for your dates"),
+			i18n.T(e.Locale, "Hi %s,", e.FirstName),
+			i18n.T(e.Locale, "%s now has a room for you from %s to %s. We are holding it for you until %s.",
+				property.PropertyName, i18n.FormatDate(e.Locale, e.StartDate),
+				i18n.FormatDate(e.Locale, e.EndDate),
+				expires.In(property.Location()).Format("2006-01-02 15:04 MST")),
+			i18n.T(e.Locale, `<a href="%s">Book it now</a> before the hold runs out.`, link))
+
+		app.MailChan <- models.MailData{
+			To:      e.Email,
+			From:    "sjol@hub.co",
+			Subject: i18n.T(e.Locale, "A room is free for your dates"),
+			Content: htmlMsg,
+		}
+	}
+
+	return held, nil
+}
+
+// ExpireHolds gives back the rooms held for guests who did not book in time
+// and offers those nights to the next guests waiting. It returns the number
+// of holds released.
+func ExpireHolds(app *config.AppConfig, db repository.DatabaseRepo) (int, error) {
+	entries, err := db.ExpiredWaitlistHolds(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	for i, e := range entries {
+		err = db.ReleaseWaitlistHold(e.ID)
+		if err != nil {
+			return i, err
+		}
+
+		_, err = Offer(app, db, e.PropertyID, e.HoldRoomID, e.StartDate, e.EndDate)
+		if err != nil {
+			return i + 1, err
+		}
+	}
+
+	return len(entries), nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,98 @@
+package waitlist
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chenemiken/goland/bookings/internal/config"
+	"github.com/chenemiken/goland/bookings/internal/models"
+	"github.com/chenemiken/goland/bookings/internal/repository/dbrepo"
+)
+
+func testApp() *config.AppConfig {
+	return &config.AppConfig{
+		HoldFor:  time.Hour,
+		BaseURL:  "http://localhost:8080",
+		MailChan: make(chan models.MailData, 10),
+	}
+}
+
+func TestOffer(t *testing.T) {
+	start := time.Date(2050, 1, 2, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2050, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name      string
+		roomID    int
+		start     time.Time
+		end       time.Time
+		held      int
+		expectErr bool
+	}{
+		// room 2 is free and has a guest waiting in the test repo
+		{"guest waiting", 2, start, end, 1, false},
+		{"nobody waiting", 1, start, end, 0, false},
+		{"no nights freed", 2, start, start, 0, false},
+		{"waitlist search fails", 3, start, end, 0, true},
+	}
+
+	for _, e := range tests {
+		app := testApp()
+		db := dbrepo.NewTestingRepo(app)
+
+		held, err := Offer(app, db, 1, e.roomID, e.start, e.end)
+		if e.expectErr && err == nil {
+			t.Errorf("%s: expected an error but did not get one", e.name)
+		}
+		if !e.expectErr && err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+		}
+		if held != e.held {
+			t.Errorf("%s: expected %d holds but got %d", e.name, e.held, held)
+		}
+		if len(app.MailChan) != e.held {
+			t.Errorf("%s: expected %d emails but got %d", e.name, e.held,
+				len(app.MailChan))
+		}
+	}
+}
+
+func TestOfferLink(t *testing.T) {
+	app := testApp()
+	db := dbrepo.NewTestingRepo(app)
+
+	start := time.Date(2050, 1, 2, 0, 0, 0, 0, time.UTC)
+	_, err := Offer(app, db, 1, 2, start, start.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-app.MailChan
+	if msg.To != "waiting@email.com" {
+		t.Errorf("hold offer sent to %q", msg.To)
+	}
+	if !strings.Contains(msg.Content, "http://localhost:8080/p/fort-smythe/waitlist/hold/") {
+		t.Errorf("hold offer does not link to the hold page: %s", msg.Content)
+	}
+	// the guest in the test repo joined the waitlist in French
+	if msg.Subject != "Une chambre est libre pour vos dates" {
+		t.Errorf("hold offer not sent in the guest's language: %q", msg.Subject)
+	}
+	if !strings.Contains(msg.Content, "du 2 janvier 2050 au 4 janvier 2050") {
+		t.Errorf("hold offer dates not written in French: %s", msg.Content)
+	}
+}
+
+func TestExpireHolds(t *testing.T) {
+	app := testApp()
+	db := dbrepo.NewTestingRepo(app)
+
+	released, err := ExpireHolds(app, db)
+	if err != nil {
+		t.Error(err)
+	}
+	if released != 0 {
+		t.Errorf("expected no holds released but got %d", released)
+	}
+}
@@ -0,0 +1 @@
+delete from rooms
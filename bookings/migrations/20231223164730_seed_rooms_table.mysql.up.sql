@@ -0,0 +1,3 @@
+insert into rooms (id, room_name, created_at, updated_at) values
+(1, 'general''s quarters', '2023-12-18 00:00:00',	'2023-12-18 00:00:00'),
+(2,	'major''s suite',	'2023-12-18 00:00:00',	'2023-12-18 00:00:00');
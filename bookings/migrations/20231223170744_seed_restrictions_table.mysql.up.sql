@@ -0,0 +1,3 @@
+insert into restrictions (id,	restriction_name,	created_at,	updated_at) values
+(1,	'reservation',	'2023-12-18 00:00:00',	'2023-12-18 00:00:00'),
+(2,	'owner_block',	'2023-12-19 00:00:00',	'2023-12-19 00:00:00');
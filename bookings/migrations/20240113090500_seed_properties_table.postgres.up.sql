@@ -0,0 +1,4 @@
+insert into properties (id, property_name, slug, host_name, created_at, updated_at) values
+(1, 'Fort Smythe', 'fort-smythe', '', '2024-01-13 00:00:00', '2024-01-13 00:00:00');
+
+select setval('properties_id_seq', (select max(id) from properties));
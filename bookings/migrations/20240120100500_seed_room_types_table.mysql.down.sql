@@ -0,0 +1,3 @@
+update rooms set room_type_id = null;
+
+delete from room_types
@@ -0,0 +1,5 @@
+insert into room_types (id, property_id, type_name, description, created_at, updated_at) values
+(1, 1, 'General''s Quarters', '', '2024-01-20 00:00:00', '2024-01-20 00:00:00'),
+(2, 1, 'Major''s Suite', '', '2024-01-20 00:00:00', '2024-01-20 00:00:00');
+
+update rooms set room_type_id = id where id in (1, 2);
@@ -0,0 +1,6 @@
+insert into restrictions (id,	restriction_name,	created_at,	updated_at) values
+(3,	'min_stay',	'2024-01-27 00:00:00',	'2024-01-27 00:00:00'),
+(4,	'max_stay',	'2024-01-27 00:00:00',	'2024-01-27 00:00:00'),
+(5,	'closed_to_arrival',	'2024-01-27 00:00:00',	'2024-01-27 00:00:00'),
+(6,	'closed_to_departure',	'2024-01-27 00:00:00',	'2024-01-27 00:00:00'),
+(7,	'weekday_arrival_only',	'2024-01-27 00:00:00',	'2024-01-27 00:00:00');
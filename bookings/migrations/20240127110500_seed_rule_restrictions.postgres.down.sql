@@ -0,0 +1 @@
+delete from restrictions where id in (3, 4, 5, 6, 7)
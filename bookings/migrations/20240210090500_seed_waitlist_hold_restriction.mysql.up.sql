@@ -0,0 +1,2 @@
+insert into restrictions (id,	restriction_name,	created_at,	updated_at) values
+(8,	'Waitlist hold',	'2024-02-10 00:00:00',	'2024-02-10 00:00:00');
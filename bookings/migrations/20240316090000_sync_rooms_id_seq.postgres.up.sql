@@ -0,0 +1 @@
+select setval('rooms_id_seq', (select max(id) from rooms));
@@ -0,0 +1,7 @@
+insert into room_types (property_id, type_name, description, created_at, updated_at)
+select property_id, room_name, '', now(), now() from rooms
+where room_type_id is null order by id;
+
+update rooms set room_type_id = (select max(rt.id) from room_types rt
+	where rt.property_id = rooms.property_id and rt.type_name = rooms.room_name)
+where room_type_id is null;
@@ -1,4 +1,5 @@
 #!/bin/bash
 
-go build -o bookings cmd/web/*.go && 
+go build -o bookings ./cmd/web &&
+./bookings migrate up -dbname=bookings -dbuser=kennethakor &&
 ./bookings -dbname=bookings -dbuser=kennethakor -cache=false -production=false
\ No newline at end of file
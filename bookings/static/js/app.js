@@ -108,3 +108,37 @@ function Prompt() {
         custom: custom,
     }
 }
+
+// roomCalendar fetches a year of per-day availability for a room and
+// returns the days that cannot be picked as arrival or departure dates.
+function roomCalendar(basePath, roomID) {
+    let now = new Date()
+    let month = (d) => d.getFullYear() + "-" + String(d.getMonth() + 1).padStart(2, "0")
+    let from = month(now)
+    let to = month(new Date(now.getFullYear(), now.getMonth() + 11, 1))
+
+    return fetch(basePath + "/room-calendar/" + roomID + "?from=" + from + "&to=" + to)
+        .then(response => response.json())
+        .then(data => {
+            let noArrival = new Set()
+            let noDeparture = new Set()
+            if (data.ok) {
+                data.days.forEach(day => {
+                    if (!day.available || day.closed_to_arrival) {
+                        noArrival.add(day.date)
+                    }
+                    if (day.closed_to_departure) {
+                        noDeparture.add(day.date)
+                    }
+                })
+            }
+            return {noArrival: noArrival, noDeparture: noDeparture}
+        })
+        .catch(() => ({noArrival: new Set(), noDeparture: new Set()}))
+}
+
+// isoDate formats a date picker day as yyyy-mm-dd in local time.
+function isoDate(d) {
+    return d.getFullYear() + "-" + String(d.getMonth() + 1).padStart(2, "0")
+        + "-" + String(d.getDate()).padStart(2, "0")
+}